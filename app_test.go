@@ -4,7 +4,6 @@
 package main
 
 import (
-	"html/template"
 	"sync"
 	"testing"
 
@@ -36,13 +35,9 @@ func initBidAppForTest(t *testing.T) *BidApp {
 		t.Fatalf("cannot init logging: %v", err)
 	}
 
-	// Define the custom functions.
-	funcMap := template.FuncMap{
-		"ToTimeZone": webutil.ToTimeZone,
-	}
-
 	// Initialize templates.
-	tmpl, err := webutil.TemplatesWithFuncs(cfg.App.TmplPattern, funcMap)
+	images := &LocalImageStore{Dir: t.TempDir()}
+	tmpl, err := webutil.TemplatesWithFuncs(cfg.App.TmplPattern, templateFuncs(images))
 	if err != nil {
 		t.Fatalf("Error initializing templates: %v", err)
 	}
@@ -60,14 +55,31 @@ func initBidAppForTest(t *testing.T) *BidApp {
 	}
 
 	// Embed web login app into BidApp.
-	bidApp = &BidApp{AuthApp: app, BidDB: &BidDB{}}
-	bidApp.BidDB.sqlDB = app.DB
+	bidDB := &BidDB{sqlDB: app.DB}
+	bidApp = &BidApp{
+		AuthApp:     app,
+		BidDB:       bidDB,
+		Images:      images,
+		ImageWidths: DefaultImageWidths,
+		ImageLimits: DefaultImageLimits,
+		ImageQueue:  NewImageQueue(bidDB, images, &LocalImageStore{Dir: t.TempDir()}, DefaultImageWidths, DefaultImageLimits, ImageQueueSize),
+		Updates:     NewBroadcaster(),
+	}
+
+	err = bidApp.ConfigAuction()
+	if err != nil {
+		t.Fatalf("cannot config auction: %v", err)
+	}
 
 	return bidApp
 }
 
 // AppForTest is a helper function that returns an App used for testing.
 func AppForTest(t *testing.T) *BidApp {
+	if errSetup != nil {
+		t.Skipf("test database not available: %v", errSetup)
+	}
+
 	once.Do(func() {
 		bidApp = initBidAppForTest(t)
 	})
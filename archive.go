@@ -0,0 +1,609 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/bnixon67/webapp/csv"
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/bnixon67/webapp/webhandler"
+	"github.com/bnixon67/webapp/webutil"
+)
+
+// ArchiveVersion is the version of the archive format written by
+// ExportArchive, which RestoreArchive must match.
+const ArchiveVersion = 1
+
+// Names of the files in an archive. The images of the items are in the
+// images directory of the archive, with their thumbnails in
+// images/thumbnails and their variants in images/variants.
+const (
+	archiveJSON    = "archive.json"
+	archiveWinners = "winners.csv" // for people, not read by RestoreArchive
+	archiveImages  = "images"
+)
+
+// Archive is the permanent record of an auction, which is written as JSON
+// to a zip with the images of its items.
+//
+// Users only have what is needed to know who bid, so restored users must
+// reset their password to log in. Winners are derived from the bids, so
+// they are a record only and are not restored.
+type Archive struct {
+	Version    int
+	Exported   time.Time
+	Auction    Auction
+	Config     []ConfigItem
+	Increments map[string][]Increment // all increment schedules
+	Items      []Item
+	Bids       []ArchiveBid
+	MaxBids    []ArchiveMaxBid
+	Pledges    []ArchivePledge
+	Users      []ArchiveUser // users that bid or pledged
+	Winners    []Winner
+
+	// variants of the images of the items, by image name
+	ImageVariants map[string][]ImageVariant
+}
+
+// ArchiveBid is a row of the bids table.
+type ArchiveBid struct {
+	ItemID  int
+	Created time.Time
+	Bidder  string
+	Amount  Money
+	Units   int
+	BuyNow  bool
+}
+
+// ArchiveMaxBid is a row of the max_bids table.
+type ArchiveMaxBid struct {
+	ItemID  int
+	Bidder  string
+	Amount  Money
+	Created time.Time
+}
+
+// ArchivePledge is a row of the pledges table.
+type ArchivePledge struct {
+	ItemID  int
+	Created time.Time
+	Pledger string
+	Amount  Money
+}
+
+// ArchiveUser is a user without their password.
+type ArchiveUser struct {
+	Username string
+	FullName string
+	Email    string
+}
+
+var (
+	ErrArchiveFile     = errors.New("invalid archive file")
+	ErrArchiveVersion  = errors.New("unsupported archive version")
+	ErrArchiveConflict = errors.New("archive conflicts with database")
+)
+
+// ReadArchive returns the archive of auction from store and db, which is
+// the database of store.
+func ReadArchive(store BidStore, db *webauth.AuthDB, auction Auction) (Archive, error) {
+	if db == nil {
+		return Archive{}, ErrInvalidDB
+	}
+
+	archive := Archive{
+		Version:  ArchiveVersion,
+		Exported: time.Now().UTC(),
+		Auction:  auction,
+	}
+
+	var err error
+
+	archive.Increments, err = store.GetIncrementSchedules()
+	if err != nil {
+		return archive, err
+	}
+
+	archive.Items, err = store.GetItems(auction.ID)
+	if err != nil {
+		return archive, err
+	}
+
+	archive.Winners, err = store.GetWinners(auction.ID)
+	if err != nil {
+		return archive, err
+	}
+
+	var imageNames []string
+	for i, item := range archive.Items {
+		archive.Items[i].Images, err = getItemImages(db, item)
+		if err != nil {
+			return archive, fmt.Errorf("images of item %d: %w", item.ID, err)
+		}
+		for _, image := range archive.Items[i].Images {
+			imageNames = append(imageNames, path.Base(image))
+		}
+	}
+
+	archive.ImageVariants, err = store.GetImageVariants(imageNames)
+	if err != nil {
+		return archive, fmt.Errorf("image variants: %w", err)
+	}
+
+	err = queryArchive(db, func(rows *sql.Rows) error {
+		var ci ConfigItem
+		err := rows.Scan(&ci.Name, &ci.Value, &ci.ValueType)
+		archive.Config = append(archive.Config, ci)
+		return err
+	}, "SELECT name, value, value_type FROM config ORDER BY name")
+	if err != nil {
+		return archive, fmt.Errorf("config: %w", err)
+	}
+
+	err = queryArchive(db, func(rows *sql.Rows) error {
+		var bid ArchiveBid
+		err := rows.Scan(&bid.ItemID, &bid.Created, &bid.Bidder, &bid.Amount, &bid.Units, &bid.BuyNow)
+		archive.Bids = append(archive.Bids, bid)
+		return err
+	}, "SELECT b.id, b.created, b.bidder, b.amount, b.units, b.buyNow FROM bids b INNER JOIN items ON b.id = items.id WHERE items.auctionId = ? ORDER BY b.id, b.created", auction.ID)
+	if err != nil {
+		return archive, fmt.Errorf("bids: %w", err)
+	}
+
+	err = queryArchive(db, func(rows *sql.Rows) error {
+		var maxBid ArchiveMaxBid
+		err := rows.Scan(&maxBid.ItemID, &maxBid.Bidder, &maxBid.Amount, &maxBid.Created)
+		archive.MaxBids = append(archive.MaxBids, maxBid)
+		return err
+	}, "SELECT m.id, m.bidder, m.amount, m.created FROM max_bids m INNER JOIN items ON m.id = items.id WHERE items.auctionId = ? ORDER BY m.id, m.bidder", auction.ID)
+	if err != nil {
+		return archive, fmt.Errorf("max_bids: %w", err)
+	}
+
+	err = queryArchive(db, func(rows *sql.Rows) error {
+		var pledge ArchivePledge
+		err := rows.Scan(&pledge.ItemID, &pledge.Created, &pledge.Pledger, &pledge.Amount)
+		archive.Pledges = append(archive.Pledges, pledge)
+		return err
+	}, "SELECT p.itemId, p.created, p.pledger, p.amount FROM pledges p INNER JOIN items ON p.itemId = items.id WHERE items.auctionId = ? ORDER BY p.id", auction.ID)
+	if err != nil {
+		return archive, fmt.Errorf("pledges: %w", err)
+	}
+
+	err = queryArchive(db, func(rows *sql.Rows) error {
+		var user ArchiveUser
+		err := rows.Scan(&user.Username, &user.FullName, &user.Email)
+		archive.Users = append(archive.Users, user)
+		return err
+	}, "SELECT username, fullName, email FROM users WHERE username IN (SELECT b.bidder FROM bids b INNER JOIN items ON b.id = items.id WHERE items.auctionId = ? UNION SELECT p.pledger FROM pledges p INNER JOIN items ON p.itemId = items.id WHERE items.auctionId = ?) ORDER BY username", auction.ID, auction.ID)
+	if err != nil {
+		return archive, fmt.Errorf("users: %w", err)
+	}
+
+	return archive, nil
+}
+
+// queryArchive runs qry with args and calls scan for each row.
+func queryArchive(db *webauth.AuthDB, scan func(rows *sql.Rows) error, qry string, args ...any) error {
+	rows, err := db.Query(qry, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportArchive writes the archive of auction from store and db to w as a
+// zip, with the images of its items, and their thumbnails and variants,
+// from images. It returns the names of images that are missing from
+// images, which are left out.
+func ExportArchive(w io.Writer, store BidStore, db *webauth.AuthDB, auction Auction, images ImageStore) ([]string, error) {
+	archive, err := ReadArchive(store, db, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+
+	// the images are added first, so the variants that are missing are
+	// left out of the archive
+	var missing []string
+	added := make(map[string]bool)
+	addImage := func(name string) error {
+		if added[name] {
+			return nil
+		}
+		added[name] = true
+
+		err := addArchiveFile(zw, path.Join(archiveImages, name), images, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, name)
+			return nil
+		}
+
+		return err
+	}
+
+	for _, item := range archive.Items {
+		for _, image := range item.Images {
+			base := path.Base(image)
+			for _, name := range []string{base, ThumbnailName(base)} {
+				err = addImage(name)
+				if err != nil {
+					return missing, err
+				}
+			}
+
+			var variants []ImageVariant
+			for _, variant := range archive.ImageVariants[base] {
+				err = addImage(variant.Name)
+				if err != nil {
+					return missing, err
+				}
+				if !slices.Contains(missing, variant.Name) {
+					variants = append(variants, variant)
+				}
+			}
+			if len(variants) == 0 {
+				delete(archive.ImageVariants, base)
+			} else {
+				archive.ImageVariants[base] = variants
+			}
+		}
+	}
+
+	f, err := zw.Create(archiveJSON)
+	if err != nil {
+		return missing, err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(archive)
+	if err != nil {
+		return missing, err
+	}
+
+	f, err = zw.Create(archiveWinners)
+	if err != nil {
+		return missing, err
+	}
+	err = csv.SliceOfStructsToCSV(f, archive.Winners)
+	if err != nil {
+		return missing, err
+	}
+
+	return missing, zw.Close()
+}
+
+// addArchiveFile adds the image imageName of images to zw as name.
+func addArchiveFile(zw *zip.Writer, name string, images ImageStore, imageName string) error {
+	in, err := images.Get(imageName)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, in)
+
+	return err
+}
+
+// OpenArchive returns the archive in zr.
+func OpenArchive(zr *zip.Reader) (Archive, error) {
+	var archive Archive
+
+	f, err := zr.Open(archiveJSON)
+	if err != nil {
+		return archive, fmt.Errorf("%w: %v", ErrArchiveFile, err)
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&archive)
+	if err != nil {
+		return archive, fmt.Errorf("%w: %v", ErrArchiveFile, err)
+	}
+
+	if archive.Version != ArchiveVersion {
+		return archive, fmt.Errorf("%w: %d", ErrArchiveVersion, archive.Version)
+	}
+
+	return archive, nil
+}
+
+// RestoreArchive restores the archive in zr to db, which was opened with
+// driverName, and its images to images. The auction and its items keep
+// their ids, so they must not be in db. Config items, increment schedules,
+// users, images, and the variants of images that are already present are
+// kept as they are.
+func RestoreArchive(zr *zip.Reader, db *webauth.AuthDB, driverName string, images ImageStore) (Archive, error) {
+	if db == nil {
+		return Archive{}, ErrInvalidDB
+	}
+
+	archive, err := OpenArchive(zr)
+	if err != nil {
+		return archive, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return archive, err
+	}
+	defer tx.Rollback()
+
+	err = restoreArchive(tx, archive)
+	if err != nil {
+		return archive, err
+	}
+
+	if driverName == DriverPostgres {
+		// explicit ids do not advance the sequences of serial columns
+		for _, table := range []string{"auctions", "items", "pledges"} {
+			_, err = tx.Exec("SELECT setval(pg_get_serial_sequence('" + table + "', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM " + table + "), false)")
+			if err != nil {
+				return archive, fmt.Errorf("%s sequence: %w", table, err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return archive, err
+	}
+
+	return archive, restoreArchiveImages(zr, images)
+}
+
+// restoreArchive inserts the rows of archive in tx.
+func restoreArchive(tx *sql.Tx, archive Archive) error {
+	a := archive.Auction
+
+	exists, err := rowExists(tx, "SELECT 1 FROM auctions WHERE id = ? OR slug = ?", a.ID, a.Slug)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: auction %d %q exists", ErrArchiveConflict, a.ID, a.Slug)
+	}
+
+	insert := "INSERT INTO auctions(" + auctionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = tx.Exec(insert, a.ID, a.Slug, a.Name, a.TimeZone, cmp.Or(a.Currency, DefaultCurrency), storedTime(a.AuctionStart), storedTime(a.AuctionEnd), int(a.SoftCloseWindow.Seconds()), int(a.SoftCloseExtension.Seconds()), a.BuyNowCutoff, a.IncrSchedule, storedTime(a.Created))
+	if err != nil {
+		return fmt.Errorf("auction: %w", err)
+	}
+
+	for _, ci := range archive.Config {
+		err = insertMissing(tx, "SELECT 1 FROM config WHERE name = ?", []any{ci.Name},
+			"INSERT INTO config(name, value, value_type) VALUES (?, ?, ?)", ci.Name, ci.Value, ci.ValueType)
+		if err != nil {
+			return fmt.Errorf("config %q: %w", ci.Name, err)
+		}
+	}
+
+	for schedule, increments := range archive.Increments {
+		exists, err := rowExists(tx, "SELECT 1 FROM increments WHERE schedule = ?", schedule)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		for _, incr := range increments {
+			_, err = tx.Exec("INSERT INTO increments(schedule, fromAmount, increment) VALUES (?, ?, ?)", schedule, incr.From, incr.Amount)
+			if err != nil {
+				return fmt.Errorf("increments %q: %w", schedule, err)
+			}
+		}
+	}
+
+	for _, user := range archive.Users {
+		err = insertMissing(tx, "SELECT 1 FROM users WHERE username = ?", []any{user.Username},
+			"INSERT INTO users(username, fullName, email, hashedPassword) VALUES (?, ?, ?, '')", user.Username, user.FullName, user.Email)
+		if err != nil {
+			return fmt.Errorf("user %q: %w", user.Username, err)
+		}
+	}
+
+	for _, item := range archive.Items {
+		exists, err := rowExists(tx, "SELECT 1 FROM items WHERE id = ?", item.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: item %d exists", ErrArchiveConflict, item.ID)
+		}
+
+		insert := "INSERT INTO items(id, auctionId, itemType, title, created, description, openingBid, minBidIncr, incrSchedule, quantity, reservePrice, buyNowPrice, pledgeLevels, artist, imageFileName, opensAt, closesAt, extendedTo, soldAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		_, err = tx.Exec(insert, item.ID, a.ID, item.ItemType, item.Title, storedTime(item.Created), item.Description, item.OpeningBid, item.MinBidIncr, item.IncrSchedule, item.Quantity, item.ReservePrice, item.BuyNowPrice, FormatPledgeLevels(item.PledgeLevels), item.Artist, item.ImageFileName, storedTimeOrNil(item.OpensAt), storedTimeOrNil(item.ClosesAt), storedTimeOrNil(item.ExtendedTo), storedTimeOrNil(item.SoldAt))
+		if err != nil {
+			return fmt.Errorf("item %d: %w", item.ID, err)
+		}
+
+		for position, fileName := range item.Images {
+			_, err = tx.Exec("INSERT INTO item_images(itemId, position, fileName) VALUES (?, ?, ?)", item.ID, position, fileName)
+			if err != nil {
+				return fmt.Errorf("images of item %d: %w", item.ID, err)
+			}
+		}
+	}
+
+	for fileName, variants := range archive.ImageVariants {
+		exists, err := rowExists(tx, "SELECT 1 FROM image_variants WHERE fileName = ?", fileName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		for _, variant := range variants {
+			_, err = tx.Exec("INSERT INTO image_variants(name, fileName, width, mimeType) VALUES (?, ?, ?, ?)", variant.Name, fileName, variant.Width, variant.Type)
+			if err != nil {
+				return fmt.Errorf("variants of image %q: %w", fileName, err)
+			}
+		}
+	}
+
+	for _, bid := range archive.Bids {
+		_, err = tx.Exec("INSERT INTO bids(id, created, bidder, amount, units, buyNow) VALUES (?, ?, ?, ?, ?, ?)", bid.ItemID, storedTime(bid.Created), bid.Bidder, bid.Amount, bid.Units, bid.BuyNow)
+		if err != nil {
+			return fmt.Errorf("bid on item %d: %w", bid.ItemID, err)
+		}
+	}
+
+	for _, maxBid := range archive.MaxBids {
+		_, err = tx.Exec("INSERT INTO max_bids(id, bidder, amount, created) VALUES (?, ?, ?, ?)", maxBid.ItemID, maxBid.Bidder, maxBid.Amount, storedTime(maxBid.Created))
+		if err != nil {
+			return fmt.Errorf("max bid on item %d: %w", maxBid.ItemID, err)
+		}
+	}
+
+	for _, pledge := range archive.Pledges {
+		_, err = tx.Exec("INSERT INTO pledges(itemId, created, pledger, amount) VALUES (?, ?, ?, ?)", pledge.ItemID, storedTime(pledge.Created), pledge.Pledger, pledge.Amount)
+		if err != nil {
+			return fmt.Errorf("pledge on item %d: %w", pledge.ItemID, err)
+		}
+	}
+
+	return nil
+}
+
+// storedTimeOrNil returns t as it is stored in the database, or nil if t
+// is nil.
+func storedTimeOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return storedTime(*t)
+}
+
+// rowExists returns true if qry with args returns a row.
+func rowExists(tx *sql.Tx, qry string, args ...any) (bool, error) {
+	var one int
+
+	err := tx.QueryRow(qry, args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// insertMissing runs insert with insertArgs unless qry with args returns a
+// row.
+func insertMissing(tx *sql.Tx, qry string, args []any, insert string, insertArgs ...any) error {
+	exists, err := rowExists(tx, qry, args...)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec(insert, insertArgs...)
+
+	return err
+}
+
+// restoreArchiveImages puts the images in zr to images, keeping any that
+// already exist.
+func restoreArchiveImages(zr *zip.Reader, images ImageStore) error {
+	for _, f := range zr.File {
+		dir, name := path.Split(f.Name)
+		if name == "" {
+			continue
+		}
+
+		switch dir {
+		case archiveImages + "/":
+		case archiveImages + "/thumbnails/":
+			name = ThumbnailName(name)
+		case archiveImages + "/variants/":
+			name = path.Join("variants", name)
+		default:
+			continue
+		}
+
+		in, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("image %q: %w", f.Name, err)
+		}
+		err = images.Put(name, in)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("image %q: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ArchiveHandler downloads the archive of an auction, which is only
+// allowed for admin users.
+func (app *BidApp) ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	// Check if the HTTP method is valid.
+	if !webutil.IsMethodOrError(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	user, err := app.DB.UserFromRequest(w, r)
+	if err != nil {
+		logger.Error("failed to GetUser", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	if !user.IsAdmin {
+		logger.Error("user not authorized", "user", user)
+		webutil.RespondWithError(w, http.StatusUnauthorized)
+		return
+	}
+
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		"attachment;filename="+auction.Slug+"-archive.zip")
+
+	missing, err := ExportArchive(w, app.BidDB, app.DB, auction, app.Images)
+	if err != nil {
+		// the response has started, so the error cannot be sent
+		logger.Error("failed to export archive", "err", err)
+		return
+	}
+
+	logger.Info("exported archive", "auction", auction.Slug, "missing", missing)
+}
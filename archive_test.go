@@ -0,0 +1,140 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestArchive(t *testing.T) {
+	src := sqliteDBForTest(t)
+
+	_, err := src.PlaceBid(1, Cents(3000), "test")
+	if err != nil {
+		t.Fatalf("PlaceBid() failed: %v", err)
+	}
+	_, err = src.PlaceBid(1, Cents(2000), "admin")
+	if err != nil {
+		t.Fatalf("PlaceBid() failed: %v", err)
+	}
+	_, err = src.Pledge(6, Cents(10000), "admin")
+	if err != nil {
+		t.Fatalf("Pledge() failed: %v", err)
+	}
+
+	// the file of the WebP variant is missing, so it is left out
+	jpegVariant := ImageVariant{Name: VariantName("File", 4, ImageTypeJPEG), Width: 4, Type: ImageTypeJPEG}
+	webpVariant := ImageVariant{Name: VariantName("File", 4, ImageTypeWebP), Width: 4, Type: ImageTypeWebP}
+	err = src.SetImageVariants("File", []ImageVariant{jpegVariant, webpVariant})
+	if err != nil {
+		t.Fatalf("SetImageVariants() failed: %v", err)
+	}
+
+	srcImages := &LocalImageStore{Dir: t.TempDir()}
+	for _, name := range []string{"File", ThumbnailName("File"), jpegVariant.Name} {
+		err = srcImages.Put(name, strings.NewReader(name))
+		if err != nil {
+			t.Fatalf("Put(%q) failed: %v", name, err)
+		}
+	}
+
+	// the currency is restored, not the default
+	_, err = src.sqlDB.Exec("UPDATE auctions SET currency = 'EUR' WHERE slug = 'test'")
+	if err != nil {
+		t.Fatalf("Exec() failed: %v", err)
+	}
+
+	auction, err := src.GetAuction("test")
+	if err != nil {
+		t.Fatalf("GetAuction() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	missing, err := ExportArchive(&buf, src, src.sqlDB, auction, srcImages)
+	if err != nil || !slices.Equal(missing, []string{webpVariant.Name}) {
+		t.Fatalf("ExportArchive() got missing %q err '%v' want %q", missing, err, webpVariant.Name)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+
+	dst := emptySQLiteDBForTest(t)
+	dstImages := &LocalImageStore{Dir: t.TempDir()}
+
+	archive, err := RestoreArchive(zr, dst.sqlDB, DriverSQLite, dstImages)
+	if err != nil {
+		t.Fatalf("RestoreArchive() failed: %v", err)
+	}
+	if len(archive.Items) != 9 || len(archive.Bids) != 3 || len(archive.Pledges) != 1 || len(archive.Users) != 2 {
+		t.Errorf("RestoreArchive() got %d items, %d bids, %d pledges, %d users", len(archive.Items), len(archive.Bids), len(archive.Pledges), len(archive.Users))
+	}
+
+	gotAuction, err := dst.GetAuction("test")
+	if err != nil {
+		t.Fatalf("GetAuction() after restore failed: %v", err)
+	}
+	if diff := cmp.Diff(auction, gotAuction); diff != "" {
+		t.Errorf("auction mismatch (-want +got):\n%s", diff)
+	}
+
+	for name, get := range map[string]func(store BidStore) (any, error){
+		"items":   func(store BidStore) (any, error) { return store.GetItems(auction.ID) },
+		"winners": func(store BidStore) (any, error) { return store.GetWinners(auction.ID) },
+		"bids":    func(store BidStore) (any, error) { return store.GetBidsForItem(1) },
+		"max bid": func(store BidStore) (any, error) { return store.GetMaxBid(1, "test") },
+		"current": func(store BidStore) (any, error) { return store.GetConfigItem("current_auction") },
+	} {
+		want, err := get(src)
+		if err != nil {
+			t.Fatalf("%s of source failed: %v", name, err)
+		}
+		got, err := get(dst)
+		if err != nil {
+			t.Fatalf("%s after restore failed: %v", name, err)
+		}
+		// times are stored to the microsecond
+		if diff := cmp.Diff(want, got, cmpopts.EquateApproxTime(time.Microsecond)); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+
+	for _, name := range []string{ThumbnailName("File"), jpegVariant.Name} {
+		got, err := readImageForTest(dstImages, name)
+		if err != nil || got != name {
+			t.Errorf("restored image %q got %q err '%v'", name, got, err)
+		}
+	}
+
+	variants, err := dst.GetImageVariants([]string{"File"})
+	if err != nil || !slices.Equal(variants["File"], []ImageVariant{jpegVariant}) {
+		t.Errorf("GetImageVariants() after restore got %v err '%v' want %v", variants, err, jpegVariant)
+	}
+
+	// new items must not reuse the ids of restored items
+	id, err := dst.CreateItem(Item{AuctionID: auction.ID, ItemType: ItemTypeAuction, Title: "New", Description: "New", Artist: "Artist", ImageFileName: "File", Quantity: 1})
+	if err != nil || id != 10 {
+		t.Errorf("CreateItem() after restore got %d err '%v' want 10", id, err)
+	}
+
+	_, err = RestoreArchive(zr, dst.sqlDB, DriverSQLite, dstImages)
+	if !errors.Is(err, ErrArchiveConflict) {
+		t.Errorf("RestoreArchive() again got err '%v' want '%v'", err, ErrArchiveConflict)
+	}
+
+	_, err = RestoreArchive(zipForTest(t, "images/File"), dst.sqlDB, DriverSQLite, dstImages)
+	if !errors.Is(err, ErrArchiveFile) {
+		t.Errorf("RestoreArchive() without %s got err '%v' want '%v'", archiveJSON, err, ErrArchiveFile)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/bnixon67/webapp/webhandler"
+	"github.com/bnixon67/webapp/webutil"
+)
+
+// AuctionsPageData contains data passed to the HTML template.
+type AuctionsPageData struct {
+	Title    string
+	Message  string
+	User     webauth.User
+	Auctions []Auction
+}
+
+// auctionFromRequest returns the auction named by the slug in the request
+// path, or the default auction if the path does not name one.
+func (app *BidApp) auctionFromRequest(r *http.Request) (Auction, error) {
+	slug := r.PathValue("slug")
+	if slug == "" {
+		slug = app.DefaultAuction
+	}
+
+	return app.BidDB.GetAuction(slug)
+}
+
+// auctionOrError returns the auction for the request. If the auction
+// cannot be found, an error is written to w and ok is false.
+func (app *BidApp) auctionOrError(w http.ResponseWriter, r *http.Request) (auction Auction, ok bool) {
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	auction, err := app.auctionFromRequest(r)
+	if err != nil {
+		logger.Error("failed to get auction", "err", err)
+		if errors.Is(err, ErrNotFound) {
+			webutil.RespondWithError(w, http.StatusNotFound)
+		} else {
+			webutil.RespondWithError(w, http.StatusInternalServerError)
+		}
+		return auction, false
+	}
+
+	return auction, true
+}
+
+// hiddenItemIDs returns the IDs of the items in auction with bids that must
+// be hidden from user.
+func (app *BidApp) hiddenItemIDs(auction Auction, user webauth.User) (map[int]bool, error) {
+	hidden := make(map[int]bool)
+
+	if user.IsAdmin {
+		return hidden, nil
+	}
+
+	items, err := app.BidDB.GetItems(auction.ID)
+	if err != nil {
+		return hidden, err
+	}
+
+	for _, item := range items {
+		if auction.BidsHidden(item, user) {
+			hidden[item.ID] = true
+		}
+	}
+
+	return hidden, nil
+}
+
+// AuctionsHandler displays a list of auctions.
+func (app *BidApp) AuctionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	// Check if the HTTP method is valid.
+	if !webutil.IsMethodOrError(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	user, err := app.DB.UserFromRequest(w, r)
+	if err != nil {
+		logger.Error("failed to get user", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	if app.BidDB == nil {
+		logger.Error("database is nil")
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	auctions, err := app.BidDB.GetAuctions()
+	if err != nil {
+		logger.Error("failed to get auctions", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	err = webutil.RenderTemplateOrError(app.Tmpl, w, "auctions.html",
+		AuctionsPageData{
+			Title:    app.Cfg.App.Name,
+			User:     user,
+			Auctions: auctions,
+		})
+	if err != nil {
+		logger.Error("unable to render template", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("success", "username", user.Username,
+		"auctions", len(auctions))
+}
@@ -0,0 +1,447 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// bidTx reads and writes the rows used to place a bid, buy an item, or
+// pledge, for a store that does not have the placeBid, buyNow, and
+// placePledge procedures. It must hold a lock or a transaction so that no
+// other bid on the item is placed until it is done.
+type bidTx interface {
+	// item returns item id as GetItem does and its auction, or an error
+	// that wraps ErrNotFound if there is no such item.
+	item(id int) (Item, Auction, error)
+
+	// maxBid returns the maximum bid of bidder for item id, or zero.
+	maxBid(id int, bidder string) (Money, error)
+
+	// standingAmount returns the amount of the latest bid of bidder for
+	// item id, or zero.
+	standingAmount(id int, bidder string) (Money, error)
+
+	// winningBids returns the winning bids for item id, highest first.
+	winningBids(id int) ([]WinningBid, error)
+
+	setMaxBid(id int, bidder string, amount Money) error
+	addBid(id int, created time.Time, bidder string, amount Money, units int, buyNow bool) error
+	extendItem(id int, extendedTo time.Time) error
+	sellItem(id int, soldAt time.Time) error
+
+	addPledge(id int, created time.Time, pledger string, amount Money) error
+
+	// pledgeTotal returns the total of the pledges for item id.
+	pledgeTotal(id int) (Money, error)
+}
+
+// placeBid places a bid of bidAmount for each of units units of item id for
+// userName at bidTime using tx, following the rules of the placeBid
+// procedure.
+func placeBid(tx bidTx, bidTime time.Time, id int, bidAmount Money, units int, userName string) (BidResult, error) {
+	item, auction, err := tx.item(id)
+	if errors.Is(err, ErrNotFound) {
+		return BidResult{Message: "No such item"}, nil
+	}
+	if err != nil {
+		return BidResult{}, err
+	}
+
+	hasBid := item.UnitsBid > 0
+	result := BidResult{
+		PriorBidder: item.Bidder,
+		HighBidder:  item.Bidder,
+		CurrentBid:  item.CurrentBid,
+	}
+
+	// maximum of the current high bidder, which is at least the current bid
+	curMax := item.CurrentBid
+	if hasBid {
+		amount, err := tx.maxBid(id, item.Bidder)
+		if err != nil {
+			return BidResult{}, err
+		}
+		curMax = maxMoney(curMax, amount)
+	}
+
+	switch {
+	case item.IsPledge():
+		result.Message = "Pledge only item"
+	case item.OpeningBid.IsZero():
+		result.Message = "Display only item"
+	case item.Sold():
+		result.Message = "Item already sold"
+	case bidTime.Before(auction.ItemOpensAt(item)):
+		result.Message = "Bidding has not started"
+	case !bidTime.Before(auction.ItemClosesAt(item)):
+		result.Message = "Bidding has closed"
+	case units < 1 || units > item.Quantity:
+		result.Message = "Invalid quantity"
+	case item.IsSealed():
+		err = placeSealedBid(tx, bidTime, item, bidAmount, userName, &result)
+	case item.MultiQuantity():
+		err = placeUnitsBid(tx, bidTime, item, auction, bidAmount, units, userName, &result)
+	case hasBid && item.Bidder == userName:
+		// high bidder is raising their maximum, price stays the same
+		if bidAmount.Cmp(curMax) <= 0 {
+			result.Message = "Maximum bid must be higher"
+			break
+		}
+
+		err = tx.setMaxBid(id, userName, bidAmount)
+		if err != nil {
+			break
+		}
+
+		// raise the current bid if the new maximum reaches the reserve
+		highAmount := maxMoney(item.CurrentBid, item.reserveFor(bidAmount))
+		if highAmount.Cmp(item.CurrentBid) > 0 {
+			err = tx.addBid(id, bidTime, userName, highAmount, 1, false)
+			if err != nil {
+				break
+			}
+		}
+
+		result.CurrentBid = highAmount
+		result.BidPlaced = true
+		result.Message = "Maximum bid raised"
+	default:
+		err = placeAutoBid(tx, bidTime, item, auction, curMax, bidAmount, userName, &result)
+	}
+	if err != nil {
+		return BidResult{}, err
+	}
+
+	// bids on a sealed item are not revealed
+	if item.IsSealed() {
+		result.PriorBidder = ""
+		result.HighBidder = ""
+		result.CurrentBid = Money{}
+	}
+
+	return result, nil
+}
+
+// placeSealedBid places a bid on a sealed item, which must exceed the
+// opening bid and the prior bid of the bidder.
+func placeSealedBid(tx bidTx, bidTime time.Time, item Item, bidAmount Money, userName string, result *BidResult) error {
+	bidderAmount, err := tx.standingAmount(item.ID, userName)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case bidAmount.Cmp(item.OpeningBid) < 0:
+		result.Message = "Bid too low"
+		return nil
+	case bidAmount.Cmp(bidderAmount) <= 0:
+		result.Message = "Bid must be higher"
+		return nil
+	}
+
+	err = tx.setMaxBid(item.ID, userName, bidAmount)
+	if err != nil {
+		return err
+	}
+	err = tx.addBid(item.ID, bidTime, userName, bidAmount, 1, false)
+	if err != nil {
+		return err
+	}
+
+	result.BidPlaced = true
+	result.Message = "Bid placed"
+	if !bidderAmount.IsZero() {
+		result.Message = "Bid raised"
+	}
+
+	return nil
+}
+
+// placeUnitsBid places a bid for units of an item with a quantity greater
+// than one, which is not bid automatically.
+func placeUnitsBid(tx bidTx, bidTime time.Time, item Item, auction Auction, bidAmount Money, units int, userName string, result *BidResult) error {
+	// standing bid of the bidder, which a new bid must exceed
+	bidderAmount, err := tx.standingAmount(item.ID, userName)
+	if err != nil {
+		return err
+	}
+
+	minAmount := item.OpeningBid
+	if item.UnitsBid >= item.Quantity {
+		minAmount = item.CurrentBid.Add(item.BidIncrement(item.CurrentBid))
+	}
+
+	if bidAmount.Cmp(minAmount) < 0 || bidAmount.Cmp(bidderAmount) <= 0 {
+		result.Message = "Bid too low"
+		return nil
+	}
+
+	// the lowest winning bidder, other than the new bidder, is the first
+	// to lose a unit
+	before, err := tx.winningBids(item.ID)
+	if err != nil {
+		return err
+	}
+	priorBidder := ""
+	for _, bid := range before {
+		if bid.Bidder != userName {
+			priorBidder = bid.Bidder
+		}
+	}
+
+	err = tx.addBid(item.ID, bidTime, userName, bidAmount, units, false)
+	if err != nil {
+		return err
+	}
+
+	after, err := tx.winningBids(item.ID)
+	if err != nil {
+		return err
+	}
+
+	// only a bidder who lost a unit is the prior bidder
+	if winningUnits(after, priorBidder) >= winningUnits(before, priorBidder) {
+		priorBidder = ""
+	}
+
+	result.PriorBidder = priorBidder
+	result.HighBidder = userName
+	result.CurrentBid = after[len(after)-1].Amount
+	result.BidPlaced = true
+	result.Message = "Bid placed"
+
+	return extendForLateBid(tx, item, auction, bidTime)
+}
+
+// placeAutoBid places a bid of up to bidAmount against the current high
+// bidder, whose maximum is curMax, bidding on behalf of each bidder.
+func placeAutoBid(tx bidTx, bidTime time.Time, item Item, auction Auction, curMax, bidAmount Money, userName string, result *BidResult) error {
+	hasBid := item.UnitsBid > 0
+
+	minAmount := item.OpeningBid
+	if hasBid {
+		minAmount = item.CurrentBid.Add(item.BidIncrement(item.CurrentBid))
+	}
+
+	if bidAmount.Cmp(minAmount) < 0 {
+		result.Message = "Bid too low"
+		return nil
+	}
+
+	err := tx.setMaxBid(item.ID, userName, bidAmount)
+	if err != nil {
+		return err
+	}
+
+	// automatic bids are recorded just after the bid that caused them
+	nextTime := bidTime.Add(time.Microsecond)
+
+	// bids are recorded in order, since a tie goes to the earliest bid
+	type bid struct {
+		created time.Time
+		bidder  string
+		amount  Money
+	}
+	var bids []bid
+
+	switch {
+	case !hasBid:
+		// first bid is placed at the opening bid
+		result.HighBidder = userName
+		result.CurrentBid = maxMoney(item.OpeningBid, item.reserveFor(bidAmount))
+
+		bids = append(bids, bid{bidTime, userName, result.CurrentBid})
+
+		result.Message = "Bid placed"
+	case bidAmount.Cmp(curMax) > 0:
+		// current bidder is bid up to their maximum and then outbid
+		if curMax.Cmp(item.CurrentBid) > 0 {
+			bids = append(bids, bid{bidTime, item.Bidder, curMax})
+		}
+
+		result.HighBidder = userName
+		result.CurrentBid = maxMoney(
+			minMoney(bidAmount, curMax.Add(item.BidIncrement(curMax))),
+			item.reserveFor(bidAmount))
+
+		bids = append(bids, bid{nextTime, userName, result.CurrentBid})
+
+		result.Message = "Bid placed"
+	default:
+		// current bidder automatically outbids the new bid
+		result.CurrentBid = maxMoney(
+			minMoney(curMax, bidAmount.Add(item.BidIncrement(bidAmount))),
+			item.reserveFor(curMax))
+
+		if result.CurrentBid.Cmp(bidAmount) == 0 {
+			// tie goes to the current bidder, so record their bid first
+			bids = append(bids,
+				bid{bidTime, item.Bidder, result.CurrentBid},
+				bid{nextTime, userName, bidAmount})
+		} else {
+			bids = append(bids,
+				bid{bidTime, userName, bidAmount},
+				bid{nextTime, item.Bidder, result.CurrentBid})
+		}
+
+		result.Message = "Outbid by automatic bid"
+	}
+
+	for _, b := range bids {
+		err = tx.addBid(item.ID, b.created, b.bidder, b.amount, 1, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	result.BidPlaced = true
+
+	return extendForLateBid(tx, item, auction, bidTime)
+}
+
+// extendForLateBid extends the closing time of item for a late bid at
+// bidTime to prevent sniping.
+func extendForLateBid(tx bidTx, item Item, auction Auction, bidTime time.Time) error {
+	closeTime := auction.ItemClosesAt(item)
+	if auction.SoftCloseWindow <= 0 ||
+		bidTime.Before(closeTime.Add(-auction.SoftCloseWindow)) {
+		return nil
+	}
+
+	extendedTo := bidTime.Add(auction.SoftCloseExtension)
+	if extendedTo.Before(closeTime) {
+		extendedTo = closeTime
+	}
+
+	return tx.extendItem(item.ID, extendedTo)
+}
+
+// buyNow buys item id for userName at bidTime using tx, following the rules
+// of the buyNow procedure.
+func buyNow(tx bidTx, bidTime time.Time, id int, userName string) (BidResult, error) {
+	item, auction, err := tx.item(id)
+	if errors.Is(err, ErrNotFound) {
+		return BidResult{Message: "No such item"}, nil
+	}
+	if err != nil {
+		return BidResult{}, err
+	}
+
+	result := BidResult{
+		PriorBidder: item.Bidder,
+		HighBidder:  item.Bidder,
+		CurrentBid:  item.CurrentBid,
+	}
+
+	// compare in hundredths of a cent so the cutoff is exact
+	cutoff := item.BuyNowPrice.Mul(int64(auction.BuyNowCutoff))
+
+	switch {
+	case item.IsPledge():
+		result.Message = "Pledge only item"
+	case item.OpeningBid.IsZero():
+		result.Message = "Display only item"
+	case item.Sold():
+		result.Message = "Item already sold"
+	case bidTime.Before(auction.ItemOpensAt(item)):
+		result.Message = "Bidding has not started"
+	case !bidTime.Before(auction.ItemClosesAt(item)):
+		result.Message = "Bidding has closed"
+	case item.BuyNowPrice.IsZero() || item.MultiQuantity() ||
+		item.CurrentBid.Mul(100).Cmp(cutoff) >= 0:
+		result.Message = "Buy now not available"
+	default:
+		err = tx.addBid(id, bidTime, userName, item.BuyNowPrice, 1, true)
+		if err != nil {
+			return BidResult{}, err
+		}
+
+		err = tx.sellItem(id, bidTime)
+		if err != nil {
+			return BidResult{}, err
+		}
+
+		result.HighBidder = userName
+		result.CurrentBid = item.BuyNowPrice
+		result.BidPlaced = true
+		result.Message = "Item bought"
+	}
+
+	return result, nil
+}
+
+// placePledge pledges amount to item id for userName at pledgeTime using
+// tx, following the rules of the placePledge procedure.
+func placePledge(tx bidTx, pledgeTime time.Time, id int, amount Money, userName string) (PledgeResult, error) {
+	var result PledgeResult
+
+	item, auction, err := tx.item(id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		result.Message = "No such item"
+	case err != nil:
+		return result, err
+	case !item.IsPledge():
+		result.Message = "Not a pledge item"
+	case amount.Cents <= 0:
+		result.Message = "Pledge too low"
+	case pledgeTime.Before(auction.ItemOpensAt(item)):
+		result.Message = "Pledging has not started"
+	case !pledgeTime.Before(auction.ItemClosesAt(item)):
+		result.Message = "Pledging has closed"
+	default:
+		err = tx.addPledge(id, pledgeTime, userName, amount)
+		if err != nil {
+			return result, err
+		}
+
+		result.Pledged = true
+		result.Message = "Pledge placed"
+	}
+
+	result.Total, err = tx.pledgeTotal(id)
+	if err != nil {
+		return PledgeResult{}, err
+	}
+
+	return result, nil
+}
+
+// winningUnits returns the units won by bidder in bids.
+func winningUnits(bids []WinningBid, bidder string) int {
+	units := 0
+	for _, bid := range bids {
+		if bid.Bidder == bidder {
+			units += bid.Units
+		}
+	}
+	return units
+}
+
+// reserveFor returns the reserve price if maxBid reaches it, which is the
+// least the current bid is raised to, or else zero.
+func (item Item) reserveFor(maxBid Money) Money {
+	if maxBid.Cmp(item.ReservePrice) >= 0 {
+		return item.ReservePrice
+	}
+	return Money{}
+}
+
+// minMoney returns the lesser of a and b.
+func minMoney(a, b Money) Money {
+	if b.Cmp(a) < 0 {
+		return b
+	}
+	return a
+}
+
+// maxMoney returns the greater of a and b.
+func maxMoney(a, b Money) Money {
+	if b.Cmp(a) > 0 {
+		return b
+	}
+	return a
+}
@@ -17,6 +17,7 @@ type BidsPageData struct {
 	Message string
 	User    webauth.User
 	Items   []ItemWithBids
+	Auction Auction
 }
 
 // BidsHandler displays all of the bids.
@@ -43,7 +44,12 @@ func (app *BidApp) BidsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	itemsWithBids, err := app.BidDB.GetItemsWithBids()
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
+	itemsWithBids, err := app.BidDB.GetItemsWithBids(auction.ID)
 	if err != nil {
 		logger.Error("failed to get items with bids", "err", err)
 		webutil.RespondWithError(w, http.StatusInternalServerError)
@@ -51,12 +57,27 @@ func (app *BidApp) BidsHandler(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	// only show own bids on sealed items until they close
+	hidden, err := app.hiddenItemIDs(auction, user)
+	if err != nil {
+		logger.Error("failed to get hidden items", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+	for i := range itemsWithBids {
+		if hidden[itemsWithBids[i].ID] {
+			itemsWithBids[i].Bids = OwnBids(itemsWithBids[i].Bids, user.Username)
+		}
+	}
+	itemsWithBids = inCurrency(itemsWithBids, auction.Currency)
+
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "bids.html",
 		BidsPageData{
 			Title:   app.Cfg.App.Name,
 			Message: "",
 			User:    user,
 			Items:   itemsWithBids,
+			Auction: auction,
 		})
 	if err != nil {
 		logger.Error("unable to RenderTemplate", "err", err)
@@ -0,0 +1,1035 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnixon67/webapp/webapp"
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/bnixon67/webapp/webutil"
+)
+
+// storeSeeder is a BidStore with the methods used to seed it for tests.
+type storeSeeder interface {
+	BidStore
+	SetConfigItem(config ConfigItem)
+	SetUser(userName, fullName, email string)
+	SetIncrements(name string, increments []Increment)
+}
+
+// storesForTest returns each kind of BidStore seeded by seedStoreForTest.
+// A store that needs a database server skips the test if it is not
+// available.
+var storesForTest = map[string]func(t *testing.T) BidStore{
+	"MemStore": func(t *testing.T) BidStore { return memStoreForTest(t) },
+	"SQLite":   func(t *testing.T) BidStore { return sqliteDBForTest(t) },
+	"Postgres": func(t *testing.T) BidStore { return postgresDBForTest(t) },
+}
+
+// forEachStore runs f as a subtest for each of storesForTest.
+func forEachStore(t *testing.T, f func(t *testing.T, store BidStore)) {
+	for name, newStore := range storesForTest {
+		t.Run(name, func(t *testing.T) {
+			f(t, newStore(t))
+		})
+	}
+}
+
+// sqlSeeder is a BidStore that uses sqlDB, such as a SQLiteDB, with the
+// methods of storeSeeder, which fail the test on an error.
+type sqlSeeder struct {
+	BidStore
+	sqlDB *webauth.AuthDB
+	t     *testing.T
+}
+
+func (s sqlSeeder) SetConfigItem(config ConfigItem) {
+	_, err := s.sqlDB.Exec("INSERT INTO config(name, value, value_type) VALUES (?, ?, ?)", config.Name, config.Value, config.ValueType)
+	if err != nil {
+		s.t.Fatalf("SetConfigItem(%q) failed: %v", config.Name, err)
+	}
+}
+
+func (s sqlSeeder) SetUser(userName, fullName, email string) {
+	_, err := s.sqlDB.Exec("INSERT INTO users(username, fullName, email, hashedPassword) VALUES (?, ?, ?, '')", userName, fullName, email)
+	if err != nil {
+		s.t.Fatalf("SetUser(%q) failed: %v", userName, err)
+	}
+}
+
+func (s sqlSeeder) SetIncrements(name string, increments []Increment) {
+	for _, increment := range increments {
+		_, err := s.sqlDB.Exec("INSERT INTO increments(schedule, fromAmount, increment) VALUES (?, ?, ?)", name, increment.From, increment.Amount)
+		if err != nil {
+			s.t.Fatalf("SetIncrements(%q) failed: %v", name, err)
+		}
+	}
+}
+
+// memStoreForTest returns a MemStore seeded by seedStoreForTest.
+func memStoreForTest(t *testing.T) *MemStore {
+	store := NewMemStore()
+	seedStoreForTest(t, store)
+	return store
+}
+
+// seedStoreForTest adds an open auction that has an item of each kind, and
+// an auction that is about to close, to store.
+func seedStoreForTest(t *testing.T, store storeSeeder) {
+	now := time.Now().UTC()
+
+	auctions := []Auction{
+		{
+			Slug: "test", Name: "Test Auction", TimeZone: "America/Chicago",
+			AuctionTimes: AuctionTimes{
+				AuctionStart: now.Add(-time.Hour),
+				AuctionEnd:   now.Add(time.Hour),
+			},
+			BuyNowCutoff: 75,
+		},
+		{
+			Slug: "soft", Name: "Soft Close Auction", TimeZone: "America/Chicago",
+			AuctionTimes: AuctionTimes{
+				AuctionStart:       now.Add(-time.Hour),
+				AuctionEnd:         now.Add(time.Minute),
+				SoftCloseWindow:    2 * time.Minute,
+				SoftCloseExtension: 5 * time.Minute,
+			},
+			BuyNowCutoff: 100,
+		},
+	}
+	for _, auction := range auctions {
+		_, err := store.CreateAuction(auction)
+		if err != nil {
+			t.Fatalf("CreateAuction(%q) failed: %v", auction.Slug, err)
+		}
+	}
+
+	store.SetConfigItem(ConfigItem{Name: "current_auction", Value: "test", ValueType: "string"})
+	store.SetUser("test", "Test User", "test@user")
+	store.SetUser("admin", "Admin User", "admin@user")
+	store.SetIncrements("standard", []Increment{
+		{From: Cents(10000), Amount: Cents(1000)},
+		{From: Cents(0), Amount: Cents(500)},
+	})
+
+	past := now.Add(-2 * time.Hour)
+	items := []Item{
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Bid Test", OpeningBid: Cents(1000), MinBidIncr: Cents(200)},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Display Only Test"},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Reserve Test", OpeningBid: Cents(1000), MinBidIncr: Cents(100), ReservePrice: Cents(5000)},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Quantity Test", OpeningBid: Cents(1000), MinBidIncr: Cents(500), Quantity: 3},
+		{AuctionID: 1, ItemType: ItemTypeSealed, Title: "Sealed Test", OpeningBid: Cents(1000), MinBidIncr: Cents(100)},
+		{AuctionID: 1, ItemType: ItemTypePledge, Title: "Pledge Test", PledgeLevels: []Money{Cents(10000), Cents(25000)}},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Buy Now Test", OpeningBid: Cents(1000), MinBidIncr: Cents(100), BuyNowPrice: Cents(10000)},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Closed Item Test", OpeningBid: Cents(1000), MinBidIncr: Cents(100), OpensAt: &past, ClosesAt: &past},
+		{AuctionID: 1, ItemType: ItemTypeAuction, Title: "Item Schedule Test", OpeningBid: Cents(5000), MinBidIncr: Cents(100), IncrSchedule: "standard"},
+		{AuctionID: 2, ItemType: ItemTypeAuction, Title: "Soft Close Test", OpeningBid: Cents(1000), MinBidIncr: Cents(100)},
+	}
+	for _, item := range items {
+		item.Description = "Item to test MemStore"
+		item.Artist = "Artist"
+		item.ImageFileName = "File"
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.ClosesAt != nil {
+			// closes after it opens, but before now
+			closesAt := item.ClosesAt.Add(time.Minute)
+			item.ClosesAt = &closesAt
+		}
+
+		_, err := store.CreateItem(item)
+		if err != nil {
+			t.Fatalf("CreateItem(%q) failed: %v", item.Title, err)
+		}
+	}
+}
+
+// storeAppForTest returns an app that uses store for auctions, items, and
+// bids, without a database for users.
+func storeAppForTest(t *testing.T, store BidStore) *BidApp {
+	images := &LocalImageStore{Dir: t.TempDir()}
+
+	tmpl, err := webutil.TemplatesWithFuncs("html/*.html", templateFuncs(images))
+	if err != nil {
+		t.Fatalf("Error initializing templates: %v", err)
+	}
+
+	app := &BidApp{
+		AuthApp:     &webauth.AuthApp{WebApp: &webapp.WebApp{Tmpl: tmpl}},
+		BidDB:       store,
+		Images:      images,
+		ImageWidths: DefaultImageWidths,
+		ImageLimits: DefaultImageLimits,
+		ImageQueue:  NewImageQueue(store, images, &LocalImageStore{Dir: t.TempDir()}, DefaultImageWidths, DefaultImageLimits, ImageQueueSize),
+		Updates:     NewBroadcaster(),
+	}
+	app.Cfg.App.Name = "Test"
+
+	err = app.ConfigAuction()
+	if err != nil {
+		t.Fatalf("cannot config auction: %v", err)
+	}
+
+	return app
+}
+
+func TestBidStorePlaceBid(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		cases := []struct {
+			id        int
+			bidAmount Money
+			bidder    string
+			want      BidResult
+		}{
+			{
+				id: 99, bidAmount: Cents(1000), bidder: "test",
+				want: BidResult{Message: "No such item"},
+			},
+			{
+				id: 2, bidAmount: Cents(1000), bidder: "test",
+				want: BidResult{Message: "Display only item"},
+			},
+			{
+				id: 6, bidAmount: Cents(1000), bidder: "test",
+				want: BidResult{Message: "Pledge only item"},
+			},
+			{
+				id: 8, bidAmount: Cents(1000), bidder: "test",
+				want: BidResult{Message: "Bidding has closed"},
+			},
+			{
+				id: 1, bidAmount: Cents(500), bidder: "test",
+				want: BidResult{Message: "Bid too low"},
+			},
+			{
+				// first bid is placed at the opening bid
+				id: 1, bidAmount: Cents(2000), bidder: "test",
+				want: BidResult{
+					BidPlaced:  true,
+					Message:    "Bid placed",
+					HighBidder: "test",
+					CurrentBid: Cents(1000),
+				},
+			},
+			{
+				id: 1, bidAmount: Cents(2000), bidder: "test",
+				want: BidResult{
+					Message:     "Maximum bid must be higher",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1000),
+				},
+			},
+			{
+				id: 1, bidAmount: Cents(3000), bidder: "test",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Maximum bid raised",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1000),
+				},
+			},
+			{
+				// below the current bid plus the increment
+				id: 1, bidAmount: Cents(1100), bidder: "admin",
+				want: BidResult{
+					Message:     "Bid too low",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1000),
+				},
+			},
+			{
+				id: 1, bidAmount: Cents(1500), bidder: "admin",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Outbid by automatic bid",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1700),
+				},
+			},
+			{
+				// tie goes to the earlier maximum
+				id: 1, bidAmount: Cents(3000), bidder: "admin",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Outbid by automatic bid",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(3000),
+				},
+			},
+			{
+				id: 1, bidAmount: Cents(4000), bidder: "admin",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Bid placed",
+					PriorBidder: "test",
+					HighBidder:  "admin",
+					CurrentBid:  Cents(3200),
+				},
+			},
+			{
+				// maximum below the reserve
+				id: 3, bidAmount: Cents(2000), bidder: "test",
+				want: BidResult{
+					BidPlaced:  true,
+					Message:    "Bid placed",
+					HighBidder: "test",
+					CurrentBid: Cents(1000),
+				},
+			},
+			{
+				// maximum above the reserve raises the bid to the reserve
+				id: 3, bidAmount: Cents(6000), bidder: "admin",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Bid placed",
+					PriorBidder: "test",
+					HighBidder:  "admin",
+					CurrentBid:  Cents(5000),
+				},
+			},
+			{
+				id: 3, bidAmount: Cents(7000), bidder: "test",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Bid placed",
+					PriorBidder: "admin",
+					HighBidder:  "test",
+					CurrentBid:  Cents(6100),
+				},
+			},
+		}
+
+		for _, tc := range cases {
+			got, err := store.PlaceBid(tc.id, tc.bidAmount, tc.bidder)
+			if err != nil {
+				t.Errorf("PlaceBid(%d, %v, %q) got err '%v' want '%v'",
+					tc.id, tc.bidAmount, tc.bidder, err, nil)
+			}
+			if got != tc.want {
+				t.Errorf("PlaceBid(%d, %v, %q)\n got %s\nwant %s",
+					tc.id, tc.bidAmount, tc.bidder,
+					AsJson(got), AsJson(tc.want))
+			}
+		}
+
+		item, err := store.GetItem(1)
+		if err != nil {
+			t.Fatalf("GetItem(1) failed: %v", err)
+		}
+		if item.Bidder != "admin" || item.CurrentBid != Cents(3200) || item.MinBid != Cents(3400) {
+			t.Errorf("GetItem(1) got %s", AsJson(item))
+		}
+
+		bids, err := store.GetBidsForItem(1)
+		if err != nil {
+			t.Fatalf("GetBidsForItem(1) failed: %v", err)
+		}
+		var gotBids []string
+		for _, bid := range bids {
+			gotBids = append(gotBids, fmt.Sprintf("%s %v", bid.Bidder, bid.Amount))
+		}
+		wantBids := []string{"admin 32.00", "admin 30.00", "test 30.00", "test 17.00", "admin 15.00", "test 10.00"}
+		if !reflect.DeepEqual(gotBids, wantBids) {
+			t.Errorf("GetBidsForItem(1) got %q want %q", gotBids, wantBids)
+		}
+
+		for bidder, want := range map[string]Money{"test": Cents(3000), "admin": Cents(4000), "other": {}} {
+			got, err := store.GetMaxBid(1, bidder)
+			if err != nil || got != want {
+				t.Errorf("GetMaxBid(1, %q) got %v err '%v' want %v", bidder, got, err, want)
+			}
+		}
+	})
+}
+
+func TestBidStorePlaceUnitsBid(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		cases := []struct {
+			id        int
+			bidAmount Money
+			units     int
+			bidder    string
+			want      BidResult
+		}{
+			{
+				id: 4, bidAmount: Cents(1000), units: 4, bidder: "test",
+				want: BidResult{Message: "Invalid quantity"},
+			},
+			{
+				// single unit item
+				id: 1, bidAmount: Cents(2000), units: 2, bidder: "test",
+				want: BidResult{Message: "Invalid quantity"},
+			},
+			{
+				id: 4, bidAmount: Cents(1000), units: 1, bidder: "test",
+				want: BidResult{
+					BidPlaced:  true,
+					Message:    "Bid placed",
+					HighBidder: "test",
+					CurrentBid: Cents(1000),
+				},
+			},
+			{
+				// unbid units at the opening bid, so no one loses a unit
+				id: 4, bidAmount: Cents(1200), units: 2, bidder: "admin",
+				want: BidResult{
+					BidPlaced:  true,
+					Message:    "Bid placed",
+					HighBidder: "admin",
+					CurrentBid: Cents(1000),
+				},
+			},
+			{
+				// must beat the lowest winning bid by the increment
+				id: 4, bidAmount: Cents(1400), units: 1, bidder: "test",
+				want: BidResult{
+					Message:     "Bid too low",
+					PriorBidder: "test",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1000),
+				},
+			},
+			{
+				// takes a unit from admin
+				id: 4, bidAmount: Cents(1500), units: 2, bidder: "test",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Bid placed",
+					PriorBidder: "admin",
+					HighBidder:  "test",
+					CurrentBid:  Cents(1200),
+				},
+			},
+		}
+
+		for _, tc := range cases {
+			got, err := store.PlaceUnitsBid(tc.id, tc.bidAmount, tc.units, tc.bidder)
+			if err != nil {
+				t.Errorf("PlaceUnitsBid(%d, %v, %d, %q) got err '%v' want '%v'",
+					tc.id, tc.bidAmount, tc.units, tc.bidder, err, nil)
+			}
+			if got != tc.want {
+				t.Errorf("PlaceUnitsBid(%d, %v, %d, %q)\n got %s\nwant %s",
+					tc.id, tc.bidAmount, tc.units, tc.bidder,
+					AsJson(got), AsJson(tc.want))
+			}
+		}
+
+		bids, err := store.GetWinningBids(4)
+		if err != nil {
+			t.Fatalf("GetWinningBids(4) failed: %v", err)
+		}
+		var gotBids []string
+		for _, bid := range bids {
+			gotBids = append(gotBids, fmt.Sprintf("%s %v %d", bid.Bidder, bid.Amount, bid.Units))
+		}
+		wantBids := []string{"test 15.00 2", "admin 12.00 1"}
+		if !reflect.DeepEqual(gotBids, wantBids) {
+			t.Errorf("GetWinningBids(4) got %q want %q", gotBids, wantBids)
+		}
+
+		// one winner for each unit
+		winners, err := store.GetWinners(1)
+		if err != nil {
+			t.Fatalf("GetWinners(1) failed: %v", err)
+		}
+		var gotWinners []string
+		for _, winner := range winners {
+			gotWinners = append(gotWinners, fmt.Sprintf("%d %d %s %s %v", winner.ID, winner.Unit, winner.ModifiedBy, winner.FullName, winner.CurrentBid))
+		}
+		wantWinners := []string{"4 1 test Test User 15.00", "4 2 test Test User 15.00", "4 3 admin Admin User 12.00"}
+		if !reflect.DeepEqual(gotWinners, wantWinners) {
+			t.Errorf("GetWinners(1) got %q want %q", gotWinners, wantWinners)
+		}
+	})
+}
+
+func TestBidStorePlaceSealedBid(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		// other bids are never returned for a sealed item
+		cases := []struct {
+			bidAmount Money
+			bidder    string
+			want      BidResult
+		}{
+			{bidAmount: Cents(500), bidder: "test", want: BidResult{Message: "Bid too low"}},
+			{bidAmount: Cents(2000), bidder: "test", want: BidResult{BidPlaced: true, Message: "Bid placed"}},
+			{bidAmount: Cents(1500), bidder: "admin", want: BidResult{BidPlaced: true, Message: "Bid placed"}},
+			{bidAmount: Cents(1500), bidder: "admin", want: BidResult{Message: "Bid must be higher"}},
+			{bidAmount: Cents(2500), bidder: "admin", want: BidResult{BidPlaced: true, Message: "Bid raised"}},
+		}
+
+		for _, tc := range cases {
+			got, err := store.PlaceBid(5, tc.bidAmount, tc.bidder)
+			if err != nil || got != tc.want {
+				t.Errorf("PlaceBid(5, %v, %q) got %s err '%v' want %s",
+					tc.bidAmount, tc.bidder, AsJson(got), err, AsJson(tc.want))
+			}
+		}
+
+		// the highest bid wins
+		item, err := store.GetItem(5)
+		if err != nil {
+			t.Fatalf("GetItem(5) failed: %v", err)
+		}
+		if item.Bidder != "admin" || item.CurrentBid != Cents(2500) {
+			t.Errorf("GetItem(5) got %s", AsJson(item))
+		}
+	})
+}
+
+func TestBidStoreBuyNow(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		_, err := store.PlaceBid(7, Cents(8000), "test")
+		if err != nil {
+			t.Fatalf("PlaceBid(7) failed: %v", err)
+		}
+
+		cases := []struct {
+			id     int
+			bidder string
+			want   BidResult
+		}{
+			{id: 1, bidder: "test", want: BidResult{Message: "Buy now not available"}},
+			{
+				id: 7, bidder: "admin",
+				want: BidResult{
+					BidPlaced:   true,
+					Message:     "Item bought",
+					PriorBidder: "test",
+					HighBidder:  "admin",
+					CurrentBid:  Cents(10000),
+				},
+			},
+			{
+				id: 7, bidder: "test",
+				want: BidResult{
+					Message:     "Item already sold",
+					PriorBidder: "admin",
+					HighBidder:  "admin",
+					CurrentBid:  Cents(10000),
+				},
+			},
+		}
+
+		for _, tc := range cases {
+			got, err := store.BuyNow(tc.id, tc.bidder)
+			if err != nil || got != tc.want {
+				t.Errorf("BuyNow(%d, %q) got %s err '%v' want %s",
+					tc.id, tc.bidder, AsJson(got), err, AsJson(tc.want))
+			}
+		}
+
+		// bidding is closed once bought
+		got, err := store.PlaceBid(7, Cents(20000), "test")
+		if err != nil || got.BidPlaced || got.Message != "Item already sold" {
+			t.Errorf("PlaceBid after BuyNow got %s err '%v'", AsJson(got), err)
+		}
+
+		winners, err := store.GetWinners(1)
+		if err != nil {
+			t.Fatalf("GetWinners(1) failed: %v", err)
+		}
+		if len(winners) != 1 || !winners[0].BuyNow || winners[0].ModifiedBy != "admin" {
+			t.Errorf("GetWinners(1) got %s", AsJson(winners))
+		}
+	})
+}
+
+func TestBidStorePledge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		cases := []struct {
+			id      int
+			amount  Money
+			pledger string
+			want    PledgeResult
+		}{
+			{id: 99, amount: Cents(10000), pledger: "test", want: PledgeResult{Message: "No such item"}},
+			{id: 1, amount: Cents(10000), pledger: "test", want: PledgeResult{Message: "Not a pledge item"}},
+			{id: 6, amount: Cents(0), pledger: "test", want: PledgeResult{Message: "Pledge too low"}},
+			{
+				id: 6, amount: Cents(10000), pledger: "test",
+				want: PledgeResult{Pledged: true, Message: "Pledge placed", Total: Cents(10000)},
+			},
+			{
+				id: 6, amount: Cents(4250), pledger: "admin",
+				want: PledgeResult{Pledged: true, Message: "Pledge placed", Total: Cents(14250)},
+			},
+		}
+
+		for _, tc := range cases {
+			got, err := store.Pledge(tc.id, tc.amount, tc.pledger)
+			if err != nil || got != tc.want {
+				t.Errorf("Pledge(%d, %v, %q) got %s err '%v' want %s",
+					tc.id, tc.amount, tc.pledger, AsJson(got), err, AsJson(tc.want))
+			}
+		}
+
+		item, err := store.GetItem(6)
+		if err != nil {
+			t.Fatalf("GetItem(6) failed: %v", err)
+		}
+		if item.PledgeTotal != Cents(14250) || item.Pledges != 2 {
+			t.Errorf("GetItem(6) got %s", AsJson(item))
+		}
+
+		// one winner for each pledge, largest first
+		winners, err := store.GetWinners(1)
+		if err != nil {
+			t.Fatalf("GetWinners(1) failed: %v", err)
+		}
+		var got []string
+		for _, winner := range winners {
+			got = append(got, fmt.Sprintf("%s %v %t", winner.ModifiedBy, winner.CurrentBid, winner.Pledge))
+		}
+		want := []string{"test 100.00 true", "admin 42.50 true"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetWinners(1) got %q want %q", got, want)
+		}
+	})
+}
+
+func TestBidStoreBidIncrementSchedule(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		item, err := store.GetItem(9)
+		if err != nil {
+			t.Fatalf("GetItem(9) failed: %v", err)
+		}
+		want := []Increment{
+			{From: Cents(0), To: Cents(10000), Amount: Cents(500)},
+			{From: Cents(10000), Amount: Cents(1000)},
+		}
+		if !reflect.DeepEqual(item.Increments, want) {
+			t.Errorf("GetItem(9) got increments %s want %s",
+				AsJson(item.Increments), AsJson(want))
+		}
+
+		bids := []struct {
+			bidAmount Money
+			bidder    string
+			want      Money
+		}{
+			{bidAmount: Cents(20000), bidder: "test", want: Cents(5000)},
+			{bidAmount: Cents(5400), bidder: "admin", want: Cents(5000)},
+			{bidAmount: Cents(6000), bidder: "admin", want: Cents(6500)},
+			{bidAmount: Cents(12000), bidder: "admin", want: Cents(13000)},
+		}
+		for _, tc := range bids {
+			got, err := store.PlaceBid(9, tc.bidAmount, tc.bidder)
+			if err != nil || got.CurrentBid != tc.want {
+				t.Errorf("PlaceBid(9, %v, %q) got %s err '%v' want current bid %v",
+					tc.bidAmount, tc.bidder, AsJson(got), err, tc.want)
+			}
+		}
+
+		item, err = store.GetItem(9)
+		if err != nil {
+			t.Fatalf("GetItem(9) failed: %v", err)
+		}
+		if item.MinBid != Cents(14000) {
+			t.Errorf("GetItem(9) got minBid %v want 140.00", item.MinBid)
+		}
+	})
+}
+
+func TestBidStoreCreateAuction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+		now := time.Now().UTC().Truncate(time.Second)
+		auction := Auction{
+			Slug: "new", Name: "New Auction", TimeZone: "America/Chicago",
+			AuctionTimes: AuctionTimes{AuctionStart: now, AuctionEnd: now.Add(time.Hour)},
+			BuyNowCutoff: 100,
+		}
+
+		id, err := store.CreateAuction(auction)
+		if err != nil {
+			t.Fatalf("CreateAuction() failed: %v", err)
+		}
+
+		got, err := store.GetAuction("new")
+		if err != nil || got.ID != int(id) || got.Name != auction.Name || !got.AuctionEnd.Equal(auction.AuctionEnd) || got.Currency != DefaultCurrency {
+			t.Errorf("GetAuction() got %+v err '%v' want id %d %+v in %s", got, err, id, auction, DefaultCurrency)
+		}
+
+		euro := auction
+		euro.Slug, euro.Currency = "euro", "EUR"
+		_, err = store.CreateAuction(euro)
+		if err != nil {
+			t.Fatalf("CreateAuction(euro) failed: %v", err)
+		}
+		got, err = store.GetAuction("euro")
+		if err != nil || got.Currency != "EUR" {
+			t.Errorf("GetAuction(euro) got currency %q err '%v' want EUR", got.Currency, err)
+		}
+
+		_, err = store.CreateAuction(auction)
+		if !errors.Is(err, ErrCreateFailed) {
+			t.Errorf("CreateAuction() with duplicate slug got err '%v' want '%v'", err, ErrCreateFailed)
+		}
+
+		err = store.SetCurrentAuction("new")
+		if err != nil {
+			t.Fatalf("SetCurrentAuction() failed: %v", err)
+		}
+		ci, err := store.GetConfigItem("current_auction")
+		if err != nil || ci.Value != "new" {
+			t.Errorf("GetConfigItem() got %q err '%v' want %q", ci.Value, err, "new")
+		}
+
+		err = store.SetCurrentAuction("nosuch")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("SetCurrentAuction(nosuch) got err '%v' want '%v'", err, ErrNotFound)
+		}
+	})
+}
+
+func TestBidStoreSoftClose(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		auction, err := store.GetAuction("soft")
+		if err != nil {
+			t.Fatalf("GetAuction(soft) failed: %v", err)
+		}
+
+		_, err = store.PlaceBid(10, Cents(2000), "test")
+		if err != nil {
+			t.Fatalf("PlaceBid(10) failed: %v", err)
+		}
+
+		item, err := store.GetItem(10)
+		if err != nil {
+			t.Fatalf("GetItem(10) failed: %v", err)
+		}
+		minEnd := time.Now().Add(auction.SoftCloseExtension - time.Minute)
+		if end := auction.ItemClosesAt(item); end.Before(minEnd) {
+			t.Errorf("EndTime = %v, want after %v", end, minEnd)
+		}
+	})
+}
+
+func TestBidStoreItems(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		_, err := store.GetItem(99)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetItem(99) got err '%v' want '%v'", err, ErrNotFound)
+		}
+
+		_, err = store.CreateItem(Item{Title: "t"})
+		if !errors.Is(err, ErrInvalidItem) {
+			t.Errorf("CreateItem() got err '%v' want '%v'", err, ErrInvalidItem)
+		}
+
+		item, err := store.GetItem(1)
+		if err != nil {
+			t.Fatalf("GetItem(1) failed: %v", err)
+		}
+		item.Title = "Updated"
+		item.ReservePrice = Cents(2500)
+		rows, err := store.UpdateItem(item)
+		if err != nil || rows != 1 {
+			t.Errorf("UpdateItem(1) got %d err '%v' want 1", rows, err)
+		}
+		got, err := store.GetItem(1)
+		if err != nil || !reflect.DeepEqual(got, item) {
+			t.Errorf("GetItem(1) after UpdateItem\n got %s\nwant %s", AsJson(got), AsJson(item))
+		}
+
+		// an item can be updated without images, as it can be created
+		noImages := item
+		noImages.ImageFileName = ""
+		rows, err = store.UpdateItem(noImages)
+		if err != nil || rows != 1 {
+			t.Errorf("UpdateItem(1) without images got %d err '%v' want 1", rows, err)
+		}
+
+		item.ReservePrice = Cents(-100)
+		_, err = store.UpdateItem(item)
+		if !errors.Is(err, ErrInvalidItem) {
+			t.Errorf("UpdateItem() got err '%v' want '%v'", err, ErrInvalidItem)
+		}
+
+		items, err := store.GetItems(2)
+		if err != nil || len(items) != 1 || items[0].ID != 10 {
+			t.Errorf("GetItems(2) got %s err '%v'", AsJson(items), err)
+		}
+
+		_, err = store.GetAuction("none")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetAuction(none) got err '%v' want '%v'", err, ErrNotFound)
+		}
+
+		_, err = store.GetConfigItem("none")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetConfigItem(none) got err '%v' want '%v'", err, ErrNotFound)
+		}
+	})
+}
+
+func TestBidStoreItemImages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+
+		// an item without images in item_images has its image file name
+		item, err := store.GetItem(1)
+		if err != nil || !reflect.DeepEqual(item.Images, []string{"File"}) {
+			t.Errorf("GetItem(1) got images %q err '%v' want %q", item.Images, err, []string{"File"})
+		}
+
+		cases := []struct {
+			id        int
+			images    []string
+			wantFirst string
+			wantErr   error
+		}{
+			{1, []string{"b.jpg", "a.jpg", "c.jpg"}, "b.jpg", nil},
+			{1, []string{"c.jpg", "b.jpg"}, "c.jpg", nil},
+			{1, nil, "", nil},
+			{99, []string{"a.jpg"}, "", ErrNotFound},
+		}
+
+		for _, tc := range cases {
+			err := store.SetItemImages(tc.id, tc.images)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("SetItemImages(%d, %q) got err '%v' want '%v'", tc.id, tc.images, err, tc.wantErr)
+			}
+			if err != nil {
+				continue
+			}
+
+			item, err := store.GetItem(tc.id)
+			if err != nil || !reflect.DeepEqual(item.Images, tc.images) || item.ImageFileName != tc.wantFirst {
+				t.Errorf("GetItem(%d) after SetItemImages(%q) got images %q first %q err '%v'", tc.id, tc.images, item.Images, item.ImageFileName, err)
+			}
+		}
+	})
+}
+
+func TestBidStoreImageVariants(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+		a := []ImageVariant{
+			{VariantName("a.jpg", 960, ImageTypeJPEG), 960, ImageTypeJPEG},
+			{VariantName("a.jpg", 480, ImageTypeJPEG), 480, ImageTypeJPEG},
+		}
+		b := []ImageVariant{{VariantName("b.jpg", 480, ImageTypeWebP), 480, ImageTypeWebP}}
+
+		for name, variants := range map[string][]ImageVariant{"a.jpg": a, "b.jpg": b} {
+			err := store.SetImageVariants(name, variants)
+			if err != nil {
+				t.Fatalf("SetImageVariants(%q) failed: %v", name, err)
+			}
+		}
+
+		got, err := store.GetImageVariants([]string{"a.jpg", "b.jpg", "c.jpg"})
+		want := map[string][]ImageVariant{"a.jpg": {a[1], a[0]}, "b.jpg": b}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("GetImageVariants() got %v err '%v' want %v", got, err, want)
+		}
+
+		err = store.SetImageVariants("a.jpg", nil)
+		if err != nil {
+			t.Fatalf("SetImageVariants(a.jpg, nil) failed: %v", err)
+		}
+
+		got, err = store.GetImageVariants([]string{"a.jpg"})
+		if err != nil || len(got) != 0 {
+			t.Errorf("GetImageVariants() after removing got %v err '%v' want none", got, err)
+		}
+
+		got, err = store.GetImageVariants(nil)
+		if err != nil || len(got) != 0 {
+			t.Errorf("GetImageVariants(nil) got %v err '%v' want none", got, err)
+		}
+	})
+}
+
+func TestBidStoreImageStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+		statuses := map[string]ImageStatus{
+			"a.jpg": {State: ImagePending},
+			"b.jpg": {State: ImageFailed, Message: "bad image"},
+			"c.jpg": {State: ImagePending},
+		}
+		for name, status := range statuses {
+			err := store.SetImageStatus(name, status)
+			if err != nil {
+				t.Fatalf("SetImageStatus(%q) failed: %v", name, err)
+			}
+		}
+
+		err := store.SetImageStatus("c.jpg", ImageStatus{State: ImageReady})
+		if err != nil {
+			t.Fatalf("SetImageStatus(c.jpg) again failed: %v", err)
+		}
+
+		got, err := store.GetImageStatuses([]string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"})
+		want := map[string]ImageStatus{
+			"a.jpg": {State: ImagePending},
+			"b.jpg": {State: ImageFailed, Message: "bad image"},
+			"c.jpg": {State: ImageReady},
+		}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("GetImageStatuses() got %v err '%v' want %v", got, err, want)
+		}
+
+		pending, err := store.GetPendingImages()
+		if err != nil || !slices.Equal(pending, []string{"a.jpg"}) {
+			t.Errorf("GetPendingImages() got %q err '%v' want %q", pending, err, []string{"a.jpg"})
+		}
+
+		err = store.SetImageStatus("b.jpg", ImageStatus{})
+		if err != nil {
+			t.Fatalf("SetImageStatus(b.jpg, removed) failed: %v", err)
+		}
+		got, err = store.GetImageStatuses([]string{"b.jpg"})
+		if err != nil || len(got) != 0 {
+			t.Errorf("GetImageStatuses() after removing got %v err '%v' want none", got, err)
+		}
+
+		got, err = store.GetImageStatuses(nil)
+		if err != nil || len(got) != 0 {
+			t.Errorf("GetImageStatuses(nil) got %v err '%v' want none", got, err)
+		}
+	})
+}
+
+func TestBidStoreHandlers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+		app := storeAppForTest(t, store)
+
+		_, err := store.PlaceBid(1, Cents(2000), "test")
+		if err != nil {
+			t.Fatalf("PlaceBid(1) failed: %v", err)
+		}
+
+		err = store.SetImageVariants("File", []ImageVariant{{VariantName("File", 480, ImageTypeWebP), 480, ImageTypeWebP}})
+		if err != nil {
+			t.Fatalf("SetImageVariants() failed: %v", err)
+		}
+		srcset := `srcset="/images/variants/File.480w.webp 480w"`
+
+		cases := []struct {
+			name    string
+			handler http.HandlerFunc
+			target  string
+			status  int
+			inBody  string
+		}{
+			{"Gallery", app.GalleryHandler, "/gallery", http.StatusOK, "Bid Test"},
+			{"GalleryVariants", app.GalleryHandler, "/gallery", http.StatusOK, srcset},
+			{"Item", app.ItemHandler, "/item/1", http.StatusOK, "$10.00"},
+			{"ItemVariants", app.ItemHandler, "/item/1", http.StatusOK, srcset},
+			{"NoSuchItem", app.ItemHandler, "/item/99", http.StatusNotFound, "Not Found"},
+			{"Auctions", app.AuctionsHandler, "/auctions", http.StatusOK, "Soft Close Auction"},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+				w := httptest.NewRecorder()
+
+				tc.handler(w, r)
+
+				if w.Code != tc.status {
+					t.Errorf("got status %d want %d", w.Code, tc.status)
+				}
+				if !strings.Contains(w.Body.String(), tc.inBody) {
+					t.Errorf("got body %q want %q in body", w.Body, tc.inBody)
+				}
+			})
+		}
+
+		// a placeholder is shown until the image is processed
+		err = store.SetImageStatus("File", ImageStatus{State: ImagePending})
+		if err != nil {
+			t.Fatalf("SetImageStatus() failed: %v", err)
+		}
+		for target, handler := range map[string]http.HandlerFunc{"/gallery": app.GalleryHandler, "/item/1": app.ItemHandler} {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+			if !strings.Contains(w.Body.String(), `src="/placeholder.svg"`) || strings.Contains(w.Body.String(), srcset) {
+				t.Errorf("%s got body %q want placeholder without %q", target, w.Body, srcset)
+			}
+		}
+	})
+}
+
+func TestBidStoreHandlersCurrency(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store BidStore) {
+		now := time.Now().UTC().Truncate(time.Second)
+		auctionID, err := store.CreateAuction(Auction{
+			Slug: "euro", Name: "Euro Auction", TimeZone: "Europe/Berlin", Currency: "EUR",
+			AuctionTimes: AuctionTimes{AuctionStart: now.Add(-time.Hour), AuctionEnd: now.Add(time.Hour)},
+			BuyNowCutoff: 100,
+		})
+		if err != nil {
+			t.Fatalf("CreateAuction() failed: %v", err)
+		}
+		id, err := store.CreateItem(Item{AuctionID: int(auctionID), ItemType: ItemTypeAuction, Title: "Euro Item", Description: "Euro", Artist: "Artist", ImageFileName: "File", OpeningBid: Cents(1250), MinBidIncr: Cents(100), Quantity: 1})
+		if err != nil {
+			t.Fatalf("CreateItem() failed: %v", err)
+		}
+		err = store.SetCurrentAuction("euro")
+		if err != nil {
+			t.Fatalf("SetCurrentAuction() failed: %v", err)
+		}
+		_, err = store.PlaceBid(int(id), Cents(2000), "test")
+		if err != nil {
+			t.Fatalf("PlaceBid() failed: %v", err)
+		}
+
+		app := storeAppForTest(t, store)
+
+		cases := []struct {
+			name    string
+			handler http.HandlerFunc
+			target  string
+			inBody  []string
+		}{
+			{"Gallery", app.GalleryHandler, "/gallery", []string{"€12.50", `data-currency-symbol="€"`}},
+			{"Item", app.ItemHandler, fmt.Sprintf("/item/%d", id), []string{"€12.50", `data-currency-symbol="€"`}},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				tc.handler(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+				if w.Code != http.StatusOK {
+					t.Errorf("got status %d want %d", w.Code, http.StatusOK)
+				}
+				for _, want := range tc.inBody {
+					if !strings.Contains(w.Body.String(), want) {
+						t.Errorf("got body %q want %q in body", w.Body, want)
+					}
+				}
+				if strings.Contains(w.Body.String(), "$1") {
+					t.Errorf("got body %q want no dollar amounts", w.Body)
+				}
+			})
+		}
+	})
+}
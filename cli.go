@@ -0,0 +1,601 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bnixon67/webapp/csv"
+	"github.com/bnixon67/webapp/webauth"
+)
+
+// commandEnv is what a command runs with.
+type commandEnv struct {
+	store       BidStore
+	db          *webauth.AuthDB // users and events
+	driverName  string          // driver db was opened with
+	images      ImageStore      // images of items
+	uploads     ImageStore      // images as uploaded, before they are processed
+	imageWidths []int           // widths of the variants of images
+	imageLimits ImageLimits     // limits on uploaded images
+	in          io.Reader
+	out         io.Writer
+}
+
+// command is a subcommand of gobid that administers the database of a
+// config file, which is given after the name of the command.
+type command struct {
+	name  string // words that name the command
+	usage string // flags and arguments after the config file
+	run   func(env commandEnv, args []string) error
+}
+
+// commands are the subcommands of gobid, other than serve and migrate.
+var commands = []command{
+	{"items export", "[-auction slug]", itemsExportCommand},
+	{"items import", "[-auction slug] [-format csv|json] [-images zip] [-dry-run] [file]", itemsImportCommand},
+	{"winners", "[-auction slug]", winnersCommand},
+	{"auction create", "-slug slug -name name [-time-zone zone] [-currency code] -start time -end time", auctionCreateCommand},
+	{"auction set-current", "slug", auctionSetCurrentCommand},
+	{"auction set-times", "[-auction slug] -start time -end time", auctionSetTimesCommand},
+	{"auction export", "[-auction slug] [file]", auctionExportCommand},
+	{"auction restore", "file", auctionRestoreCommand},
+	{"images sweep", "[-min-age duration] [-dry-run]", imagesSweepCommand},
+	{"images variants", "[-force]", imagesVariantsCommand},
+	{"user make-admin", "[-revoke] username", userMakeAdminCommand},
+}
+
+var ErrUsage = errors.New("usage")
+
+// slugRE matches the slug of an auction, which is used in URLs.
+var slugRE = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,29}$`)
+
+// TimeLayout is the layout of times given to commands, which are in the
+// time zone of the auction.
+const TimeLayout = "2006-01-02 15:04"
+
+// usage writes the usage of gobid to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s serve [config file]\n", os.Args[0])
+	fmt.Fprintf(w, "       %s migrate up|down|status [config file]\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "       %s %s [config file] %s\n", os.Args[0], cmd.name, cmd.usage)
+	}
+}
+
+// findCommand returns the command named by the start of args and the rest
+// of args.
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return cmd, args[len(words):], true
+		}
+	}
+
+	return command{}, nil, false
+}
+
+// isCommandWord returns true if arg is the first word of a command.
+func isCommandWord(arg string) bool {
+	if arg == "serve" || arg == "migrate" {
+		return true
+	}
+
+	for _, cmd := range commands {
+		if strings.Fields(cmd.name)[0] == arg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runCommand runs cmd with args on the database of the config file and
+// returns the exit code.
+func runCommand(cmd command, configFileName string, args []string) int {
+	cfg := loadConfig(configFileName)
+	images, imagesCfg := loadImageStore(configFileName)
+	uploads, err := NewUploadStore(imagesCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init upload store:", err)
+		return ExitConfig
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	store, err := NewBidStore(cfg.SQL.DriverName, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init bid db:", err)
+		return ExitDB
+	}
+
+	err = cmd.run(commandEnv{store: store, db: db, driverName: cfg.SQL.DriverName, images: images, uploads: uploads, imageWidths: imagesCfg.ImageWidths(), imageLimits: imagesCfg.ImageLimits(), in: os.Stdin, out: os.Stdout}, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", cmd.name, err)
+		if errors.Is(err, ErrUsage) {
+			fmt.Fprintf(os.Stderr, "Usage: %s %s [config file] %s\n", os.Args[0], cmd.name, cmd.usage)
+			return ExitUsage
+		}
+		return ExitApp
+	}
+
+	return 0
+}
+
+// parseFlags parses args with flags and returns the remaining arguments,
+// which must number from minArgs to maxArgs.
+func parseFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		return nil, fmt.Errorf("%w: unexpected arguments %q", ErrUsage, flags.Args())
+	}
+
+	return flags.Args(), nil
+}
+
+// commandAuction returns the auction with slug, or the current auction
+// if slug is empty.
+func commandAuction(store BidStore, slug string) (Auction, error) {
+	if slug == "" {
+		ci, err := store.GetConfigItem("current_auction")
+		if err != nil {
+			return Auction{}, fmt.Errorf("current_auction %w", err)
+		}
+		slug = ci.Value
+	}
+
+	return store.GetAuction(slug)
+}
+
+// itemsExportCommand writes the items of an auction as JSON.
+func itemsExportCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("items export", flag.ContinueOnError)
+	slug := flags.String("auction", "", "slug of auction")
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	auction, err := commandAuction(env.store, *slug)
+	if err != nil {
+		return err
+	}
+
+	items, err := env.store.GetItems(auction.ID)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(env.out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(items)
+}
+
+// itemsImportCommand creates the items in a CSV or JSON file, or standard
+// input, in an auction, with their images from a zip. No items are created
+// if a row has an error, which is written for each row.
+func itemsImportCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("items import", flag.ContinueOnError)
+	slug := flags.String("auction", "", "slug of auction")
+	format := flags.String("format", "", "csv or json, from the file extension by default")
+	imagesName := flags.String("images", "", "zip of images matched by file name")
+	dryRun := flags.Bool("dry-run", false, "check the rows without creating items")
+	args, err := parseFlags(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	auction, err := commandAuction(env.store, *slug)
+	if err != nil {
+		return err
+	}
+
+	in := env.in
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+
+		if *format == "" {
+			*format = ImportFormat(args[0])
+		}
+	}
+	if *format == "" {
+		*format = "json"
+	}
+
+	rows, err := ReadImportRows(in, *format, auction.ID)
+	if err != nil {
+		return err
+	}
+
+	var images *zip.Reader
+	if *imagesName != "" {
+		zipFile, err := zip.OpenReader(*imagesName)
+		if err != nil {
+			return err
+		}
+		defer zipFile.Close()
+
+		images = &zipFile.Reader
+	}
+	MatchImportImages(rows, images)
+
+	invalid := 0
+	for _, row := range rows {
+		if row.Valid() {
+			fmt.Fprintf(env.out, "row %d ok %s\n", row.Row, row.Item.Title)
+		} else {
+			invalid++
+			fmt.Fprintf(env.out, "row %d error %s\n", row.Row, strings.Join(row.Errors, "; "))
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrImportRows, invalid, len(rows))
+	}
+	if *dryRun {
+		return nil
+	}
+
+	// the images are processed before the command exits, so the queue
+	// has room for all of them
+	queue := NewImageQueue(env.store, env.images, env.uploads, env.imageWidths, env.imageLimits, len(rows))
+	ids, err := ImportItems(env.store, rows, images, queue)
+	for n, id := range ids {
+		fmt.Fprintf(env.out, "created item %d %s\n", id, rows[n].Item.Title)
+	}
+
+	return errors.Join(err, queue.ProcessQueued())
+}
+
+// winnersCommand writes the winners of an auction as CSV.
+func winnersCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("winners", flag.ContinueOnError)
+	slug := flags.String("auction", "", "slug of auction")
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	auction, err := commandAuction(env.store, *slug)
+	if err != nil {
+		return err
+	}
+
+	winners, err := env.store.GetWinners(auction.ID)
+	if err != nil {
+		return err
+	}
+
+	return csv.SliceOfStructsToCSV(env.out, winners)
+}
+
+// auctionCreateCommand creates an auction, whose start and end are given
+// in its time zone. The auction becomes the current auction if there is
+// none.
+func auctionCreateCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("auction create", flag.ContinueOnError)
+	slug := flags.String("slug", "", "short name of auction used in URLs")
+	name := flags.String("name", "", "name of auction")
+	timeZone := flags.String("time-zone", "America/Chicago", "IANA time zone of auction")
+	currency := flags.String("currency", DefaultCurrency, "ISO 4217 currency of amounts of auction")
+	start := flags.String("start", "", "start of auction as "+TimeLayout)
+	end := flags.String("end", "", "end of auction as "+TimeLayout)
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if !slugRE.MatchString(*slug) {
+		return fmt.Errorf("%w: slug must be up to 30 lower case letters, digits, or dashes", ErrUsage)
+	}
+
+	if *name == "" {
+		return fmt.Errorf("%w: name is required", ErrUsage)
+	}
+
+	if !ValidCurrency(*currency) {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code, such as %s", ErrUsage, DefaultCurrency)
+	}
+
+	auction := Auction{Slug: *slug, Name: *name, TimeZone: *timeZone, Currency: *currency, BuyNowCutoff: 100}
+
+	loc, err := auction.Location()
+	if err != nil {
+		return fmt.Errorf("%w: time zone: %v", ErrUsage, err)
+	}
+
+	auction.AuctionStart, err = time.ParseInLocation(TimeLayout, *start, loc)
+	if err != nil {
+		return fmt.Errorf("%w: start: %v", ErrUsage, err)
+	}
+
+	auction.AuctionEnd, err = time.ParseInLocation(TimeLayout, *end, loc)
+	if err != nil {
+		return fmt.Errorf("%w: end: %v", ErrUsage, err)
+	}
+
+	if !auction.AuctionEnd.After(auction.AuctionStart) {
+		return fmt.Errorf("%w: end must be after start", ErrUsage)
+	}
+
+	id, err := env.store.CreateAuction(auction)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.out, "created auction %d %s\n", id, auction.Slug)
+
+	_, err = env.store.GetConfigItem("current_auction")
+	if errors.Is(err, ErrNotFound) {
+		err = env.store.SetCurrentAuction(auction.Slug)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.out, "current auction is %s\n", auction.Slug)
+	}
+
+	return nil
+}
+
+// auctionSetCurrentCommand sets the current auction, which is used by
+// requests and commands without an auction.
+func auctionSetCurrentCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("auction set-current", flag.ContinueOnError)
+	args, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	err = env.store.SetCurrentAuction(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.out, "current auction is %s\n", args[0])
+
+	return nil
+}
+
+// auctionSetTimesCommand sets the start and end of an auction, which are
+// given in its time zone.
+func auctionSetTimesCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("auction set-times", flag.ContinueOnError)
+	slug := flags.String("auction", "", "slug of auction")
+	start := flags.String("start", "", "start of auction as "+TimeLayout)
+	end := flags.String("end", "", "end of auction as "+TimeLayout)
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	auction, err := commandAuction(env.store, *slug)
+	if err != nil {
+		return err
+	}
+
+	loc, err := auction.Location()
+	if err != nil {
+		return err
+	}
+
+	startsAt, err := time.ParseInLocation(TimeLayout, *start, loc)
+	if err != nil {
+		return fmt.Errorf("%w: start: %v", ErrUsage, err)
+	}
+
+	endsAt, err := time.ParseInLocation(TimeLayout, *end, loc)
+	if err != nil {
+		return fmt.Errorf("%w: end: %v", ErrUsage, err)
+	}
+
+	if !endsAt.After(startsAt) {
+		return fmt.Errorf("%w: end must be after start", ErrUsage)
+	}
+
+	err = env.store.SetAuctionTimes(auction.ID, startsAt, endsAt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.out, "auction %s runs from %s to %s\n", auction.Slug,
+		startsAt.Format(TimeLayout+" MST"), endsAt.Format(TimeLayout+" MST"))
+
+	return nil
+}
+
+// auctionExportCommand writes the archive of an auction as a zip to a
+// file or standard output.
+func auctionExportCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("auction export", flag.ContinueOnError)
+	slug := flags.String("auction", "", "slug of auction")
+	args, err := parseFlags(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	auction, err := commandAuction(env.store, *slug)
+	if err != nil {
+		return err
+	}
+
+	out := env.out
+	if len(args) == 1 {
+		f, err := os.OpenFile(args[0], os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	missing, err := ExportArchive(out, env.store, env.db, auction, env.images)
+	for _, name := range missing {
+		fmt.Fprintf(os.Stderr, "missing image %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		fmt.Fprintf(env.out, "exported auction %s to %s\n", auction.Slug, args[0])
+	}
+
+	return nil
+}
+
+// auctionRestoreCommand restores the archive of an auction from a zip.
+func auctionRestoreCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("auction restore", flag.ContinueOnError)
+	args, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.OpenReader(args[0])
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	archive, err := RestoreArchive(&zr.Reader, env.db, env.driverName, env.images)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.out, "restored auction %s with %d items, %d bids, and %d pledges\n",
+		archive.Auction.Slug, len(archive.Items), len(archive.Bids), len(archive.Pledges))
+
+	return nil
+}
+
+// imagesSweepCommand removes the images that are not used by any item.
+func imagesSweepCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("images sweep", flag.ContinueOnError)
+	minAge := flags.Duration("min-age", ImageSweepMinAge, "keep images modified within this duration")
+	dryRun := flags.Bool("dry-run", false, "list the images without removing them")
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	removed, err := SweepImages(env.store, env.images, *minAge, *dryRun)
+	for _, name := range removed {
+		if *dryRun {
+			fmt.Fprintf(env.out, "would remove %s\n", name)
+		} else {
+			fmt.Fprintf(env.out, "removed %s\n", name)
+		}
+	}
+
+	return err
+}
+
+// imagesVariantsCommand saves the variants of the images used by items
+// that have none, such as images saved before there were variants.
+func imagesVariantsCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("images variants", flag.ContinueOnError)
+	force := flags.Bool("force", false, "save the variants of every image, such as after changing the widths")
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	names, err := env.store.GetImageFileNames()
+	if err != nil {
+		return err
+	}
+
+	variants, err := env.store.GetImageVariants(names)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if name == "" || (len(variants[name]) > 0 && !*force) {
+			continue
+		}
+
+		saved, err := createImageVariants(env.store, env.images, name, env.imageWidths)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(env.out, "missing image %s\n", name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("image %s: %w", name, err)
+		}
+
+		fmt.Fprintf(env.out, "saved %d variants of %s\n", len(saved), name)
+	}
+
+	return nil
+}
+
+// userMakeAdminCommand makes a user an admin, or revokes it.
+func userMakeAdminCommand(env commandEnv, args []string) error {
+	flags := flag.NewFlagSet("user make-admin", flag.ContinueOnError)
+	revoke := flags.Bool("revoke", false, "revoke admin instead")
+	args, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	userName := args[0]
+
+	err = setAdmin(env.db, userName, !*revoke)
+	if err != nil {
+		return err
+	}
+
+	if *revoke {
+		fmt.Fprintf(env.out, "%s is not an admin\n", userName)
+	} else {
+		fmt.Fprintf(env.out, "%s is an admin\n", userName)
+	}
+
+	return nil
+}
+
+// setAdmin sets whether userName is an admin.
+func setAdmin(db *webauth.AuthDB, userName string, admin bool) error {
+	if db == nil {
+		return ErrInvalidDB
+	}
+
+	exists, err := db.UserExists(userName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("user %q: %w", userName, ErrNotFound)
+	}
+
+	_, err = db.Exec("UPDATE users SET admin = ? WHERE username = ?", admin, userName)
+
+	return err
+}
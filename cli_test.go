@@ -0,0 +1,196 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindCommand(t *testing.T) {
+	cases := []struct {
+		args     []string
+		wantName string
+		wantRest []string
+		wantOk   bool
+	}{
+		{[]string{"items", "export", "gobid.json"}, "items export", []string{"gobid.json"}, true},
+		{[]string{"winners", "gobid.json", "-auction", "spring"}, "winners", []string{"gobid.json", "-auction", "spring"}, true},
+		{[]string{"items"}, "", nil, false},
+		{[]string{"items", "delete", "gobid.json"}, "", nil, false},
+	}
+
+	for _, tc := range cases {
+		cmd, rest, ok := findCommand(tc.args)
+		if cmd.name != tc.wantName || strings.Join(rest, " ") != strings.Join(tc.wantRest, " ") || ok != tc.wantOk {
+			t.Errorf("findCommand(%q) = %q, %q, %v, want %q, %q, %v", tc.args, cmd.name, rest, ok, tc.wantName, tc.wantRest, tc.wantOk)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	db := sqliteDBForTest(t)
+
+	_, err := db.PlaceBid(1, Cents(2000), "test")
+	if err != nil {
+		t.Fatalf("PlaceBid() failed: %v", err)
+	}
+
+	var export strings.Builder
+	err = itemsExportCommand(commandEnv{store: db, db: db.sqlDB, out: &export}, []string{"-auction", "soft"})
+	if err != nil {
+		t.Fatalf("items export failed: %v", err)
+	}
+
+	// the exported image is not in a zip
+	exportNoImage := strings.ReplaceAll(export.String(), `"ImageFileName": "File"`, `"ImageFileName": ""`)
+
+	archiveFile := filepath.Join(t.TempDir(), "test.zip")
+	images := &LocalImageStore{Dir: t.TempDir()}
+
+	csvImport := "title,description,artist,openingBid,minBidIncr\nCSV Test,About,Artist,10.00,1\n"
+	csvWithImage := "title,description,artist,openingBid,minBidIncr,imageFileName\nImage Test,About,Artist,10.00,1,a.png\n"
+
+	imagesZip := filepath.Join(t.TempDir(), "images.zip")
+	f, err := os.Create(imagesZip)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	fw, _ := zw.Create("a.png")
+	fw.Write(imageForTest(t, color.White))
+	zw.Close()
+	f.Close()
+
+	cases := []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"items export", nil, "", `"Title": "Bid Test"`, nil},
+		{"items export", []string{"extra"}, "", "", ErrUsage},
+		{"items import", nil, export.String(), "row 1 error image \"File\" needs a zip of images", ErrImportRows},
+		{"items import", nil, exportNoImage, "created item 11 Soft Close Test", nil},
+		{"items import", []string{"-auction", "nosuch"}, export.String(), "", ErrNotFound},
+		{"items import", []string{"-format", "csv", "-dry-run"}, csvImport, "row 1 ok CSV Test", nil},
+		{"items import", []string{"-format", "csv"}, csvImport + "No Artist,About,,10,1\n", "row 2 error artist is required", ErrImportRows},
+		{"items import", []string{"-format", "xml"}, "", "", ErrImportFormat},
+		{"items import", []string{"-auction", "soft", "-format", "csv"}, csvWithImage, "row 1 error image \"a.png\" needs a zip of images", ErrImportRows},
+		{"items import", []string{"-auction", "soft", "-format", "csv", "-images", imagesZip}, csvWithImage, "created item 12 Image Test", nil},
+		{"winners", nil, "", "Bid Test", nil},
+		{"auction set-times", []string{"-auction", "soft", "-start", "2024-05-01 18:00", "-end", "2024-05-01 21:00"}, "", "auction soft runs from 2024-05-01 18:00 CDT to 2024-05-01 21:00 CDT", nil},
+		{"auction set-times", []string{"-start", "2024-05-01 18:00", "-end", "2024-05-01 17:00"}, "", "", ErrUsage},
+		{"auction set-times", []string{"-start", "May 1", "-end", "2024-05-01 17:00"}, "", "", ErrUsage},
+		{"auction export", []string{archiveFile}, "", "exported auction test to " + archiveFile, nil},
+		{"auction export", []string{archiveFile}, "", "", os.ErrExist},
+		{"auction restore", []string{archiveFile}, "", "", ErrArchiveConflict},
+		{"auction restore", nil, "", "", ErrUsage},
+		{"images sweep", []string{"-dry-run"}, "", "", nil},
+		{"images sweep", []string{"extra"}, "", "", ErrUsage},
+		{"images variants", nil, "", "missing image File", nil},
+		{"images variants", []string{"extra"}, "", "", ErrUsage},
+		{"auction create", []string{"-slug", "spring", "-name", "Spring", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "spring", nil},
+		{"auction create", []string{"-slug", "spring", "-name", "Spring", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "", ErrCreateFailed},
+		{"auction create", []string{"-slug", "Spring!", "-name", "Spring", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "", ErrUsage},
+		{"auction create", []string{"-slug", "fall", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "", ErrUsage},
+		{"auction create", []string{"-slug", "fall", "-name", "Fall", "-time-zone", "Nowhere", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "", ErrUsage},
+		{"auction create", []string{"-slug", "fall", "-name", "Fall", "-start", "2024-05-01 18:00", "-end", "2024-05-01 17:00"}, "", "", ErrUsage},
+		{"auction create", []string{"-slug", "fall", "-name", "Fall", "-currency", "eur", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}, "", "", ErrUsage},
+		{"auction set-current", []string{"spring"}, "", "current auction is spring", nil},
+		{"auction set-current", []string{"nosuch"}, "", "", ErrNotFound},
+		{"auction set-current", nil, "", "", ErrUsage},
+		{"user make-admin", []string{"test"}, "", "test is an admin", nil},
+		{"user make-admin", []string{"-revoke", "admin"}, "", "admin is not an admin", nil},
+		{"user make-admin", []string{"nosuch"}, "", "", ErrNotFound},
+		{"user make-admin", nil, "", "", ErrUsage},
+	}
+
+	for _, tc := range cases {
+		cmd, _, ok := findCommand(strings.Fields(tc.name))
+		if !ok {
+			t.Fatalf("findCommand(%q) failed", tc.name)
+		}
+
+		var out strings.Builder
+		env := commandEnv{store: db, db: db.sqlDB, driverName: DriverSQLite, images: images, uploads: &LocalImageStore{Dir: t.TempDir()}, imageWidths: DefaultImageWidths, imageLimits: DefaultImageLimits, in: strings.NewReader(tc.in), out: &out}
+
+		err := cmd.run(env, tc.args)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s %q got err '%v' want '%v'", tc.name, tc.args, err, tc.wantErr)
+		}
+		if !strings.Contains(out.String(), tc.want) {
+			t.Errorf("%s %q got %q want %q", tc.name, tc.args, out.String(), tc.want)
+		}
+	}
+
+	items, err := db.GetItems(1)
+	if err != nil || len(items) != 10 {
+		t.Errorf("GetItems(1) after import got %d items err '%v' want 10", len(items), err)
+	}
+
+	// the image is processed before the command returns
+	item, err := db.GetItem(12)
+	if err != nil {
+		t.Fatalf("GetItem(12) failed: %v", err)
+	}
+	variants, err := db.GetImageVariants([]string{item.ImageFileName})
+	if err != nil || len(variants[item.ImageFileName]) == 0 {
+		t.Errorf("GetImageVariants(%q) after import got %v err '%v' want variants", item.ImageFileName, variants, err)
+	}
+
+	auction, err := db.GetAuction("soft")
+	wantStart := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	if err != nil || !auction.AuctionStart.Equal(wantStart) {
+		t.Errorf("GetAuction() after set-times got start %v err '%v' want %v", auction.AuctionStart, err, wantStart)
+	}
+
+	ci, err := db.GetConfigItem("current_auction")
+	if err != nil || ci.Value != "spring" {
+		t.Errorf("GetConfigItem() after set-current got %q err '%v' want %q", ci.Value, err, "spring")
+	}
+
+	for userName, want := range map[string]bool{"test": true, "admin": false} {
+		var admin bool
+		err := db.sqlDB.QueryRow("SELECT admin FROM users WHERE username = ?", userName).Scan(&admin)
+		if err != nil || admin != want {
+			t.Errorf("admin of %q got %v err '%v' want %v", userName, admin, err, want)
+		}
+	}
+}
+
+func TestAuctionCreateCommandSetsCurrent(t *testing.T) {
+	db := emptySQLiteDBForTest(t)
+
+	var out strings.Builder
+	env := commandEnv{store: db, db: db.sqlDB, driverName: DriverSQLite, out: &out}
+
+	args := []string{"-slug", "first", "-name", "First", "-currency", "EUR", "-start", "2024-05-01 18:00", "-end", "2024-05-02 18:00"}
+	err := auctionCreateCommand(env, args)
+	if err != nil {
+		t.Fatalf("auction create failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "current auction is first") {
+		t.Errorf("auction create got %q want current auction", out.String())
+	}
+
+	app := &BidApp{BidDB: db}
+	err = app.ConfigAuction()
+	if err != nil || app.DefaultAuction != "first" {
+		t.Errorf("ConfigAuction() got %q err '%v' want %q", app.DefaultAuction, err, "first")
+	}
+
+	auction, err := db.GetAuction("first")
+	wantStart := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	if err != nil || !auction.AuctionStart.Equal(wantStart) || auction.BuyNowCutoff != 100 || auction.Currency != "EUR" {
+		t.Errorf("GetAuction() got start %v cutoff %d currency %q err '%v' want %v cutoff 100 currency EUR", auction.AuctionStart, auction.BuyNowCutoff, auction.Currency, err, wantStart)
+	}
+}
@@ -15,58 +15,64 @@ type Config struct {
 	webauth.Config // Inherit webapp.Config
 }
 
-const timeLayout = "2006-01-02 15:04:05 MST"
-
-func (app *BidApp) GetTimeConfig(name string) (time.Time, error) {
-	var t time.Time
+// AuctionTimes determines when bids are accepted.
+//
+// A bid placed within SoftCloseWindow of an item closing extends the
+// closing time of that item to SoftCloseExtension after the bid. A zero
+// SoftCloseWindow disables extensions.
+type AuctionTimes struct {
+	AuctionStart, AuctionEnd            time.Time
+	SoftCloseWindow, SoftCloseExtension time.Duration
+}
 
-	ci, err := app.BidDB.GetConfigItem(name)
+// ConfigAuction sets the default auction, which is named by the
+// current_auction config item and is used by requests without an auction.
+func (app *BidApp) ConfigAuction() error {
+	s := "current_auction"
+	ci, err := app.BidDB.GetConfigItem(s)
 	if err != nil {
-		return t, err
+		return fmt.Errorf("%q %w", s, err)
 	}
 
-	// TODO: define config variable for timezone
-	loc, err := time.LoadLocation("America/Chicago")
+	auction, err := app.BidDB.GetAuction(ci.Value)
 	if err != nil {
-		return t, err
+		return fmt.Errorf("%q %w", s, err)
 	}
 
-	t, err = time.ParseInLocation(timeLayout, ci.Value, loc)
+	_, err = auction.Location()
 	if err != nil {
-		return t, err
+		return fmt.Errorf("%q %w", s, err)
 	}
 
-	return t, err
-}
-
-func (app *BidApp) ConfigAuction() error {
-	var err error
+	app.DefaultAuction = auction.Slug
 
-	s := "auction_start"
-	app.AuctionStart, err = app.GetTimeConfig(s)
-	if err != nil {
-		return fmt.Errorf("%q %w", s, err)
-	}
+	return nil
+}
 
-	s = "auction_end"
-	app.AuctionEnd, err = app.GetTimeConfig(s)
-	if err != nil {
-		return fmt.Errorf("%q %w", s, err)
-	}
+func (t AuctionTimes) IsAuctionStarted() bool {
+	return time.Now().After(t.AuctionStart)
+}
 
-	return err
+func (t AuctionTimes) IsAuctionEnded() bool {
+	return time.Now().After(t.AuctionEnd)
 }
 
-func (app *BidApp) IsAuctionStarted() bool {
-	return time.Now().After(app.AuctionStart)
+// ItemOpensAt returns when bidding opens for item, which is the start of
+// the auction unless the item overrides it.
+func (t AuctionTimes) ItemOpensAt(item Item) time.Time {
+	return item.StartTime(t.AuctionStart)
 }
 
-func (app *BidApp) IsAuctionEnded() bool {
-	return time.Now().After(app.AuctionEnd)
+// ItemClosesAt returns when bidding closes for item, which is the end of
+// the auction unless the item overrides it or a late bid extended it.
+func (t AuctionTimes) ItemClosesAt(item Item) time.Time {
+	return item.EndTime(t.AuctionEnd)
 }
 
-func (app *BidApp) IsAuctionOpen() bool {
-	if app.IsAuctionStarted() && !app.IsAuctionEnded() {
+// IsAuctionOpen returns true if bids are accepted for item.
+func (t AuctionTimes) IsAuctionOpen(item Item) bool {
+	now := time.Now()
+	if now.After(t.ItemOpensAt(item)) && now.Before(t.ItemClosesAt(item)) {
 		return true
 	}
 	return false
@@ -4,37 +4,339 @@
 package main
 
 import (
+	"cmp"
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bnixon67/webapp/webauth"
 )
 
+// BidStore stores the auctions, items, bids, pledges, and config used by
+// BidApp. BidDB stores them in MySQL, SQLiteDB in SQLite, PostgresDB in
+// PostgreSQL, and MemStore in memory.
+//
+// Bids and pledges must follow the rules of the placeBid, buyNow, and
+// placePledge procedures in the MySQL migrations.
+type BidStore interface {
+	GetConfigItem(name string) (ConfigItem, error)
+
+	GetAuction(slug string) (Auction, error)
+	GetAuctionByID(id int) (Auction, error)
+	GetAuctions() ([]Auction, error)
+	CreateAuction(auction Auction) (int64, error)
+	SetAuctionTimes(id int, startsAt, endsAt time.Time) error
+	SetCurrentAuction(slug string) error
+
+	GetIncrements(name string) ([]Increment, error)
+	GetIncrementSchedules() (map[string][]Increment, error)
+
+	GetItem(id int) (Item, error)
+	GetItems(auctionID int) ([]Item, error)
+	GetItemsWithBids(auctionID int) ([]ItemWithBids, error)
+	CreateItem(item Item) (int64, error)
+	UpdateItem(item Item) (int64, error)
+	SetItemImages(id int, images []string) error
+	GetImageFileNames() ([]string, error)
+	GetImageVariants(fileNames []string) (map[string][]ImageVariant, error)
+	SetImageVariants(fileName string, variants []ImageVariant) error
+	GetImageStatuses(fileNames []string) (map[string]ImageStatus, error)
+	GetPendingImages() ([]string, error)
+	SetImageStatus(fileName string, status ImageStatus) error
+
+	PlaceBid(id int, bidAmount Money, userName string) (BidResult, error)
+	PlaceUnitsBid(id int, bidAmount Money, units int, userName string) (BidResult, error)
+	BuyNow(id int, userName string) (BidResult, error)
+	Pledge(id int, amount Money, userName string) (PledgeResult, error)
+
+	GetBids() ([]Bid, error)
+	GetBidsForItem(id int) ([]Bid, error)
+	GetMaxBid(id int, bidder string) (Money, error)
+	GetWinningBids(id int) ([]WinningBid, error)
+	GetWinners(auctionID int) ([]Winner, error)
+}
+
+// BidDB is a BidStore that uses MySQL.
 type BidDB struct {
 	sqlDB *webauth.AuthDB
 }
 
+// Database drivers supported by NewBidStore.
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
+
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+// NewBidStore returns the BidStore for sqlDB, which was opened with the
+// driver driverName. The schema of the database is created by Migrate.
+func NewBidStore(driverName string, sqlDB *webauth.AuthDB) (BidStore, error) {
+	switch driverName {
+	case DriverMySQL:
+		return &BidDB{sqlDB: sqlDB}, nil
+	case DriverSQLite:
+		return NewSQLiteDB(sqlDB)
+	case DriverPostgres:
+		return NewPostgresDB(sqlDB)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, driverName)
+}
+
+// BidResult is the outcome of placing a bid.
+//
+// The bid amount is the maximum the bidder is willing to pay, so the new
+// high bidder and current bid may differ from the bid that was placed.
+//
+// For an item with a quantity greater than one, PriorBidder is the bidder
+// that lost a unit, if any, and CurrentBid is the lowest winning bid.
 type BidResult struct {
 	BidPlaced   bool
 	Message     string
-	PriorBidder string
+	PriorBidder string // high bidder before the bid
+	HighBidder  string // high bidder after the bid
+	CurrentBid  Money  // current bid after the bid
 }
 
+// PledgeResult is the outcome of a pledge.
+type PledgeResult struct {
+	Pledged bool
+	Message string
+	Total   Money // total of all pledges for the item
+}
+
+// Outbid returns true if the prior bidder lost the lead because their
+// maximum bid was exceeded.
+func (r BidResult) Outbid() bool {
+	return r.BidPlaced && r.PriorBidder != "" && r.PriorBidder != r.HighBidder
+}
+
+// Item types.
+const (
+	ItemTypeAuction = "auction" // bid on, or display only
+	ItemTypePledge  = "pledge"  // fund-a-need item that accepts pledges
+	ItemTypeSealed  = "sealed"  // bids are hidden until the item closes
+)
+
 type Item struct {
 	ID            int
+	AuctionID     int
+	ItemType      string
 	Title         string
 	Created       time.Time
 	Description   string
-	OpeningBid    float64
-	MinBidIncr    float64
+	OpeningBid    Money
+	MinBidIncr    Money       // increment if not given by a schedule
+	IncrSchedule  string      // overrides auction's schedule if not empty
+	Increments    []Increment // schedule of the item or its auction
+	Quantity      int         // units, each won by one of the highest bids
+	ReservePrice  Money       // not sold below, must not be shown to bidders
+	BuyNowPrice   Money       // price to buy outright, zero if not available
+	PledgeLevels  []Money     // suggested amounts for a pledge item
 	Artist        string
-	ImageFileName string
+	ImageFileName string   // first of Images, used for the gallery thumbnail
+	Images        []string // images in order, set by GetItem and ReadArchive
 	Bidder        string
-	CurrentBid    float64
+	CurrentBid    Money // lowest winning bid if Quantity is more than one
+	UnitsBid      int   // units with a winning bid
+	PledgeTotal   Money // total of pledges for a pledge item
+	Pledges       int   // number of pledges for a pledge item
 	Modified      *time.Time
-	MinBid        float64
+	MinBid        Money
+	OpensAt       *time.Time // overrides start of auction if not nil
+	ClosesAt      *time.Time // overrides end of auction if not nil
+	ExtendedTo    *time.Time // closing time extended by a late bid
+	SoldAt        *time.Time // when bought with buy now, which closes bidding
+}
+
+// Increment is the bid increment for a current bid of at least From and
+// below To, or without an upper limit if To is zero.
+type Increment struct {
+	From   Money
+	To     Money
+	Amount Money
+}
+
+// BidIncrement returns the increment above amount, which is from the
+// increment schedule if it covers amount, or else MinBidIncr. This must
+// agree with the bidIncrement function in the database.
+func (item Item) BidIncrement(amount Money) Money {
+	incr := item.MinBidIncr
+	for _, increment := range item.Increments {
+		if increment.From.Cmp(amount) <= 0 {
+			incr = increment.Amount
+		}
+	}
+	return incr
+}
+
+// HasReserve returns true if the item has a reserve price.
+func (item Item) HasReserve() bool {
+	return item.ReservePrice.Cents > 0
+}
+
+// ReserveMet returns true if the current bid is at least the reserve price.
+func (item Item) ReserveMet() bool {
+	return item.CurrentBid.Cents > 0 &&
+		item.CurrentBid.Cmp(item.ReservePrice) >= 0
+}
+
+// IsPledge returns true if the item accepts pledges instead of bids.
+func (item Item) IsPledge() bool {
+	return item.ItemType == ItemTypePledge
+}
+
+// IsSealed returns true if bids on the item are hidden until it closes.
+func (item Item) IsSealed() bool {
+	return item.ItemType == ItemTypeSealed
+}
+
+// WithBidsHidden returns the item without its current bid and bidder,
+// such as for a sealed item that is open.
+func (item Item) WithBidsHidden() Item {
+	item.Bidder = ""
+	item.CurrentBid = Money{}
+	item.UnitsBid = 0
+	item.Modified = nil
+	item.MinBid = item.OpeningBid
+	return item
+}
+
+// InCurrency returns the item with its amounts in currency.
+func (item Item) InCurrency(currency string) Item {
+	item.OpeningBid = item.OpeningBid.In(currency)
+	item.MinBidIncr = item.MinBidIncr.In(currency)
+	item.ReservePrice = item.ReservePrice.In(currency)
+	item.BuyNowPrice = item.BuyNowPrice.In(currency)
+	item.CurrentBid = item.CurrentBid.In(currency)
+	item.PledgeTotal = item.PledgeTotal.In(currency)
+	item.MinBid = item.MinBid.In(currency)
+
+	// copy the slices, which may be shared with the store
+	increments := make([]Increment, len(item.Increments))
+	for i, increment := range item.Increments {
+		increments[i] = Increment{
+			From:   increment.From.In(currency),
+			To:     increment.To.In(currency),
+			Amount: increment.Amount.In(currency),
+		}
+	}
+	item.Increments = increments
+
+	levels := make([]Money, len(item.PledgeLevels))
+	for i, level := range item.PledgeLevels {
+		levels[i] = level.In(currency)
+	}
+	item.PledgeLevels = levels
+
+	return item
+}
+
+// inCurrency puts each of values in currency and returns values.
+func inCurrency[T interface{ InCurrency(string) T }](values []T, currency string) []T {
+	for i, value := range values {
+		values[i] = value.InCurrency(currency)
+	}
+	return values
+}
+
+// PledgeLevelList returns the pledge levels as a comma separated list.
+func (item Item) PledgeLevelList() string {
+	return FormatPledgeLevels(item.PledgeLevels)
+}
+
+// ValidType returns true if the item has a known type. Only a pledge item
+// may have pledge levels, and it cannot have a reserve, buy now price, or
+// more than one unit. A sealed item cannot have a buy now price or more
+// than one unit.
+func (item Item) ValidType() bool {
+	switch item.ItemType {
+	case ItemTypeAuction:
+		return len(item.PledgeLevels) == 0
+	case ItemTypeSealed:
+		return len(item.PledgeLevels) == 0 && item.BuyNowPrice.IsZero() &&
+			item.Quantity == 1
+	case ItemTypePledge:
+		return item.ReservePrice.IsZero() && item.BuyNowPrice.IsZero() &&
+			item.Quantity == 1
+	}
+	return false
+}
+
+// MultiQuantity returns true if the item has more than one unit.
+func (item Item) MultiQuantity() bool {
+	return item.Quantity > 1
+}
+
+// ValidQuantity returns true if the item has at least one unit, and only
+// one unit if it has a buy now price.
+func (item Item) ValidQuantity() bool {
+	if item.Quantity < 1 {
+		return false
+	}
+	return item.BuyNowPrice.IsZero() || item.Quantity == 1
+}
+
+// Sold returns true if the item was bought with buy now.
+func (item Item) Sold() bool {
+	return item.SoldAt != nil
+}
+
+// StartTime returns when bidding opens for the item given the start of the
+// auction.
+func (item Item) StartTime(auctionStart time.Time) time.Time {
+	if item.OpensAt != nil {
+		return *item.OpensAt
+	}
+	return auctionStart
+}
+
+// ValidPrices returns true unless the reserve or buy now prices are
+// negative, or the buy now price is below the opening bid or reserve.
+func (item Item) ValidPrices() bool {
+	if item.ReservePrice.Cents < 0 || item.BuyNowPrice.Cents < 0 {
+		return false
+	}
+	if !item.BuyNowPrice.IsZero() &&
+		(item.BuyNowPrice.Cmp(item.OpeningBid) < 0 ||
+			item.BuyNowPrice.Cmp(item.ReservePrice) < 0) {
+		return false
+	}
+	return true
+}
+
+// Valid returns true if the times, prices, quantity, and type of the item
+// are valid.
+func (item Item) Valid() bool {
+	return item.ValidTimes() && item.ValidPrices() && item.ValidQuantity() && item.ValidType()
+}
+
+// ValidTimes returns true unless the item closes before it opens.
+func (item Item) ValidTimes() bool {
+	if item.OpensAt != nil && item.ClosesAt != nil {
+		return item.ClosesAt.After(*item.OpensAt)
+	}
+	return true
+}
+
+// EndTime returns when bidding closes for the item given the end of the
+// auction.
+func (item Item) EndTime(auctionEnd time.Time) time.Time {
+	end := auctionEnd
+	if item.ClosesAt != nil {
+		end = *item.ClosesAt
+	}
+	if item.ExtendedTo != nil && item.ExtendedTo.After(end) {
+		end = *item.ExtendedTo
+	}
+	if item.SoldAt != nil && item.SoldAt.Before(end) {
+		end = *item.SoldAt
+	}
+	return end
 }
 
 type ItemWithBids struct {
@@ -42,45 +344,431 @@ type ItemWithBids struct {
 	Title         string
 	Created       time.Time
 	Description   string
-	OpeningBid    float64
-	MinBidIncr    float64
+	OpeningBid    Money
+	MinBidIncr    Money
 	Artist        string
 	ImageFileName string
 	Bids          []Bid
 }
 
+// InCurrency returns the item with its amounts and bids in currency.
+func (item ItemWithBids) InCurrency(currency string) ItemWithBids {
+	item.OpeningBid = item.OpeningBid.In(currency)
+	item.MinBidIncr = item.MinBidIncr.In(currency)
+	item.Bids = inCurrency(slices.Clone(item.Bids), currency)
+	return item
+}
+
+// Auction is a single event with its own items, bids, and times.
+type Auction struct {
+	ID       int
+	Slug     string // short name used in URLs
+	Name     string
+	TimeZone string // IANA time zone used to display times
+	Currency string // ISO 4217 currency of the amounts of its items
+	Created  time.Time
+	AuctionTimes
+
+	// BuyNowCutoff is the percent of the buy now price the current bid
+	// must stay below for buy now to be available.
+	BuyNowCutoff int
+
+	// IncrSchedule names the increment schedule of items that do not
+	// have their own, or is empty to use the increment of each item.
+	IncrSchedule string
+}
+
+// CurrencySymbol returns the symbol that amounts of the auction are
+// displayed with.
+func (a Auction) CurrencySymbol() string {
+	return CurrencySymbol(cmp.Or(a.Currency, DefaultCurrency))
+}
+
+// Location returns the time zone of the auction.
+func (a Auction) Location() (*time.Location, error) {
+	return time.LoadLocation(a.TimeZone)
+}
+
+// BuyNowAvailable returns true if item can be bought with buy now.
+func (a Auction) BuyNowAvailable(item Item) bool {
+	// compare in hundredths of a cent so the cutoff is exact
+	cutoff := item.BuyNowPrice.Mul(int64(a.BuyNowCutoff))
+	return !item.BuyNowPrice.IsZero() && !item.Sold() &&
+		a.IsAuctionOpen(item) && item.CurrentBid.Mul(100).Cmp(cutoff) < 0
+}
+
+// BidsHidden returns true if bids on item must be hidden from user, which
+// is until a sealed item closes unless user is an admin.
+func (a Auction) BidsHidden(item Item, user webauth.User) bool {
+	return item.IsSealed() && !user.IsAdmin &&
+		time.Now().Before(a.ItemClosesAt(item))
+}
+
+// HideBids hides the bids on items that user cannot see and returns items.
+func (a Auction) HideBids(items []Item, user webauth.User) []Item {
+	for i, item := range items {
+		if a.BidsHidden(item, user) {
+			items[i] = item.WithBidsHidden()
+		}
+	}
+	return items
+}
+
 type ConfigItem struct {
 	Name      string
 	Value     string
 	ValueType string
 }
 
+// WinningBid is a bid that wins units of an item.
+type WinningBid struct {
+	Created time.Time
+	Bidder  string
+	Amount  Money
+	Units   int // units won, which may be fewer than were bid
+}
+
+// DisplayBidder returns the bidder name that is safe to show to others.
+func (bid WinningBid) DisplayBidder() string {
+	return DisplayName(bid.Bidder)
+}
+
+// InCurrency returns the bid with its amount in currency.
+func (bid WinningBid) InCurrency(currency string) WinningBid {
+	bid.Amount = bid.Amount.In(currency)
+	return bid
+}
+
 type Bid struct {
 	ID       int
 	Created  time.Time
 	Bidder   string
-	Amount   float64
+	Amount   Money
 	FullName string
 	Email    string
 }
 
+// InCurrency returns the bid with its amount in currency.
+func (bid Bid) InCurrency(currency string) Bid {
+	bid.Amount = bid.Amount.In(currency)
+	return bid
+}
+
+// OwnBids returns the bids placed by userName.
+func OwnBids(bids []Bid, userName string) []Bid {
+	var own []Bid
+	for _, bid := range bids {
+		if bid.Bidder == userName {
+			own = append(own, bid)
+		}
+	}
+	return own
+}
+
 var (
 	ErrNotFound  = errors.New("not found")
 	ErrInvalidDB = errors.New("invalid db")
 )
 
+// querier runs queries on a database or within a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func (db BidDB) GetItem(id int) (Item, error) {
-	var item Item
-	var err error
+	if db.sqlDB == nil {
+		return Item{}, ErrInvalidDB
+	}
+
+	item, err := getItem(db.sqlDB, id)
+	if err != nil {
+		return item, err
+	}
+
+	item.Images, err = getItemImages(db.sqlDB, item)
+
+	return item, err
+}
+
+// getItemImages returns the images of item in order using q. An item
+// without rows in item_images, such as one created before them, has its
+// ImageFileName as its only image.
+func getItemImages(q querier, item Item) ([]string, error) {
+	var images []string
+
+	rows, err := q.Query("SELECT fileName FROM item_images WHERE itemId = ? ORDER BY position", item.ID)
+	if err != nil {
+		return images, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileName string
+		err = rows.Scan(&fileName)
+		if err != nil {
+			return images, err
+		}
+		images = append(images, fileName)
+	}
+	err = rows.Err()
+	if err != nil {
+		return images, err
+	}
+
+	if len(images) == 0 && item.ImageFileName != "" {
+		images = []string{item.ImageFileName}
+	}
+
+	return images, nil
+}
+
+// GetImageFileNames returns the names of the images used by any item.
+func (db BidDB) GetImageFileNames() ([]string, error) {
+	var names []string
+
+	if db.sqlDB == nil {
+		return names, ErrInvalidDB
+	}
+
+	rows, err := db.sqlDB.Query("SELECT imageFileName FROM items UNION SELECT fileName FROM item_images")
+	if err != nil {
+		return names, err
+	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// SetItemImages replaces the images of item id with images, in order, and
+// sets its ImageFileName to the first image.
+func (db BidDB) SetItemImages(id int, images []string) error {
 	if db.sqlDB == nil {
-		return item, ErrInvalidDB
+		return ErrInvalidDB
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var one int
+	err = tx.QueryRow("SELECT 1 FROM items WHERE id = ?", id).Scan(&one)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM item_images WHERE itemId = ?", id)
+	if err != nil {
+		return err
+	}
+
+	for position, fileName := range images {
+		_, err = tx.Exec("INSERT INTO item_images(itemId, position, fileName) VALUES (?, ?, ?)", id, position, fileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	var first string
+	if len(images) > 0 {
+		first = images[0]
+	}
+
+	_, err = tx.Exec("UPDATE items SET imageFileName = ? WHERE id = ?", first, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetImageVariants returns the variants of the images named fileNames, by
+// image name, in order of width.
+func (db BidDB) GetImageVariants(fileNames []string) (map[string][]ImageVariant, error) {
+	variants := make(map[string][]ImageVariant)
+
+	if db.sqlDB == nil {
+		return variants, ErrInvalidDB
+	}
+
+	if len(fileNames) == 0 {
+		return variants, nil
+	}
+
+	args := make([]any, len(fileNames))
+	for i, fileName := range fileNames {
+		args[i] = fileName
+	}
+	placeholders := strings.Repeat("?, ", len(fileNames)-1) + "?"
+
+	qry := "SELECT fileName, name, width, mimeType FROM image_variants WHERE fileName IN (" + placeholders + ") ORDER BY fileName, width, mimeType"
+	rows, err := db.sqlDB.Query(qry, args...)
+	if err != nil {
+		return variants, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileName string
+		var variant ImageVariant
+		err = rows.Scan(&fileName, &variant.Name, &variant.Width, &variant.Type)
+		if err != nil {
+			return variants, err
+		}
+		variants[fileName] = append(variants[fileName], variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// SetImageVariants replaces the variants of the image named fileName with
+// variants.
+func (db BidDB) SetImageVariants(fileName string, variants []ImageVariant) error {
+	if db.sqlDB == nil {
+		return ErrInvalidDB
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM image_variants WHERE fileName = ?", fileName)
+	if err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		_, err = tx.Exec("INSERT INTO image_variants(name, fileName, width, mimeType) VALUES (?, ?, ?, ?)", variant.Name, fileName, variant.Width, variant.Type)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetImageStatuses returns the status of processing the images named
+// fileNames, by image name. Images without a status, such as those saved
+// before uploads were processed in the background, are not included.
+func (db BidDB) GetImageStatuses(fileNames []string) (map[string]ImageStatus, error) {
+	statuses := make(map[string]ImageStatus)
+
+	if db.sqlDB == nil {
+		return statuses, ErrInvalidDB
+	}
+
+	if len(fileNames) == 0 {
+		return statuses, nil
+	}
+
+	args := make([]any, len(fileNames))
+	for i, fileName := range fileNames {
+		args[i] = fileName
+	}
+	placeholders := strings.Repeat("?, ", len(fileNames)-1) + "?"
+
+	rows, err := db.sqlDB.Query("SELECT fileName, state, message FROM image_status WHERE fileName IN ("+placeholders+")", args...)
+	if err != nil {
+		return statuses, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileName string
+		var status ImageStatus
+		err = rows.Scan(&fileName, &status.State, &status.Message)
+		if err != nil {
+			return statuses, err
+		}
+		statuses[fileName] = status
+	}
+
+	return statuses, rows.Err()
+}
+
+// GetPendingImages returns the names of the images that are waiting to be
+// processed.
+func (db BidDB) GetPendingImages() ([]string, error) {
+	var names []string
+
+	if db.sqlDB == nil {
+		return names, ErrInvalidDB
+	}
+
+	rows, err := db.sqlDB.Query("SELECT fileName FROM image_status WHERE state = ? ORDER BY fileName", ImagePending)
+	if err != nil {
+		return names, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// SetImageStatus sets the status of processing the image named fileName.
+// A status without a State removes it.
+func (db BidDB) SetImageStatus(fileName string, status ImageStatus) error {
+	if db.sqlDB == nil {
+		return ErrInvalidDB
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM image_status WHERE fileName = ?", fileName)
+	if err != nil {
+		return err
+	}
+
+	if status.State == "" {
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec("INSERT INTO image_status(fileName, state, message) VALUES (?, ?, ?)", fileName, status.State, status.Message)
+	if err != nil {
+		return err
 	}
 
-	qry := "SELECT items.id, items.title, items.created, bids.created, IFNULL(bids.bidder,''), items.description, items.openingBid, items.minBidIncr, IFNULL(bids.amount,0), items.artist, items.imageFileName FROM items LEFT OUTER JOIN current_bids bids ON items.id = bids.id WHERE items.id = ?"
+	return tx.Commit()
+}
+
+// getItem returns item id using q.
+func getItem(q querier, id int) (Item, error) {
+	var item Item
+	var err error
+
+	qry := "SELECT items.id, items.auctionId, items.itemType, items.title, items.created, bids.created, COALESCE(bids.bidder,''), items.description, items.openingBid, items.minBidIncr, items.incrSchedule, " + incrScheduleColumn + ", items.quantity, items.reservePrice, items.buyNowPrice, items.pledgeLevels, COALESCE(bids.amount,0), COALESCE(bids.unitsBid,0), COALESCE(pledges.total,0), COALESCE(pledges.pledges,0), items.artist, items.imageFileName, items.opensAt, items.closesAt, items.extendedTo, items.soldAt FROM items LEFT OUTER JOIN auctions ON items.auctionId = auctions.id LEFT OUTER JOIN current_bids bids ON items.id = bids.id LEFT OUTER JOIN pledge_totals pledges ON items.id = pledges.id WHERE items.id = ?"
 
-	row := db.sqlDB.QueryRow(qry, id)
-	err = row.Scan(&item.ID, &item.Title, &item.Created, &item.Modified, &item.Bidder, &item.Description, &item.OpeningBid, &item.MinBidIncr, &item.CurrentBid, &item.Artist, &item.ImageFileName)
+	var pledgeLevels, schedule string
+	row := q.QueryRow(qry, id)
+	err = row.Scan(&item.ID, &item.AuctionID, &item.ItemType, &item.Title, &item.Created, &item.Modified, &item.Bidder, &item.Description, &item.OpeningBid, &item.MinBidIncr, &item.IncrSchedule, &schedule, &item.Quantity, &item.ReservePrice, &item.BuyNowPrice, &pledgeLevels, &item.CurrentBid, &item.UnitsBid, &item.PledgeTotal, &item.Pledges, &item.Artist, &item.ImageFileName, &item.OpensAt, &item.ClosesAt, &item.ExtendedTo, &item.SoldAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return item, fmt.Errorf("item %d: %w", id, ErrNotFound)
@@ -88,11 +776,23 @@ func (db BidDB) GetItem(id int) (Item, error) {
 		return item, err
 	}
 
+	item.PledgeLevels, err = ParsePledgeLevels(pledgeLevels)
+	if err != nil {
+		return item, fmt.Errorf("item %d: %w", id, err)
+	}
+
+	if schedule != "" {
+		item.Increments, err = getIncrements(q, schedule)
+		if err != nil {
+			return item, fmt.Errorf("item %d: %w", id, err)
+		}
+	}
+
 	// TODO: make this a database field
-	if item.CurrentBid == 0 {
+	if item.CurrentBid.IsZero() || item.UnitsBid < item.Quantity {
 		item.MinBid = item.OpeningBid
 	} else {
-		item.MinBid = item.CurrentBid + item.MinBidIncr
+		item.MinBid = item.CurrentBid.Add(item.BidIncrement(item.CurrentBid))
 	}
 
 	return item, err
@@ -121,7 +821,240 @@ func (db BidDB) GetConfigItem(name string) (ConfigItem, error) {
 	return config, err
 }
 
-func (db BidDB) GetItems() ([]Item, error) {
+const auctionColumns = "id, slug, name, timeZone, currency, startsAt, endsAt, softCloseWindow, softCloseExtension, buyNowCutoff, incrSchedule, created"
+
+// scanAuction scans a row selected with auctionColumns.
+func scanAuction(row interface{ Scan(...any) error }) (Auction, error) {
+	var auction Auction
+	var window, extension int
+
+	err := row.Scan(&auction.ID, &auction.Slug, &auction.Name, &auction.TimeZone, &auction.Currency, &auction.AuctionStart, &auction.AuctionEnd, &window, &extension, &auction.BuyNowCutoff, &auction.IncrSchedule, &auction.Created)
+	if err != nil {
+		return auction, err
+	}
+
+	auction.SoftCloseWindow = time.Duration(window) * time.Second
+	auction.SoftCloseExtension = time.Duration(extension) * time.Second
+
+	return auction, err
+}
+
+// GetAuction returns the auction identified by slug.
+func (db BidDB) GetAuction(slug string) (Auction, error) {
+	if db.sqlDB == nil {
+		return Auction{}, ErrInvalidDB
+	}
+
+	qry := "SELECT " + auctionColumns + " FROM auctions WHERE slug = ?"
+
+	auction, err := scanAuction(db.sqlDB.QueryRow(qry, slug))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return auction, fmt.Errorf("auction %q: %w", slug, ErrNotFound)
+		}
+		return auction, err
+	}
+
+	return auction, err
+}
+
+// GetAuctionByID returns the auction identified by id.
+func (db BidDB) GetAuctionByID(id int) (Auction, error) {
+	if db.sqlDB == nil {
+		return Auction{}, ErrInvalidDB
+	}
+
+	qry := "SELECT " + auctionColumns + " FROM auctions WHERE id = ?"
+
+	auction, err := scanAuction(db.sqlDB.QueryRow(qry, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return auction, fmt.Errorf("auction %d: %w", id, ErrNotFound)
+		}
+		return auction, err
+	}
+
+	return auction, err
+}
+
+// GetAuctions returns all auctions, most recent first.
+func (db BidDB) GetAuctions() ([]Auction, error) {
+	var auctions []Auction
+	var err error
+
+	if db.sqlDB == nil {
+		return auctions, ErrInvalidDB
+	}
+
+	qry := "SELECT " + auctionColumns + " FROM auctions ORDER BY startsAt DESC, id"
+
+	rows, err := db.sqlDB.Query(qry)
+	if err != nil {
+		return auctions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		auction, err := scanAuction(rows)
+		if err != nil {
+			return auctions, err
+		}
+
+		auctions = append(auctions, auction)
+	}
+	err = rows.Err()
+	if err != nil {
+		return auctions, err
+	}
+
+	return auctions, err
+}
+
+// SetAuctionTimes sets the start and end of auction id.
+func (db BidDB) SetAuctionTimes(id int, startsAt, endsAt time.Time) error {
+	if db.sqlDB == nil {
+		return ErrInvalidDB
+	}
+
+	update := "UPDATE auctions SET startsAt = ?, endsAt = ? WHERE id = ?"
+
+	_, err := db.sqlDB.Exec(update, storedTime(startsAt), storedTime(endsAt), id)
+
+	return err
+}
+
+const insertAuction = "INSERT INTO auctions(slug, name, timeZone, currency, startsAt, endsAt, softCloseWindow, softCloseExtension, buyNowCutoff, incrSchedule) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// insertAuctionArgs returns the arguments of insertAuction for auction.
+func insertAuctionArgs(a Auction) []any {
+	return []any{a.Slug, a.Name, a.TimeZone, cmp.Or(a.Currency, DefaultCurrency), storedTime(a.AuctionStart), storedTime(a.AuctionEnd), int(a.SoftCloseWindow.Seconds()), int(a.SoftCloseExtension.Seconds()), a.BuyNowCutoff, a.IncrSchedule}
+}
+
+// CreateAuction creates auction and returns its id. The slug of the
+// auction must be unique.
+func (db BidDB) CreateAuction(auction Auction) (int64, error) {
+	if db.sqlDB == nil {
+		return 0, ErrInvalidDB
+	}
+
+	result, err := db.sqlDB.Exec(insertAuction, insertAuctionArgs(auction)...)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCreateFailed, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCreateFailed, err)
+	}
+
+	return id, err
+}
+
+// SetCurrentAuction sets the current_auction config item to slug, which
+// must name an auction.
+func (db BidDB) SetCurrentAuction(slug string) error {
+	if db.sqlDB == nil {
+		return ErrInvalidDB
+	}
+
+	_, err := db.GetAuction(slug)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM config WHERE name = 'current_auction'")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO config(name, value, value_type) VALUES ('current_auction', ?, 'string')", slug)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// incrScheduleColumn selects the name of the increment schedule that
+// applies to an item, which is from the item or else its auction.
+const incrScheduleColumn = "CASE WHEN items.incrSchedule <> '' THEN items.incrSchedule ELSE COALESCE(auctions.incrSchedule, '') END"
+
+// scanIncrements returns the increments in rows by schedule, setting the
+// upper limit of each increment from the next one in the schedule. The
+// rows must be ordered by schedule and fromAmount.
+func scanIncrements(rows *sql.Rows) (map[string][]Increment, error) {
+	schedules := make(map[string][]Increment)
+
+	for rows.Next() {
+		var name string
+		var increment Increment
+
+		err := rows.Scan(&name, &increment.From, &increment.Amount)
+		if err != nil {
+			return schedules, err
+		}
+
+		schedule := schedules[name]
+		if n := len(schedule); n > 0 {
+			schedule[n-1].To = increment.From
+		}
+		schedules[name] = append(schedule, increment)
+	}
+
+	return schedules, rows.Err()
+}
+
+// GetIncrements returns the increments of the increment schedule named
+// name, ordered by the amount each applies from.
+func (db BidDB) GetIncrements(name string) ([]Increment, error) {
+	if db.sqlDB == nil {
+		return nil, ErrInvalidDB
+	}
+
+	return getIncrements(db.sqlDB, name)
+}
+
+// getIncrements returns the increments of the schedule named name using q.
+func getIncrements(q querier, name string) ([]Increment, error) {
+	qry := "SELECT schedule, fromAmount, increment FROM increments WHERE schedule = ? ORDER BY schedule, fromAmount"
+
+	rows, err := q.Query(qry, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules, err := scanIncrements(rows)
+
+	return schedules[name], err
+}
+
+// GetIncrementSchedules returns the increments of all increment schedules
+// by name.
+func (db BidDB) GetIncrementSchedules() (map[string][]Increment, error) {
+	if db.sqlDB == nil {
+		return nil, ErrInvalidDB
+	}
+
+	qry := "SELECT schedule, fromAmount, increment FROM increments ORDER BY schedule, fromAmount"
+
+	rows, err := db.sqlDB.Query(qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanIncrements(rows)
+}
+
+// GetItems returns the items in auction auctionID.
+func (db BidDB) GetItems(auctionID int) ([]Item, error) {
 	var items []Item
 	var err error
 
@@ -129,9 +1062,14 @@ func (db BidDB) GetItems() ([]Item, error) {
 		return items, ErrInvalidDB
 	}
 
-	qry := "SELECT items.id, items.title, items.created, bids.created, items.description, items.openingBid, items.minBidIncr, IFNULL(bids.amount,0), IFNULL(bids.bidder,''), items.artist, items.imageFileName FROM items LEFT OUTER JOIN current_bids bids ON items.id = bids.id"
+	schedules, err := db.GetIncrementSchedules()
+	if err != nil {
+		return items, err
+	}
+
+	qry := "SELECT items.id, items.auctionId, items.itemType, items.title, items.created, bids.created, items.description, items.openingBid, items.minBidIncr, items.incrSchedule, " + incrScheduleColumn + ", items.quantity, items.reservePrice, items.buyNowPrice, items.pledgeLevels, COALESCE(bids.amount,0), COALESCE(bids.unitsBid,0), COALESCE(bids.bidder,''), COALESCE(pledges.total,0), COALESCE(pledges.pledges,0), items.artist, items.imageFileName, items.opensAt, items.closesAt, items.extendedTo, items.soldAt FROM items LEFT OUTER JOIN auctions ON items.auctionId = auctions.id LEFT OUTER JOIN current_bids bids ON items.id = bids.id LEFT OUTER JOIN pledge_totals pledges ON items.id = pledges.id WHERE items.auctionId = ? ORDER BY items.id"
 
-	rows, err := db.sqlDB.Query(qry)
+	rows, err := db.sqlDB.Query(qry, auctionID)
 	if err != nil {
 		return items, err
 	}
@@ -139,17 +1077,25 @@ func (db BidDB) GetItems() ([]Item, error) {
 
 	for rows.Next() {
 		var item Item
+		var pledgeLevels, schedule string
 
-		err = rows.Scan(&item.ID, &item.Title, &item.Created, &item.Modified, &item.Description, &item.OpeningBid, &item.MinBidIncr, &item.CurrentBid, &item.Bidder, &item.Artist, &item.ImageFileName)
+		err = rows.Scan(&item.ID, &item.AuctionID, &item.ItemType, &item.Title, &item.Created, &item.Modified, &item.Description, &item.OpeningBid, &item.MinBidIncr, &item.IncrSchedule, &schedule, &item.Quantity, &item.ReservePrice, &item.BuyNowPrice, &pledgeLevels, &item.CurrentBid, &item.UnitsBid, &item.Bidder, &item.PledgeTotal, &item.Pledges, &item.Artist, &item.ImageFileName, &item.OpensAt, &item.ClosesAt, &item.ExtendedTo, &item.SoldAt)
 		if err != nil {
 			return items, err
 		}
 
+		item.PledgeLevels, err = ParsePledgeLevels(pledgeLevels)
+		if err != nil {
+			return items, fmt.Errorf("item %d: %w", item.ID, err)
+		}
+
+		item.Increments = schedules[schedule]
+
 		// TODO: make this a database field
-		if item.CurrentBid == 0 {
+		if item.CurrentBid.IsZero() || item.UnitsBid < item.Quantity {
 			item.MinBid = item.OpeningBid
 		} else {
-			item.MinBid = item.CurrentBid + item.MinBidIncr
+			item.MinBid = item.CurrentBid.Add(item.BidIncrement(item.CurrentBid))
 		}
 
 		items = append(items, item)
@@ -162,7 +1108,10 @@ func (db BidDB) GetItems() ([]Item, error) {
 	return items, err
 }
 
-func (db BidDB) GetWinners() ([]Winner, error) {
+// GetWinners returns the current winners in auction auctionID, including
+// items with a high bid below the reserve price, which are not sold. There
+// is one winner for each unit won of an item and for each pledge.
+func (db BidDB) GetWinners(auctionID int) ([]Winner, error) {
 	var winners []Winner
 	var err error
 
@@ -170,9 +1119,9 @@ func (db BidDB) GetWinners() ([]Winner, error) {
 		return winners, ErrInvalidDB
 	}
 
-	qry := "SELECT items.id, items.title, items.artist, bids.amount, bids.created, bids.bidder, IFNULL(users.fullName,'<missing>'), IFNULL(users.email,'<missing>') FROM items LEFT OUTER JOIN current_bids bids ON items.id = bids.id LEFT JOIN users ON bids.bidder = users.userName WHERE bids.Amount <> 0 ORDER BY items.id"
+	qry := "SELECT items.id AS id, items.title, items.artist, items.quantity, bids.units, bids.amount AS amount, bids.created AS created, bids.bidder, COALESCE(users.fullName,'<missing>'), COALESCE(users.email,'<missing>'), bids.amount >= items.reservePrice, bids.buyNow, false FROM items INNER JOIN winning_bids bids ON items.id = bids.id LEFT JOIN users ON bids.bidder = users.userName WHERE bids.Amount <> 0 AND items.auctionId = ? UNION ALL SELECT items.id, items.title, items.artist, items.quantity, 1, pledges.amount, pledges.created, pledges.pledger, COALESCE(users.fullName,'<missing>'), COALESCE(users.email,'<missing>'), true, false, true FROM items INNER JOIN pledges ON items.id = pledges.itemId LEFT JOIN users ON pledges.pledger = users.userName WHERE items.auctionId = ? ORDER BY id, amount DESC, created"
 
-	rows, err := db.sqlDB.Query(qry)
+	rows, err := db.sqlDB.Query(qry, auctionID, auctionID)
 	if err != nil {
 		return winners, err
 	}
@@ -180,13 +1129,14 @@ func (db BidDB) GetWinners() ([]Winner, error) {
 
 	for rows.Next() {
 		var winner Winner
+		var units int
 
-		err = rows.Scan(&winner.ID, &winner.Title, &winner.Artist, &winner.CurrentBid, &winner.Modified, &winner.ModifiedBy, &winner.FullName, &winner.Email)
+		err = rows.Scan(&winner.ID, &winner.Title, &winner.Artist, &winner.Quantity, &units, &winner.CurrentBid, &winner.Modified, &winner.ModifiedBy, &winner.FullName, &winner.Email, &winner.ReserveMet, &winner.BuyNow, &winner.Pledge)
 		if err != nil {
 			return winners, err
 		}
 
-		winners = append(winners, winner)
+		winners = appendWinner(winners, winner, units)
 	}
 	err = rows.Err()
 	if err != nil {
@@ -196,19 +1146,49 @@ func (db BidDB) GetWinners() ([]Winner, error) {
 	return winners, err
 }
 
+// appendWinner appends winner to winners once for each of units units won.
+// Units of an item are numbered in the order they were won, so winner must
+// follow the other winners of the item.
+func appendWinner(winners []Winner, winner Winner, units int) []Winner {
+	unit := 1
+	if n := len(winners); n > 0 && winners[n-1].ID == winner.ID && !winner.Pledge {
+		unit = winners[n-1].Unit + 1
+	}
+	for range units {
+		winner.Unit = unit
+		winners = append(winners, winner)
+		unit++
+	}
+	return winners
+}
+
 const PlaceBidError = "Unable to place bid. Try again."
 
+const PledgeError = "Unable to pledge. Try again."
+
 const EventBid webauth.EventName = "bid"
 
-func (db BidDB) PlaceBid(id int, bidAmount float64, userName string) (BidResult, error) {
+// PlaceBid places a bid of up to bidAmount for userName on item id. Bids
+// are placed automatically on behalf of the bidder up to bidAmount.
+//
+// The bid is rejected unless placed within the times of the item and its
+// auction, and a late bid extends the closing time of the item.
+func (db BidDB) PlaceBid(id int, bidAmount Money, userName string) (BidResult, error) {
+	return db.PlaceUnitsBid(id, bidAmount, 1, userName)
+}
+
+// PlaceUnitsBid places a bid of bidAmount for each of units units of item
+// id for userName. The units must be one unless the item has a quantity
+// greater than one, which is not bid automatically.
+func (db BidDB) PlaceUnitsBid(id int, bidAmount Money, units int, userName string) (BidResult, error) {
 	var bidResult BidResult
 
 	if db.sqlDB == nil {
 		return bidResult, ErrInvalidDB
 	}
 
-	row := db.sqlDB.QueryRow("CALL placeBid(?, ?, ?)", id, bidAmount, userName)
-	err := row.Scan(&bidResult.BidPlaced, &bidResult.Message, &bidResult.PriorBidder)
+	row := db.sqlDB.QueryRow("CALL placeBid(?, ?, ?, ?)", id, bidAmount, units, userName)
+	err := row.Scan(&bidResult.BidPlaced, &bidResult.Message, &bidResult.PriorBidder, &bidResult.HighBidder, &bidResult.CurrentBid)
 	if err != nil {
 		bidResult.Message = PlaceBidError
 		return bidResult, err
@@ -219,6 +1199,112 @@ func (db BidDB) PlaceBid(id int, bidAmount float64, userName string) (BidResult,
 	return bidResult, err
 }
 
+// BuyNow buys item id for userName at its buy now price, which closes
+// bidding on the item.
+func (db BidDB) BuyNow(id int, userName string) (BidResult, error) {
+	var bidResult BidResult
+
+	if db.sqlDB == nil {
+		return bidResult, ErrInvalidDB
+	}
+
+	row := db.sqlDB.QueryRow("CALL buyNow(?, ?)", id, userName)
+	err := row.Scan(&bidResult.BidPlaced, &bidResult.Message, &bidResult.PriorBidder, &bidResult.HighBidder, &bidResult.CurrentBid)
+	if err != nil {
+		bidResult.Message = PlaceBidError
+		return bidResult, err
+	}
+
+	db.sqlDB.WriteEvent(EventBid, true, userName, bidResult.Message)
+
+	return bidResult, err
+}
+
+const EventPledge webauth.EventName = "pledge"
+
+// Pledge pledges amount to pledge item id for userName. Any number of
+// pledges are accepted while the item is open.
+func (db BidDB) Pledge(id int, amount Money, userName string) (PledgeResult, error) {
+	var pledgeResult PledgeResult
+
+	if db.sqlDB == nil {
+		return pledgeResult, ErrInvalidDB
+	}
+
+	row := db.sqlDB.QueryRow("CALL placePledge(?, ?, ?)", id, amount, userName)
+	err := row.Scan(&pledgeResult.Pledged, &pledgeResult.Message, &pledgeResult.Total)
+	if err != nil {
+		pledgeResult.Message = PledgeError
+		return pledgeResult, err
+	}
+
+	db.sqlDB.WriteEvent(EventPledge, true, userName, pledgeResult.Message)
+
+	return pledgeResult, err
+}
+
+// GetWinningBids returns the winning bids for item id, highest first.
+func (db BidDB) GetWinningBids(id int) ([]WinningBid, error) {
+	if db.sqlDB == nil {
+		return nil, ErrInvalidDB
+	}
+
+	return getWinningBids(db.sqlDB, id)
+}
+
+// getWinningBids returns the winning bids for item id using q.
+func getWinningBids(q querier, id int) ([]WinningBid, error) {
+	var bids []WinningBid
+	var err error
+
+	qry := "SELECT created, bidder, amount, units FROM winning_bids WHERE id = ? ORDER BY amount DESC, created"
+
+	rows, err := q.Query(qry, id)
+	if err != nil {
+		return bids, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bid WinningBid
+
+		err = rows.Scan(&bid.Created, &bid.Bidder, &bid.Amount, &bid.Units)
+		if err != nil {
+			return bids, err
+		}
+
+		bids = append(bids, bid)
+	}
+	err = rows.Err()
+	if err != nil {
+		return bids, err
+	}
+
+	return bids, err
+}
+
+// GetMaxBid returns the maximum bid of bidder for item id, or zero if the
+// bidder has not placed a bid on the item.
+func (db BidDB) GetMaxBid(id int, bidder string) (Money, error) {
+	var amount Money
+
+	if db.sqlDB == nil {
+		return amount, ErrInvalidDB
+	}
+
+	qry := "SELECT amount FROM max_bids WHERE id = ? AND bidder = ?"
+
+	err := db.sqlDB.QueryRow(qry, id, bidder).Scan(&amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Money{}, nil
+		}
+		return amount, err
+	}
+
+	return amount, err
+}
+
 var ErrInvalidItem = errors.New("invalid item")
 
 func (db BidDB) UpdateItem(item Item) (int64, error) {
@@ -231,13 +1317,16 @@ func (db BidDB) UpdateItem(item Item) (int64, error) {
 		item.Title,
 		item.Description,
 		item.Artist,
-		item.ImageFileName,
 	) {
 		return 0, ErrInvalidItem
 	}
 
-	update := "UPDATE items SET title = ?, description = ?, openingBid = ?, minBidIncr = ?, artist = ?, imageFileName = ? WHERE id = ?"
-	result, err := db.sqlDB.Exec(update, item.Title, item.Description, item.OpeningBid, item.MinBidIncr, item.Artist, item.ImageFileName, item.ID)
+	if !item.Valid() {
+		return 0, ErrInvalidItem
+	}
+
+	update := "UPDATE items SET auctionId = ?, itemType = ?, title = ?, description = ?, openingBid = ?, minBidIncr = ?, incrSchedule = ?, quantity = ?, reservePrice = ?, buyNowPrice = ?, pledgeLevels = ?, artist = ?, imageFileName = ?, opensAt = ?, closesAt = ? WHERE id = ?"
+	result, err := db.sqlDB.Exec(update, item.AuctionID, item.ItemType, item.Title, item.Description, item.OpeningBid, item.MinBidIncr, item.IncrSchedule, item.Quantity, item.ReservePrice, item.BuyNowPrice, FormatPledgeLevels(item.PledgeLevels), item.Artist, item.ImageFileName, item.OpensAt, item.ClosesAt, item.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -252,22 +1341,37 @@ func (db BidDB) UpdateItem(item Item) (int64, error) {
 
 var ErrCreateFailed = errors.New("create failed")
 
-func (db BidDB) CreateItem(item Item) (int64, error) {
-	if db.sqlDB == nil {
-		return 0, ErrInvalidDB
-	}
+const insertItem = "INSERT INTO items(auctionId, itemType, title, description, openingBid, minBidIncr, incrSchedule, quantity, reservePrice, buyNowPrice, pledgeLevels, artist, imageFileName, opensAt, closesAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// insertItemArgs returns the arguments of insertItem for item.
+func insertItemArgs(item Item) []any {
+	return []any{item.AuctionID, item.ItemType, item.Title, item.Description, item.OpeningBid, item.MinBidIncr, item.IncrSchedule, item.Quantity, item.ReservePrice, item.BuyNowPrice, FormatPledgeLevels(item.PledgeLevels), item.Artist, item.ImageFileName, item.OpensAt, item.ClosesAt}
+}
 
+// validNewItem returns true if item can be created.
+func validNewItem(item Item) bool {
 	// require non-empty strings for some fields
 	if AnyEmpty(
 		item.Title,
 		item.Description,
 		item.Artist,
 	) {
+		return false
+	}
+
+	return item.Valid()
+}
+
+func (db BidDB) CreateItem(item Item) (int64, error) {
+	if db.sqlDB == nil {
+		return 0, ErrInvalidDB
+	}
+
+	if !validNewItem(item) {
 		return 0, ErrInvalidItem
 	}
 
-	insert := "INSERT INTO items(title, description, openingBid, minBidIncr, artist, imageFileName) VALUES (?, ?, ?, ?, ?, ?)"
-	result, err := db.sqlDB.Exec(insert, item.Title, item.Description, item.OpeningBid, item.MinBidIncr, item.Artist, item.ImageFileName)
+	result, err := db.sqlDB.Exec(insertItem, insertItemArgs(item)...)
 	if err != nil {
 		return 0, fmt.Errorf("%w: %v", ErrCreateFailed, err)
 	}
@@ -361,18 +1465,19 @@ type ItemsWithBidsResult struct {
 	Title         string
 	ItemCreated   time.Time
 	Description   string
-	OpeningBid    float64
-	MinBidIncr    float64
+	OpeningBid    Money
+	MinBidIncr    Money
 	Artist        string
 	ImageFileName string
 	BidCreated    time.Time
 	Bidder        string
-	Amount        float64
+	Amount        Money
 	FullName      string
 	Email         string
 }
 
-func (db BidDB) GetItemsWithBids() ([]ItemWithBids, error) {
+// GetItemsWithBids returns the items in auction auctionID that have bids.
+func (db BidDB) GetItemsWithBids(auctionID int) ([]ItemWithBids, error) {
 	var items []ItemWithBids
 	var err error
 
@@ -380,9 +1485,9 @@ func (db BidDB) GetItemsWithBids() ([]ItemWithBids, error) {
 		return items, ErrInvalidDB
 	}
 
-	qry := "SELECT items.id, items.title, items.created AS itemCreated, items.description, items.openingBid, items.minBidIncr, items.artist, items.imageFileName, bids.created AS bidCreated, bids.bidder, bids.amount, users.fullName, users.email FROM items INNER JOIN bids ON items.id = bids.id INNER JOIN users ON bids.bidder = users.UserName ORDER BY items.id, bids.created DESC"
+	qry := "SELECT items.id, items.title, items.created AS itemCreated, items.description, items.openingBid, items.minBidIncr, items.artist, items.imageFileName, bids.created AS bidCreated, bids.bidder, bids.amount, users.fullName, users.email FROM items INNER JOIN bids ON items.id = bids.id INNER JOIN users ON bids.bidder = users.UserName WHERE items.auctionId = ? ORDER BY items.id, bids.created DESC"
 
-	rows, err := db.sqlDB.Query(qry)
+	rows, err := db.sqlDB.Query(qry, auctionID)
 	if err != nil {
 		return items, err
 	}
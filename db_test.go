@@ -5,11 +5,13 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/bnixon67/webapp/webauth"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -21,29 +23,36 @@ var (
 
 	testID2 = Item{
 		ID:            2,
+		AuctionID:     1,
+		ItemType:      ItemTypeAuction,
 		Title:         "Item Test",
 		Created:       ct.Add(time.Hour * 2),
 		Description:   "Item to test GetItem",
-		OpeningBid:    10.0,
-		MinBidIncr:    2.0,
+		OpeningBid:    Cents(1000),
+		MinBidIncr:    Cents(200),
+		Quantity:      1,
 		Artist:        "ARTIST",
 		ImageFileName: "FILENAME",
-		MinBid:        10.0,
+		MinBid:        Cents(1000),
 	}
 
 	testID3 = Item{
 		ID:            3,
+		AuctionID:     1,
+		ItemType:      ItemTypeAuction,
 		Title:         "Item Test with Bid",
 		Created:       ct.Add(time.Hour * 3),
 		Modified:      &mt,
 		Bidder:        mb,
 		Description:   "Item to test GetItem with Bid",
-		OpeningBid:    5.0,
-		MinBidIncr:    1.0,
-		CurrentBid:    15.0,
+		OpeningBid:    Cents(500),
+		MinBidIncr:    Cents(100),
+		Quantity:      1,
+		CurrentBid:    Cents(1500),
+		UnitsBid:      1,
 		Artist:        "Art",
 		ImageFileName: "File",
-		MinBid:        16.0,
+		MinBid:        Cents(1600),
 	}
 )
 
@@ -76,13 +85,11 @@ func TestGetItem(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err := app.BidDB.GetItem(0)
+	invalidDB := BidDB{}
+	_, err := invalidDB.GetItem(0)
 	if err != ErrInvalidDB {
 		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestGetConfigItem(t *testing.T) {
@@ -118,13 +125,11 @@ func TestGetConfigItem(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err := app.BidDB.GetConfigItem("")
+	invalidDB := BidDB{}
+	_, err := invalidDB.GetConfigItem("")
 	if err != ErrInvalidDB {
 		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestGetItems(t *testing.T) {
@@ -133,7 +138,7 @@ func TestGetItems(t *testing.T) {
 		t.Fatalf("cannot create AppForTest")
 	}
 
-	got, err := app.BidDB.GetItems()
+	got, err := app.BidDB.GetItems(1)
 	if err != nil {
 		t.Fatalf("got err '%v' want '%v'", err, nil)
 	}
@@ -154,13 +159,11 @@ func TestGetItems(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.GetItems()
+	invalidDB := BidDB{}
+	_, err = invalidDB.GetItems(1)
 	if err != ErrInvalidDB {
 		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestGetWinners(t *testing.T) {
@@ -169,7 +172,7 @@ func TestGetWinners(t *testing.T) {
 		t.Fatalf("cannot create AppForTest")
 	}
 
-	got, err := app.BidDB.GetWinners()
+	got, err := app.BidDB.GetWinners(1)
 	if err != nil {
 		t.Errorf("got err '%v' want '%v'", err, nil)
 	}
@@ -180,11 +183,14 @@ func TestGetWinners(t *testing.T) {
 		ID:         3,
 		Title:      "Item Test with Bid",
 		Artist:     "Art",
-		CurrentBid: 15.0,
+		Quantity:   1,
+		Unit:       1,
+		CurrentBid: Cents(1500),
 		Modified:   modified,
 		ModifiedBy: "test",
 		Email:      "test@user",
 		FullName:   "Test User",
+		ReserveMet: true,
 	}
 	found := false
 	for idx := range got {
@@ -201,13 +207,11 @@ func TestGetWinners(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.GetWinners()
+	invalidDB := BidDB{}
+	_, err = invalidDB.GetWinners(1)
 	if err != ErrInvalidDB {
 		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestPlaceBid(t *testing.T) {
@@ -222,16 +226,24 @@ func TestPlaceBid(t *testing.T) {
 		t.Fatalf("GetItem(1) failed: %v", err)
 	}
 
+	// auction that closes soon, so bids extend the closing time
+	soft, err := app.BidDB.GetAuction("soft")
+	if err != nil {
+		t.Fatalf("GetAuction(\"soft\") failed: %v", err)
+	}
+
+	now := time.Now()
+
 	cases := []struct {
 		id        int
-		bidAmount float64
+		bidAmount Money
 		bidder    string
 		want      BidResult
 		err       error
 		sleep     bool
 	}{
 		{
-			id: 0, bidAmount: 1.0, bidder: "test",
+			id: 0, bidAmount: Cents(100), bidder: "test",
 			want: BidResult{
 				BidPlaced: false,
 				Message:   "No such item",
@@ -239,7 +251,7 @@ func TestPlaceBid(t *testing.T) {
 			err: nil,
 		},
 		{
-			id: 4, bidAmount: 1.0, bidder: "test",
+			id: 4, bidAmount: Cents(100), bidder: "test",
 			want: BidResult{
 				BidPlaced: false,
 				Message:   "Display only item",
@@ -247,33 +259,170 @@ func TestPlaceBid(t *testing.T) {
 			err: nil,
 		},
 		{
-			id: 3, bidAmount: 1.0, bidder: "test",
+			id: 3, bidAmount: Cents(100), bidder: "test",
 			want: BidResult{
 				BidPlaced:   false,
 				Message:     "Bid too low",
 				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(1500),
 			},
 			err: nil,
 		},
 		{
-			id: 1, bidAmount: id1.MinBid, bidder: "test",
+			id: 1, bidAmount: Cents(10000), bidder: "test",
 			want: BidResult{
 				BidPlaced:   true,
 				Message:     "Bid placed",
 				PriorBidder: "",
+				HighBidder:  "test",
+				CurrentBid:  id1.MinBid,
+			},
+			err: nil,
+		},
+		{
+			id: 1, bidAmount: Cents(9000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   false,
+				Message:     "Maximum bid must be higher",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  id1.MinBid,
 			},
 			err: nil,
 		},
 		{
-			id: 1, bidAmount: 100, bidder: "test",
+			id: 1, bidAmount: Cents(11000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Maximum bid raised",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  id1.MinBid,
+			},
+			err: nil,
+		},
+		{
+			id: 1, bidAmount: Cents(5000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Outbid by automatic bid",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(5000).Add(id1.MinBidIncr),
+			},
+			err:   nil,
+			sleep: true,
+		},
+		{
+			id: 1, bidAmount: Cents(11000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Outbid by automatic bid",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(11000),
+			},
+			err:   nil,
+			sleep: true,
+		},
+		{
+			id: 1, bidAmount: Cents(20000), bidder: "admin",
 			want: BidResult{
 				BidPlaced:   true,
 				Message:     "Bid placed",
 				PriorBidder: "test",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(11000).Add(id1.MinBidIncr),
 			},
 			err:   nil,
 			sleep: true,
 		},
+		{
+			// auction has ended
+			id: 9, bidAmount: Cents(30000), bidder: "test",
+			want: BidResult{
+				BidPlaced: false,
+				Message:   "Bidding has closed",
+			},
+			err: nil,
+		},
+		{
+			// auction has not started
+			id: 10, bidAmount: Cents(10000), bidder: "test",
+			want: BidResult{
+				BidPlaced: false,
+				Message:   "Bidding has not started",
+			},
+			err: nil,
+		},
+		{
+			// closes before the auction ends
+			id: 8, bidAmount: Cents(10000), bidder: "test",
+			want: BidResult{
+				BidPlaced: false,
+				Message:   "Bidding has closed",
+			},
+			err: nil,
+		},
+		{
+			id: 7, bidAmount: Cents(2000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "",
+				HighBidder:  "test",
+				CurrentBid:  Cents(1000),
+			},
+			err: nil,
+		},
+		{
+			id: 7, bidAmount: Cents(3000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "test",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(2100),
+			},
+			err: nil,
+		},
+		{
+			// maximum below the reserve
+			id: 11, bidAmount: Cents(2000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "",
+				HighBidder:  "test",
+				CurrentBid:  Cents(1000),
+			},
+			err: nil,
+		},
+		{
+			// maximum above the reserve raises the bid to the reserve
+			id: 11, bidAmount: Cents(6000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "test",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(5000),
+			},
+			err: nil,
+		},
+		{
+			// raised maximum above the reserve raises the bid
+			id: 12, bidAmount: Cents(15000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Maximum bid raised",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(10000),
+			},
+			err: nil,
+		},
 	}
 
 	for _, tc := range cases {
@@ -282,24 +431,556 @@ func TestPlaceBid(t *testing.T) {
 		}
 		got, err := app.BidDB.PlaceBid(tc.id, tc.bidAmount, tc.bidder)
 		if !errors.Is(err, tc.err) {
-			t.Errorf("PlaceBid(%d, %f, %q)\ngot err '%v' want '%v'",
+			t.Errorf("PlaceBid(%d, %v, %q)\ngot err '%v' want '%v'",
 				tc.id, tc.bidAmount, tc.bidder, err, tc.err)
 		}
 		if !reflect.DeepEqual(got, tc.want) {
-			t.Errorf("PlaceBid(%d, %f, %q)\n got %s\nwant %s",
+			t.Errorf("PlaceBid(%d, %v, %q)\n got %s\nwant %s",
 				tc.id, tc.bidAmount, tc.bidder,
 				AsJson(got), AsJson(tc.want))
 		}
 	}
 
+	// late bid should have extended the closing time
+	id7, err := app.BidDB.GetItem(7)
+	if err != nil {
+		t.Fatalf("GetItem(7) failed: %v", err)
+	}
+	minEnd := now.Add(soft.SoftCloseExtension - time.Minute)
+	if end := soft.ItemClosesAt(id7); end.Before(minEnd) {
+		t.Errorf("EndTime = %v, want after %v", end, minEnd)
+	}
+
+	// test for invalid DB
+	invalidDB := BidDB{}
+	_, err = invalidDB.PlaceBid(0, Cents(0), "test")
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+}
+
+func TestBuyNow(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	cases := []struct {
+		id     int
+		bidder string
+		want   BidResult
+	}{
+		{
+			id: 4, bidder: "test",
+			want: BidResult{Message: "Display only item"},
+		},
+		{
+			id: 9, bidder: "test",
+			want: BidResult{Message: "Bidding has closed"},
+		},
+		{
+			// no buy now price
+			id: 2, bidder: "test",
+			want: BidResult{Message: "Buy now not available"},
+		},
+		{
+			// current bid is above the cutoff
+			id: 14, bidder: "admin",
+			want: BidResult{
+				Message:     "Buy now not available",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(3000),
+			},
+		},
+		{
+			id: 13, bidder: "test",
+			want: BidResult{
+				BidPlaced:  true,
+				Message:    "Item bought",
+				HighBidder: "test",
+				CurrentBid: Cents(10000),
+			},
+		},
+		{
+			id: 13, bidder: "admin",
+			want: BidResult{
+				Message:     "Item already sold",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(10000),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.BuyNow(tc.id, tc.bidder)
+		if err != nil {
+			t.Errorf("BuyNow(%d, %q) got err '%v' want '%v'",
+				tc.id, tc.bidder, err, nil)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("BuyNow(%d, %q)\n got %s\nwant %s",
+				tc.id, tc.bidder, AsJson(got), AsJson(tc.want))
+		}
+	}
+
+	// bidding is closed once bought
+	got, err := app.BidDB.PlaceBid(13, Cents(20000), "admin")
+	if err != nil || got.BidPlaced || got.Message != "Item already sold" {
+		t.Errorf("PlaceBid after BuyNow got %s err '%v'", AsJson(got), err)
+	}
+
+	// test for invalid DB
+	invalidDB := BidDB{}
+	_, err = invalidDB.BuyNow(0, "test")
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+}
+
+func TestPlaceUnitsBid(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	cases := []struct {
+		id        int
+		bidAmount Money
+		units     int
+		bidder    string
+		want      BidResult
+	}{
+		{
+			// single unit item
+			id: 3, bidAmount: Cents(10000), units: 2, bidder: "admin",
+			want: BidResult{
+				Message:     "Invalid quantity",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(1500),
+			},
+		},
+		{
+			// unbid unit at the opening bid
+			id: 15, bidAmount: Cents(1500), units: 1, bidder: "admin",
+			want: BidResult{
+				BidPlaced:  true,
+				Message:    "Bid placed",
+				HighBidder: "admin",
+				CurrentBid: Cents(1500),
+			},
+		},
+		{
+			// must beat both the lowest winning bid and own bid
+			id: 15, bidAmount: Cents(1800), units: 1, bidder: "test",
+			want: BidResult{
+				Message:     "Bid too low",
+				PriorBidder: "admin",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(1500),
+			},
+		},
+		{
+			// more units than the quantity
+			id: 15, bidAmount: Cents(3000), units: 4, bidder: "test",
+			want: BidResult{
+				Message:     "Invalid quantity",
+				PriorBidder: "admin",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(1500),
+			},
+		},
+		{
+			// takes a unit from test
+			id: 15, bidAmount: Cents(2500), units: 2, bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "test",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(2000),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.PlaceUnitsBid(tc.id, tc.bidAmount, tc.units, tc.bidder)
+		if err != nil {
+			t.Errorf("PlaceUnitsBid(%d, %v, %d, %q) got err '%v' want '%v'",
+				tc.id, tc.bidAmount, tc.units, tc.bidder, err, nil)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("PlaceUnitsBid(%d, %v, %d, %q)\n got %s\nwant %s",
+				tc.id, tc.bidAmount, tc.units, tc.bidder,
+				AsJson(got), AsJson(tc.want))
+		}
+	}
+
+	bids, err := app.BidDB.GetWinningBids(15)
+	if err != nil {
+		t.Fatalf("GetWinningBids(15) failed: %v", err)
+	}
+	var gotBids []string
+	for _, bid := range bids {
+		gotBids = append(gotBids, fmt.Sprintf("%s %v %d", bid.Bidder, bid.Amount, bid.Units))
+	}
+	wantBids := []string{"admin 25.00 2", "test 20.00 1"}
+	if !reflect.DeepEqual(gotBids, wantBids) {
+		t.Errorf("GetWinningBids(15) got %q want %q", gotBids, wantBids)
+	}
+
+	// one winner for each unit
+	winners, err := app.BidDB.GetWinners(1)
+	if err != nil {
+		t.Fatalf("GetWinners(1) failed: %v", err)
+	}
+	var gotWinners []string
+	for _, winner := range winners {
+		if winner.ID == 15 {
+			gotWinners = append(gotWinners, fmt.Sprintf("%d %s %v", winner.Unit, winner.ModifiedBy, winner.CurrentBid))
+		}
+	}
+	wantWinners := []string{"1 admin 25.00", "2 admin 25.00", "3 test 20.00"}
+	if !reflect.DeepEqual(gotWinners, wantWinners) {
+		t.Errorf("GetWinners(1) item 15 got %q want %q", gotWinners, wantWinners)
+	}
+
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.PlaceBid(0, 0, "test")
+	invalidDB := BidDB{}
+	_, err = invalidDB.PlaceUnitsBid(0, Cents(0), 1, "test")
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+	_, err = invalidDB.GetWinningBids(15)
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+}
+
+func TestPlaceSealedBid(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	// other bids are never returned for a sealed item
+	cases := []struct {
+		id        int
+		bidAmount Money
+		bidder    string
+		want      BidResult
+	}{
+		{
+			id: 17, bidAmount: Cents(500), bidder: "admin",
+			want: BidResult{Message: "Bid too low"},
+		},
+		{
+			// below the bid of test, which is hidden
+			id: 17, bidAmount: Cents(1500), bidder: "admin",
+			want: BidResult{BidPlaced: true, Message: "Bid placed"},
+		},
+		{
+			id: 17, bidAmount: Cents(1500), bidder: "admin",
+			want: BidResult{Message: "Bid must be higher"},
+		},
+		{
+			id: 17, bidAmount: Cents(3000), bidder: "admin",
+			want: BidResult{BidPlaced: true, Message: "Bid raised"},
+		},
+		{
+			id: 17, bidAmount: Cents(1000), bidder: "test",
+			want: BidResult{Message: "Bid must be higher"},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.PlaceBid(tc.id, tc.bidAmount, tc.bidder)
+		if err != nil {
+			t.Errorf("PlaceBid(%d, %v, %q) got err '%v' want '%v'",
+				tc.id, tc.bidAmount, tc.bidder, err, nil)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("PlaceBid(%d, %v, %q)\n got %s\nwant %s",
+				tc.id, tc.bidAmount, tc.bidder,
+				AsJson(got), AsJson(tc.want))
+		}
+	}
+
+	// the highest bid wins
+	item, err := app.BidDB.GetItem(17)
+	if err != nil {
+		t.Fatalf("GetItem(17) failed: %v", err)
+	}
+	if !item.IsSealed() || item.Bidder != "admin" || item.CurrentBid != Cents(3000) {
+		t.Errorf("GetItem(17) got %s", AsJson(item))
+	}
+}
+
+func TestBidIncrementSchedule(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	standard := []Increment{
+		{From: Cents(0), To: Cents(10000), Amount: Cents(500)},
+		{From: Cents(10000), To: Cents(50000), Amount: Cents(1000)},
+		{From: Cents(50000), Amount: Cents(2500)},
+	}
+
+	schedules, err := app.BidDB.GetIncrementSchedules()
+	if err != nil {
+		t.Fatalf("GetIncrementSchedules() failed: %v", err)
+	}
+	if !reflect.DeepEqual(schedules["standard"], standard) {
+		t.Errorf("GetIncrementSchedules() standard got %s want %s",
+			AsJson(schedules["standard"]), AsJson(standard))
+	}
+	if len(schedules) != 2 {
+		t.Errorf("GetIncrementSchedules() got %d schedules want 2", len(schedules))
+	}
+
+	// item 18 has its own schedule, 19 uses its auction's, and 20
+	// overrides its auction's
+	items := []struct {
+		id         int
+		increments []Increment
+		minBid     Money
+	}{
+		{id: 18, increments: standard, minBid: Cents(13000)},
+		{id: 19, increments: standard, minBid: Cents(5000)},
+		{id: 20, increments: []Increment{{From: Cents(0), Amount: Cents(100)}}, minBid: Cents(5000)},
+	}
+	for _, tc := range items {
+		item, err := app.BidDB.GetItem(tc.id)
+		if err != nil {
+			t.Fatalf("GetItem(%d) failed: %v", tc.id, err)
+		}
+		if !reflect.DeepEqual(item.Increments, tc.increments) || item.MinBid != tc.minBid {
+			t.Errorf("GetItem(%d) got increments %s minBid %v want %s %v",
+				tc.id, AsJson(item.Increments), item.MinBid,
+				AsJson(tc.increments), tc.minBid)
+		}
+	}
+
+	// the database must use the same increments
+	cases := []struct {
+		id        int
+		bidAmount Money
+		bidder    string
+		want      BidResult
+	}{
+		{
+			id: 18, bidAmount: Cents(12500), bidder: "admin",
+			want: BidResult{
+				Message:     "Bid too low",
+				PriorBidder: "test",
+				HighBidder:  "test",
+				CurrentBid:  Cents(12000),
+			},
+		},
+		{
+			id: 18, bidAmount: Cents(20000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "test",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(13000),
+			},
+		},
+		{
+			id: 19, bidAmount: Cents(5000), bidder: "admin",
+			want: BidResult{
+				BidPlaced:  true,
+				Message:    "Bid placed",
+				HighBidder: "admin",
+				CurrentBid: Cents(5000),
+			},
+		},
+		{
+			id: 19, bidAmount: Cents(5400), bidder: "test",
+			want: BidResult{
+				Message:     "Bid too low",
+				PriorBidder: "admin",
+				HighBidder:  "admin",
+				CurrentBid:  Cents(5000),
+			},
+		},
+		{
+			id: 19, bidAmount: Cents(6000), bidder: "test",
+			want: BidResult{
+				BidPlaced:   true,
+				Message:     "Bid placed",
+				PriorBidder: "admin",
+				HighBidder:  "test",
+				CurrentBid:  Cents(5500),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.PlaceBid(tc.id, tc.bidAmount, tc.bidder)
+		if err != nil {
+			t.Errorf("PlaceBid(%d, %v, %q) got err '%v' want '%v'",
+				tc.id, tc.bidAmount, tc.bidder, err, nil)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("PlaceBid(%d, %v, %q)\n got %s\nwant %s",
+				tc.id, tc.bidAmount, tc.bidder,
+				AsJson(got), AsJson(tc.want))
+		}
+	}
+
+	item, err := app.BidDB.GetItem(18)
+	if err != nil {
+		t.Fatalf("GetItem(18) failed: %v", err)
+	}
+	if item.MinBid != Cents(14000) {
+		t.Errorf("GetItem(18) got minBid %v want 140.00", item.MinBid)
+	}
+
+	// test for invalid DB
+	invalidDB := BidDB{}
+	_, err = invalidDB.GetIncrements("standard")
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+	_, err = invalidDB.GetIncrementSchedules()
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+}
+
+func TestPledge(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	cases := []struct {
+		id      int
+		amount  Money
+		pledger string
+		want    PledgeResult
+	}{
+		{
+			id: 999, amount: Cents(10000), pledger: "test",
+			want: PledgeResult{Message: "No such item"},
+		},
+		{
+			id: 2, amount: Cents(10000), pledger: "test",
+			want: PledgeResult{Message: "Not a pledge item"},
+		},
+		{
+			id: 16, amount: Cents(0), pledger: "admin",
+			want: PledgeResult{Message: "Pledge too low", Total: Cents(10000)},
+		},
+		{
+			id: 16, amount: Cents(25000), pledger: "admin",
+			want: PledgeResult{
+				Pledged: true, Message: "Pledge placed", Total: Cents(35000),
+			},
+		},
+		{
+			// any number of pledges of any amount
+			id: 16, amount: Cents(4250), pledger: "admin",
+			want: PledgeResult{
+				Pledged: true, Message: "Pledge placed", Total: Cents(39250),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.Pledge(tc.id, tc.amount, tc.pledger)
+		if err != nil {
+			t.Errorf("Pledge(%d, %v, %q) got err '%v' want '%v'",
+				tc.id, tc.amount, tc.pledger, err, nil)
+		}
+		if got != tc.want {
+			t.Errorf("Pledge(%d, %v, %q)\n got %s\nwant %s",
+				tc.id, tc.amount, tc.pledger,
+				AsJson(got), AsJson(tc.want))
+		}
+	}
+
+	// pledge items do not accept bids
+	bidResult, err := app.BidDB.PlaceBid(16, Cents(100000), "test")
+	if err != nil || bidResult.BidPlaced || bidResult.Message != "Pledge only item" {
+		t.Errorf("PlaceBid(16) got %s err '%v'", AsJson(bidResult), err)
+	}
+
+	item, err := app.BidDB.GetItem(16)
+	if err != nil {
+		t.Fatalf("GetItem(16) failed: %v", err)
+	}
+	if !item.IsPledge() || item.PledgeTotal != Cents(39250) || item.Pledges != 3 ||
+		!reflect.DeepEqual(item.PledgeLevels, []Money{Cents(10000), Cents(25000), Cents(50000)}) {
+		t.Errorf("GetItem(16) got %s", AsJson(item))
+	}
+
+	// one winner for each pledge
+	winners, err := app.BidDB.GetWinners(1)
+	if err != nil {
+		t.Fatalf("GetWinners(1) failed: %v", err)
+	}
+	var got []string
+	for _, winner := range winners {
+		if winner.ID == 16 && winner.Pledge {
+			got = append(got, fmt.Sprintf("%s %v", winner.ModifiedBy, winner.CurrentBid))
+		}
+	}
+	want := []string{"admin 250.00", "test 100.00", "admin 42.50"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWinners(1) item 16 got %q want %q", got, want)
+	}
+
+	// test for invalid DB
+	invalidDB := BidDB{}
+	_, err = invalidDB.Pledge(0, Cents(0), "test")
+	if err != ErrInvalidDB {
+		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
+	}
+}
+
+func TestGetMaxBid(t *testing.T) {
+	app := AppForTest(t)
+	if app == nil {
+		t.Fatalf("cannot create AppForTest")
+	}
+
+	cases := []struct {
+		id     int
+		bidder string
+		want   Money
+	}{
+		{id: 0, bidder: "test", want: Cents(0)},
+		{id: 2, bidder: "test", want: Cents(0)},
+		{id: 1, bidder: "nosuchuser", want: Cents(0)},
+		{id: 1, bidder: "test", want: Cents(11000)},
+		{id: 1, bidder: "admin", want: Cents(20000)},
+	}
+
+	for _, tc := range cases {
+		got, err := app.BidDB.GetMaxBid(tc.id, tc.bidder)
+		if err != nil {
+			t.Errorf("GetMaxBid(%d, %q) got err '%v' want '%v'",
+				tc.id, tc.bidder, err, nil)
+		}
+		if got != tc.want {
+			t.Errorf("GetMaxBid(%d, %q) got %v want %v",
+				tc.id, tc.bidder, got, tc.want)
+		}
+	}
+
+	// test for invalid DB
+	invalidDB := BidDB{}
+	_, err := invalidDB.GetMaxBid(0, "test")
 	if err != ErrInvalidDB {
 		t.Errorf("got err '%v' want '%v'", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestUpdateItem(t *testing.T) {
@@ -313,7 +994,33 @@ func TestUpdateItem(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetItem(1) failed: %v", err)
 	}
-	testItem.MinBidIncr += 1
+	testItem.MinBidIncr = testItem.MinBidIncr.Add(Cents(100))
+
+	opensAt := time.Date(2023, time.January, 1, 18, 0, 0, 0, time.UTC)
+	closesAt := opensAt.Add(time.Hour)
+	timedItem := testItem
+	timedItem.OpensAt = &opensAt
+	timedItem.ClosesAt = &closesAt
+	invalidItem := testItem
+	invalidItem.OpensAt = &closesAt
+	invalidItem.ClosesAt = &opensAt
+	reserveItem := testItem
+	reserveItem.ReservePrice = Cents(2500)
+	negativeReserveItem := testItem
+	negativeReserveItem.ReservePrice = Cents(-100)
+	quantityItem := testItem
+	quantityItem.Quantity = 3
+	quantityBuyNowItem := quantityItem
+	quantityBuyNowItem.BuyNowPrice = testItem.OpeningBid.Add(Cents(1000))
+	pledgeItem := testItem
+	pledgeItem.ItemType = ItemTypePledge
+	pledgeItem.PledgeLevels = []Money{Cents(5000), Cents(10000)}
+	levelsItem := testItem
+	levelsItem.PledgeLevels = []Money{Cents(5000), Cents(10000)}
+	buyNowItem := testItem
+	buyNowItem.BuyNowPrice = testItem.OpeningBid.Add(Cents(1000))
+	lowBuyNowItem := testItem
+	lowBuyNowItem.BuyNowPrice = Cents(testItem.OpeningBid.Cents / 2)
 
 	cases := []struct {
 		item Item
@@ -324,6 +1031,46 @@ func TestUpdateItem(t *testing.T) {
 			item: testItem,
 			want: 1, err: nil,
 		},
+		{
+			item: timedItem,
+			want: 1, err: nil,
+		},
+		{
+			item: invalidItem,
+			want: 0, err: ErrInvalidItem,
+		},
+		{
+			item: reserveItem,
+			want: 1, err: nil,
+		},
+		{
+			item: negativeReserveItem,
+			want: 0, err: ErrInvalidItem,
+		},
+		{
+			item: quantityItem,
+			want: 1, err: nil,
+		},
+		{
+			item: quantityBuyNowItem,
+			want: 0, err: ErrInvalidItem,
+		},
+		{
+			item: pledgeItem,
+			want: 1, err: nil,
+		},
+		{
+			item: levelsItem,
+			want: 0, err: ErrInvalidItem,
+		},
+		{
+			item: buyNowItem,
+			want: 1, err: nil,
+		},
+		{
+			item: lowBuyNowItem,
+			want: 0, err: ErrInvalidItem,
+		},
 		{
 			item: Item{},
 			want: 0, err: ErrInvalidItem,
@@ -345,7 +1092,11 @@ func TestUpdateItem(t *testing.T) {
 			want: 0, err: ErrInvalidItem,
 		},
 		{
-			item: Item{ID: 5, Title: "t", Description: "d", Artist: "a", ImageFileName: "i", Created: ct.Add(time.Hour * 5)},
+			item: Item{ID: 5, ItemType: ItemTypeAuction, Title: "t", Description: "d", Artist: "a", Quantity: 1, Created: ct.Add(time.Hour * 5)},
+			want: 1, err: nil,
+		},
+		{
+			item: Item{ID: 5, ItemType: ItemTypeAuction, Title: "t", Description: "d", Artist: "a", ImageFileName: "i", Quantity: 1, Created: ct.Add(time.Hour * 5)},
 			want: 1, err: nil,
 		},
 	}
@@ -376,13 +1127,11 @@ func TestUpdateItem(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.UpdateItem(Item{})
+	invalidDB := BidDB{}
+	_, err = invalidDB.UpdateItem(Item{})
 	if err != ErrInvalidDB {
 		t.Errorf("got err %q want %q", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestCreateItem(t *testing.T) {
@@ -392,10 +1141,12 @@ func TestCreateItem(t *testing.T) {
 	}
 
 	testItem := Item{
+		ItemType:      ItemTypeAuction,
 		Title:         "Test CreateItem",
 		Description:   "This is a test of CreateItem",
-		OpeningBid:    42,
-		MinBidIncr:    1,
+		OpeningBid:    Cents(4200),
+		MinBidIncr:    Cents(100),
+		Quantity:      1,
 		Artist:        "CreateItem Artist",
 		ImageFileName: "CreateItem.jpg",
 	}
@@ -435,13 +1186,11 @@ func TestCreateItem(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err := app.BidDB.CreateItem(Item{})
+	invalidDB := BidDB{}
+	_, err := invalidDB.CreateItem(Item{})
 	if err != ErrInvalidDB {
 		t.Errorf("got err %q want %q", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func TestGetBidsForItem(t *testing.T) {
@@ -453,13 +1202,13 @@ func TestGetBidsForItem(t *testing.T) {
 	var noBids []Bid
 
 	testBidsID3 := []Bid{
-		Bid{ID: 3, Created: bt, Bidder: "test", Amount: 15, FullName: "", Email: ""},
+		Bid{ID: 3, Created: bt, Bidder: "test", Amount: Cents(1500), FullName: "", Email: ""},
 	}
 
 	testBidsID6 := []Bid{
-		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: 7, FullName: "", Email: ""},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: 5, FullName: "", Email: ""},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: 3, FullName: "", Email: ""},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: Cents(700), FullName: "", Email: ""},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: Cents(500), FullName: "", Email: ""},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: Cents(300), FullName: "", Email: ""},
 	}
 
 	cases := []struct {
@@ -488,13 +1237,11 @@ func TestGetBidsForItem(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err := app.BidDB.CreateItem(Item{})
+	invalidDB := BidDB{}
+	_, err := invalidDB.CreateItem(Item{})
 	if err != ErrInvalidDB {
 		t.Errorf("got err %q want %q", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func containsBid(bids []Bid, bid Bid) bool {
@@ -518,10 +1265,10 @@ func TestGetBids(t *testing.T) {
 	}
 
 	want := []Bid{
-		Bid{ID: 3, Created: bt, Bidder: "test", Amount: 15, FullName: "Test User", Email: "test@user"},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: 7, FullName: "Test User", Email: "test@user"},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: 5, FullName: "Test User", Email: "test@user"},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: 3, FullName: "Test User", Email: "test@user"},
+		Bid{ID: 3, Created: bt, Bidder: "test", Amount: Cents(1500), FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: Cents(700), FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: Cents(500), FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: Cents(300), FullName: "Test User", Email: "test@user"},
 	}
 
 	// check if want elements are in got
@@ -533,13 +1280,11 @@ func TestGetBids(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.GetBids()
+	invalidDB := BidDB{}
+	_, err = invalidDB.GetBids()
 	if err != ErrInvalidDB {
 		t.Errorf("got err %q want %q", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 func containsItemWithBids(items []ItemWithBids, item ItemWithBids) bool {
@@ -557,21 +1302,21 @@ func TestGetItemsWithBids(t *testing.T) {
 		t.Fatalf("cannot create AppForTest")
 	}
 
-	got, err := app.BidDB.GetItemsWithBids()
+	got, err := app.BidDB.GetItemsWithBids(1)
 	if err != nil {
 		t.Errorf("GetBids failed: %v", err)
 	}
 
 	bidsID3 := []Bid{
 		Bid{
-			ID: 3, Created: bt, Bidder: "test", Amount: 15,
+			ID: 3, Created: bt, Bidder: "test", Amount: Cents(1500),
 			FullName: "Test User", Email: "test@user",
 		},
 	}
 	bidsID6 := []Bid{
-		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: 7, FullName: "Test User", Email: "test@user"},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: 5, FullName: "Test User", Email: "test@user"},
-		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: 3, FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 3), Bidder: "test", Amount: Cents(700), FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 2), Bidder: "test", Amount: Cents(500), FullName: "Test User", Email: "test@user"},
+		Bid{ID: 6, Created: bt.Add(time.Hour * 1), Bidder: "test", Amount: Cents(300), FullName: "Test User", Email: "test@user"},
 	}
 
 	want := []ItemWithBids{
@@ -579,7 +1324,7 @@ func TestGetItemsWithBids(t *testing.T) {
 			ID: 3, Title: "Item Test with Bid",
 			Created:     ct.Add(time.Hour * 3),
 			Description: "Item to test GetItem with Bid",
-			OpeningBid:  5, MinBidIncr: 1,
+			OpeningBid:  Cents(500), MinBidIncr: Cents(100),
 			Artist: "Art", ImageFileName: "File",
 			Bids: bidsID3,
 		},
@@ -587,7 +1332,7 @@ func TestGetItemsWithBids(t *testing.T) {
 			ID: 6, Title: "Item Test with 3 Bids",
 			Created:     ct.Add(time.Hour * 6),
 			Description: "Item to test GetItem with 3 Bids",
-			OpeningBid:  3, MinBidIncr: 2,
+			OpeningBid:  Cents(300), MinBidIncr: Cents(200),
 			Artist: "Art 3 Bid", ImageFileName: "File 3 Bid",
 			Bids: bidsID6,
 		},
@@ -602,13 +1347,11 @@ func TestGetItemsWithBids(t *testing.T) {
 	}
 
 	// test for invalid DB
-	sqlDB := app.BidDB.sqlDB
-	app.BidDB.sqlDB = nil
-	_, err = app.BidDB.GetBids()
+	invalidDB := BidDB{}
+	_, err = invalidDB.GetBids()
 	if err != ErrInvalidDB {
 		t.Errorf("got err %q want %q", err, ErrInvalidDB)
 	}
-	app.BidDB.sqlDB = sqlDB
 }
 
 /*
@@ -618,9 +1361,197 @@ func TestGetItemsWithBids(t *testing.T) {
 		t.Fatalf("cannot create AppForTest")
 	}
 
-	_, err := app.BidDB.GetItemsWithBids()
+	_, err := app.BidDB.GetItemsWithBids(1)
 	if err != nil {
 		t.Errorf("GetItemsWithBids failed: %v", err)
 	}
 }
 */
+
+func TestItemReserveMet(t *testing.T) {
+	cases := []struct {
+		item       Item
+		hasReserve bool
+		reserveMet bool
+	}{
+		{item: Item{}, hasReserve: false, reserveMet: false},
+		{item: Item{CurrentBid: Cents(1000)}, hasReserve: false, reserveMet: true},
+		{item: Item{ReservePrice: Cents(5000)}, hasReserve: true, reserveMet: false},
+		{item: Item{ReservePrice: Cents(5000), CurrentBid: Cents(4900)}, hasReserve: true, reserveMet: false},
+		{item: Item{ReservePrice: Cents(5000), CurrentBid: Cents(5000)}, hasReserve: true, reserveMet: true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.item.HasReserve(); got != tc.hasReserve {
+			t.Errorf("HasReserve() = %t want %t for %s",
+				got, tc.hasReserve, AsJson(tc.item))
+		}
+		if got := tc.item.ReserveMet(); got != tc.reserveMet {
+			t.Errorf("ReserveMet() = %t want %t for %s",
+				got, tc.reserveMet, AsJson(tc.item))
+		}
+	}
+}
+
+func TestAuctionBuyNowAvailable(t *testing.T) {
+	now := time.Now()
+	auction := Auction{
+		AuctionTimes: AuctionTimes{
+			AuctionStart: now.Add(-time.Hour),
+			AuctionEnd:   now.Add(time.Hour),
+		},
+		BuyNowCutoff: 75,
+	}
+
+	cases := []struct {
+		item Item
+		want bool
+	}{
+		{item: Item{OpeningBid: Cents(1000)}, want: false},
+		{item: Item{OpeningBid: Cents(1000), BuyNowPrice: Cents(10000)}, want: true},
+		{item: Item{OpeningBid: Cents(1000), BuyNowPrice: Cents(10000), CurrentBid: Cents(7400)}, want: true},
+		{item: Item{OpeningBid: Cents(1000), BuyNowPrice: Cents(10000), CurrentBid: Cents(7500)}, want: false},
+		{item: Item{OpeningBid: Cents(1000), BuyNowPrice: Cents(10000), SoldAt: &now}, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := auction.BuyNowAvailable(tc.item); got != tc.want {
+			t.Errorf("BuyNowAvailable() = %t want %t for %s",
+				got, tc.want, AsJson(tc.item))
+		}
+	}
+}
+
+func TestItemBidIncrement(t *testing.T) {
+	increments := []Increment{
+		{From: Cents(0), To: Cents(10000), Amount: Cents(500)},
+		{From: Cents(10000), To: Cents(50000), Amount: Cents(1000)},
+		{From: Cents(50000), Amount: Cents(2500)},
+	}
+
+	cases := []struct {
+		item   Item
+		amount Money
+		want   Money
+	}{
+		{item: Item{MinBidIncr: Cents(200)}, amount: Cents(0), want: Cents(200)},
+		{item: Item{MinBidIncr: Cents(200)}, amount: Cents(100000), want: Cents(200)},
+		{item: Item{MinBidIncr: Cents(200), Increments: increments}, amount: Cents(0), want: Cents(500)},
+		{item: Item{MinBidIncr: Cents(200), Increments: increments}, amount: Cents(9999), want: Cents(500)},
+		{item: Item{MinBidIncr: Cents(200), Increments: increments}, amount: Cents(10000), want: Cents(1000)},
+		{item: Item{MinBidIncr: Cents(200), Increments: increments}, amount: Cents(50000), want: Cents(2500)},
+		{item: Item{MinBidIncr: Cents(200), Increments: increments[1:]}, amount: Cents(5000), want: Cents(200)},
+	}
+
+	for _, tc := range cases {
+		if got := tc.item.BidIncrement(tc.amount); got != tc.want {
+			t.Errorf("BidIncrement(%v) = %v want %v for %s",
+				tc.amount, got, tc.want, AsJson(tc.item))
+		}
+	}
+}
+
+func TestItemValidQuantity(t *testing.T) {
+	cases := []struct {
+		item Item
+		want bool
+	}{
+		{item: Item{}, want: false},
+		{item: Item{Quantity: 1}, want: true},
+		{item: Item{Quantity: 3}, want: true},
+		{item: Item{Quantity: 1, BuyNowPrice: Cents(1000)}, want: true},
+		{item: Item{Quantity: 3, BuyNowPrice: Cents(1000)}, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.item.ValidQuantity(); got != tc.want {
+			t.Errorf("ValidQuantity() = %t want %t for %s",
+				got, tc.want, AsJson(tc.item))
+		}
+	}
+}
+
+func TestItemValidType(t *testing.T) {
+	cases := []struct {
+		item Item
+		want bool
+	}{
+		{item: Item{}, want: false},
+		{item: Item{ItemType: "other", Quantity: 1}, want: false},
+		{item: Item{ItemType: ItemTypeAuction, Quantity: 1}, want: true},
+		{item: Item{ItemType: ItemTypeAuction, Quantity: 1, PledgeLevels: []Money{Cents(100)}}, want: false},
+		{item: Item{ItemType: ItemTypePledge, Quantity: 1}, want: true},
+		{item: Item{ItemType: ItemTypePledge, Quantity: 1, PledgeLevels: []Money{Cents(100)}}, want: true},
+		{item: Item{ItemType: ItemTypePledge, Quantity: 2}, want: false},
+		{item: Item{ItemType: ItemTypePledge, Quantity: 1, ReservePrice: Cents(100)}, want: false},
+		{item: Item{ItemType: ItemTypePledge, Quantity: 1, BuyNowPrice: Cents(100)}, want: false},
+		{item: Item{ItemType: ItemTypeSealed, Quantity: 1}, want: true},
+		{item: Item{ItemType: ItemTypeSealed, Quantity: 1, ReservePrice: Cents(100)}, want: true},
+		{item: Item{ItemType: ItemTypeSealed, Quantity: 2}, want: false},
+		{item: Item{ItemType: ItemTypeSealed, Quantity: 1, BuyNowPrice: Cents(100)}, want: false},
+		{item: Item{ItemType: ItemTypeSealed, Quantity: 1, PledgeLevels: []Money{Cents(100)}}, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.item.ValidType(); got != tc.want {
+			t.Errorf("ValidType() = %t want %t for %s",
+				got, tc.want, AsJson(tc.item))
+		}
+	}
+}
+
+func TestAuctionBidsHidden(t *testing.T) {
+	now := time.Now()
+	open := Auction{
+		AuctionTimes: AuctionTimes{
+			AuctionStart: now.Add(-time.Hour),
+			AuctionEnd:   now.Add(time.Hour),
+		},
+	}
+	closed := Auction{
+		AuctionTimes: AuctionTimes{
+			AuctionStart: now.Add(-2 * time.Hour),
+			AuctionEnd:   now.Add(-time.Hour),
+		},
+	}
+	sealed := Item{ItemType: ItemTypeSealed, Quantity: 1}
+	user := webauth.User{Username: "test"}
+	admin := webauth.User{Username: "admin", IsAdmin: true}
+
+	cases := []struct {
+		auction Auction
+		item    Item
+		user    webauth.User
+		want    bool
+	}{
+		{auction: open, item: Item{ItemType: ItemTypeAuction}, user: user, want: false},
+		{auction: open, item: sealed, user: webauth.User{}, want: true},
+		{auction: open, item: sealed, user: user, want: true},
+		{auction: open, item: sealed, user: admin, want: false},
+		{auction: closed, item: sealed, user: user, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.auction.BidsHidden(tc.item, tc.user); got != tc.want {
+			t.Errorf("BidsHidden() = %t want %t for %s and %q",
+				got, tc.want, AsJson(tc.item), tc.user.Username)
+		}
+	}
+
+	items := open.HideBids([]Item{
+		{ID: 1, ItemType: ItemTypeAuction, OpeningBid: Cents(1000), MinBid: Cents(2100), CurrentBid: Cents(2000), Bidder: "test"},
+		{ID: 2, ItemType: ItemTypeSealed, OpeningBid: Cents(1000), MinBid: Cents(2100), CurrentBid: Cents(2000), Bidder: "test"},
+	}, user)
+	if items[0].Bidder != "test" || items[0].CurrentBid != Cents(2000) {
+		t.Errorf("HideBids() hid auction item %s", AsJson(items[0]))
+	}
+	if items[1].Bidder != "" || !items[1].CurrentBid.IsZero() || items[1].MinBid != Cents(1000) {
+		t.Errorf("HideBids() did not hide sealed item %s", AsJson(items[1]))
+	}
+
+	bids := []Bid{{ID: 2, Bidder: "test"}, {ID: 2, Bidder: "admin"}}
+	want := []Bid{{ID: 2, Bidder: "test"}}
+	if got := OwnBids(bids, "test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("OwnBids() got %s want %s", AsJson(got), AsJson(want))
+	}
+}
@@ -4,11 +4,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bnixon67/webapp/webauth"
 	"github.com/bnixon67/webapp/webhandler"
@@ -17,12 +22,31 @@ import (
 
 // ItemEditPageData contains data passed to the HTML template.
 type ItemEditPageData struct {
-	Title   string
-	Message string
-	User    webauth.User
-	Item    Item
+	Title    string
+	Message  string
+	User     webauth.User
+	Item     Item
+	Auction  Auction   // auction of Item
+	Auctions []Auction // auctions Item can be moved to
+
+	IncrSchedules []string // names of the bid increment schedules
+
+	// ImageStatuses are the status of processing the images of Item, by
+	// image name.
+	ImageStatuses map[string]ImageStatus
+
+	ImageLimits     ImageLimits // limits on each uploaded image
+	MaxImageUploads int         // how many images can be uploaded at once
 }
 
+// MaxImageUploads is how many images can be uploaded at once when editing
+// an item.
+const MaxImageUploads = 10
+
+// maxEditFormBytes is the size of the edit form without the images, which
+// is also how much of the images is kept in memory.
+const maxEditFormBytes = 1 << 20
+
 // ItemEditHandler display an item.
 func (app *BidApp) ItemEditHandler(w http.ResponseWriter, r *http.Request) {
 	// Get logger with request info and function name.
@@ -67,13 +91,14 @@ func (app *BidApp) ItemEditHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		app.itemEditGetHandler(w, r, id, user)
+		app.itemEditGetHandler(w, r, id, user, "")
 	case http.MethodPost:
 		app.itemEditPostHandler(w, r, id, user)
 	}
 }
 
-func (app *BidApp) itemEditGetHandler(w http.ResponseWriter, r *http.Request, id int, user webauth.User) {
+// itemEditGetHandler displays the form to edit item id, with msg.
+func (app *BidApp) itemEditGetHandler(w http.ResponseWriter, r *http.Request, id int, user webauth.User, msg string) {
 	// Get logger with request info and function name.
 	logger := webhandler.RequestLoggerWithFuncName(r)
 
@@ -89,12 +114,38 @@ func (app *BidApp) itemEditGetHandler(w http.ResponseWriter, r *http.Request, id
 		}
 	}
 
+	auction, auctions, err := app.itemAuctions(item)
+	if err != nil {
+		logger.Error("unable to get auctions", "item", item, "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := app.BidDB.GetIncrementSchedules()
+	if err != nil {
+		logger.Error("unable to get increment schedules", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	imageStatuses, err := app.BidDB.GetImageStatuses(item.Images)
+	if err != nil {
+		logger.Error("unable to get image statuses", "item", item, "err", err)
+	}
+
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "edit.html",
 		ItemEditPageData{
-			Title:   app.Cfg.App.Name,
-			Message: "",
-			User:    user,
-			Item:    item,
+			Title:         app.Cfg.App.Name,
+			Message:       msg,
+			User:          user,
+			Item:          item,
+			Auction:       auction,
+			Auctions:      auctions,
+			IncrSchedules: slices.Sorted(maps.Keys(schedules)),
+			ImageStatuses: imageStatuses,
+
+			ImageLimits:     app.ImageLimits,
+			MaxImageUploads: MaxImageUploads,
 		})
 	if err != nil {
 		logger.Error("unable to render template", "err", err)
@@ -112,6 +163,25 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 	var msg string
 	var err error
 
+	// limit the size of the request, which is mostly images
+	if app.ImageLimits.MaxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, MaxImageUploads*app.ImageLimits.MaxBytes+maxEditFormBytes)
+	}
+	err = r.ParseMultipartForm(maxEditFormBytes)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		logger.Warn("request too large", "limit", maxBytesErr.Limit)
+		app.itemEditGetHandler(w, r, id, user,
+			fmt.Sprintf("Could not upload images larger than %s in all, try fewer images at once",
+				formatBytes(maxBytesErr.Limit-maxEditFormBytes)))
+		return
+	}
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		logger.Error("unable to parse form", "err", err)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
 	// get title
 	title := r.PostFormValue("title")
 	if title == "" {
@@ -135,9 +205,9 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 		webutil.RespondWithError(w, http.StatusBadRequest)
 		return
 	}
-	openingBid, err := strconv.ParseFloat(openingBidStr, 64)
+	openingBid, err := ParseMoney(openingBidStr)
 	if err != nil {
-		logger.Error("unable to convert openingBid to float64",
+		logger.Error("unable to parse openingBid",
 			"openingBidStr", openingBidStr,
 			"err", err,
 		)
@@ -152,9 +222,9 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 		webutil.RespondWithError(w, http.StatusBadRequest)
 		return
 	}
-	minBidIncr, err := strconv.ParseFloat(minBidIncrStr, 64)
+	minBidIncr, err := ParseMoney(minBidIncrStr)
 	if err != nil {
-		logger.Error("unable to convert minBidIncr to float64",
+		logger.Error("unable to parse minBidIncr",
 			"minBidIncrStr", minBidIncrStr,
 			"err", err,
 		)
@@ -162,58 +232,223 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 		return
 	}
 
+	// get incrSchedule, which is optional
+	incrSchedule := r.PostFormValue("incrSchedule")
+
+	schedules, err := app.BidDB.GetIncrementSchedules()
+	if err != nil {
+		logger.Error("unable to get increment schedules", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// get itemType, which defaults to an auction item
+	itemType := r.PostFormValue("itemType")
+	if itemType == "" {
+		itemType = ItemTypeAuction
+	}
+
+	// get pledgeLevels, which is optional
+	pledgeLevelsStr := r.PostFormValue("pledgeLevels")
+	pledgeLevels, err := ParsePledgeLevels(pledgeLevelsStr)
+	if err != nil {
+		logger.Error("invalid pledgeLevels",
+			"pledgeLevelsStr", pledgeLevelsStr,
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
+	// get quantity, which defaults to one
+	quantity := 1
+	quantityStr := r.PostFormValue("quantity")
+	if quantityStr != "" {
+		quantity, err = strconv.Atoi(quantityStr)
+		if err != nil || quantity < 1 {
+			logger.Error("invalid quantity",
+				"quantityStr", quantityStr,
+				"err", err,
+			)
+			webutil.RespondWithError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// get reservePrice, which is optional
+	var reservePrice Money
+	reservePriceStr := r.PostFormValue("reservePrice")
+	if reservePriceStr != "" {
+		reservePrice, err = ParseMoney(reservePriceStr)
+		if err != nil {
+			logger.Error("invalid reservePrice",
+				"reservePriceStr", reservePriceStr,
+				"err", err,
+			)
+			webutil.RespondWithError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// get buyNowPrice, which is optional
+	var buyNowPrice Money
+	buyNowPriceStr := r.PostFormValue("buyNowPrice")
+	if buyNowPriceStr != "" {
+		buyNowPrice, err = ParseMoney(buyNowPriceStr)
+		if err != nil {
+			logger.Error("invalid buyNowPrice",
+				"buyNowPriceStr", buyNowPriceStr,
+				"err", err,
+			)
+			webutil.RespondWithError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// get auction, which defaults to the default auction
+	var auction Auction
+	auctionIDStr := r.PostFormValue("auctionId")
+	if auctionIDStr == "" {
+		auction, err = app.BidDB.GetAuction(app.DefaultAuction)
+	} else {
+		var auctionID int
+		auctionID, err = strconv.Atoi(auctionIDStr)
+		if err == nil {
+			auction, err = app.BidDB.GetAuctionByID(auctionID)
+		}
+	}
+	if err != nil {
+		logger.Error("unable to get auction",
+			"auctionIdStr", auctionIDStr,
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
+	// times are entered in the time zone of the auction
+	loc, err := auction.Location()
+	if err != nil {
+		logger.Error("unable to get location",
+			"auction", auction,
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// get opensAt and closesAt, which are optional
+	opensAt, err := parseFormTime(r, "opensAt", loc)
+	if err != nil {
+		logger.Error("unable to parse opensAt",
+			"opensAt", r.PostFormValue("opensAt"),
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+	closesAt, err := parseFormTime(r, "closesAt", loc)
+	if err != nil {
+		logger.Error("unable to parse closesAt",
+			"closesAt", r.PostFormValue("closesAt"),
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
 	// get artist
 	artist := r.PostFormValue("artist")
 
-	// get imageFileName
-	imageFileName := r.PostFormValue("imageFileName")
+	item := Item{
+		ID:           id,
+		AuctionID:    auction.ID,
+		ItemType:     itemType,
+		Title:        title,
+		Description:  description,
+		OpeningBid:   openingBid,
+		MinBidIncr:   minBidIncr,
+		IncrSchedule: incrSchedule,
+		Quantity:     quantity,
+		ReservePrice: reservePrice,
+		BuyNowPrice:  buyNowPrice,
+		PledgeLevels: pledgeLevels,
+		Artist:       artist,
+		OpensAt:      opensAt,
+		ClosesAt:     closesAt,
+	}
+
+	if !item.ValidTimes() {
+		msg = "Closing time must be after opening time"
+	}
 
-	// get imageFile
-	imageFile, fileHeader, err := r.FormFile("imageFile")
-	if err != nil && err != http.ErrMissingFile {
-		logger.Error("no imageFile", "err", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	if _, ok := schedules[incrSchedule]; msg == "" && incrSchedule != "" && !ok {
+		msg = "Unknown increment schedule"
 	}
 
-	// new imageFile to upload
-	if err != http.ErrMissingFile {
-		defer imageFile.Close()
+	if msg == "" && !item.ValidPrices() {
+		msg = "Buy now price must be at least the opening bid and reserve"
+	}
 
-		imageFileName = SafeFileName(fileHeader.Filename, "jpg")
+	if msg == "" && !item.ValidQuantity() {
+		msg = "Buy now is only available for a quantity of one"
+	}
 
-		var err error
-		var name string
+	if msg == "" && !item.ValidType() {
+		switch item.ItemType {
+		case ItemTypeAuction:
+			msg = "Only Fund-a-Need items have pledge levels"
+		case ItemTypeSealed:
+			msg = "Sealed bid items cannot have buy now, quantity, or pledge levels"
+		case ItemTypePledge:
+			msg = "Fund-a-Need items cannot have a reserve, buy now, or quantity"
+		default:
+			msg = "Invalid item type"
+		}
+	}
 
-		name = filepath.Join("images", imageFileName)
-		err = SaveScaledJPEG(imageFile, name, 1920, 0)
-		if err != nil {
-			logger.Error("unable to SaveScaledJPEG",
-				"imageFile", imageFile,
-				"name", name,
-				"err", err)
-			msg = err.Error()
+	// get images in the order shown, without those removed
+	removed := r.PostForm["removeImages"]
+	var images []string
+	for _, name := range r.PostForm["images"] {
+		if name == filepath.Base(name) && !slices.Contains(removed, name) && !slices.Contains(images, name) {
+			images = append(images, name)
 		}
+	}
 
-		name = filepath.Join("images", "thumbnails", imageFileName)
-		err = SaveScaledJPEG(imageFile, name, 480, 0)
+	// save new images, which are added after the others, unless the item
+	// is invalid so the images would not be used
+	var imageFiles []*multipart.FileHeader
+	if r.MultipartForm != nil && msg == "" {
+		imageFiles = r.MultipartForm.File["imageFiles"]
+	}
+	if len(imageFiles) > MaxImageUploads {
+		logger.Warn("too many images", "count", len(imageFiles))
+		msg = fmt.Sprintf("Could not upload %d images, upload at most %d at once", len(imageFiles), MaxImageUploads)
+		imageFiles = nil
+	}
+	for _, fileHeader := range imageFiles {
+		name, err := app.uploadImage(fileHeader)
 		if err != nil {
-			logger.Error("unable to SaveScaledJPEG",
-				"imageFile", imageFile,
+			logger.Error("unable to uploadImage",
+				"fileName", fileHeader.Filename,
 				"name", name,
 				"err", err)
-			msg = err.Error()
+			msg = fmt.Sprintf("Could not upload %s", fileHeader.Filename)
+			if errors.Is(err, ErrNotImage) || errors.Is(err, ErrImageTooLarge) {
+				msg += ": " + err.Error()
+			}
+			continue
+		}
+		if !slices.Contains(images, name) {
+			images = append(images, name)
 		}
 	}
 
-	item := Item{
-		ID:            id,
-		Title:         title,
-		Description:   description,
-		OpeningBid:    openingBid,
-		MinBidIncr:    minBidIncr,
-		Artist:        artist,
-		ImageFileName: imageFileName,
+	// the first image is shown in the gallery
+	item.Images = images
+	if len(images) > 0 {
+		item.ImageFileName = images[0]
 	}
 
 	// only continue if msg is null, otherwise there was a prior error
@@ -226,6 +461,11 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 					"item", item, "err", err)
 			} else {
 				logger.Info("created item", "newId", newId)
+				err = app.BidDB.SetItemImages(int(newId), images)
+				if err != nil {
+					logger.Error("unable to SetItemImages",
+						"id", newId, "images", images, "err", err)
+				}
 				newUrl := fmt.Sprintf("/edit/%d", newId)
 				http.Redirect(w, r, newUrl, http.StatusSeeOther)
 				return
@@ -236,27 +476,53 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 				msg = "Could not update item"
 				logger.Error("unable to UpdateItem",
 					"item", item, "rows", rows, "err", err)
+			} else if err = app.BidDB.SetItemImages(id, images); err != nil {
+				msg = "Could not update images"
+				logger.Error("unable to SetItemImages",
+					"id", id, "images", images, "err", err)
 			} else {
 				msg = "Updated item"
 			}
 		}
 	}
 
-	// get item from database
-	item, err = app.BidDB.GetItem(id)
+	// show an item that was not created as submitted, with the images
+	// that were uploaded, so it can be corrected and submitted again
+	if id != 0 {
+		item, err = app.BidDB.GetItem(id)
+		if err != nil {
+			logger.Error("unable to GetItem", "id", id, "err", err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	auction, auctions, err := app.itemAuctions(item)
 	if err != nil {
-		logger.Error("unable to GetItem", "id", id, "err", err)
-		w.WriteHeader(http.StatusNotFound)
+		logger.Error("unable to get auctions", "item", item, "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
 		return
 	}
 
+	imageStatuses, err := app.BidDB.GetImageStatuses(item.Images)
+	if err != nil {
+		logger.Error("unable to GetImageStatuses", "id", id, "err", err)
+	}
+
 	// display page
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "edit.html",
 		ItemEditPageData{
-			Title:   app.Cfg.App.Name,
-			Message: msg,
-			User:    user,
-			Item:    item,
+			Title:         app.Cfg.App.Name,
+			Message:       msg,
+			User:          user,
+			Item:          item,
+			Auction:       auction,
+			Auctions:      auctions,
+			IncrSchedules: slices.Sorted(maps.Keys(schedules)),
+			ImageStatuses: imageStatuses,
+
+			ImageLimits:     app.ImageLimits,
+			MaxImageUploads: MaxImageUploads,
 		})
 	if err != nil {
 		logger.Error("unable to RenderTemplate", "err", err)
@@ -265,3 +531,54 @@ func (app *BidApp) itemEditPostHandler(w http.ResponseWriter, r *http.Request, i
 
 	logger.Info("success", "user", user, "item", item)
 }
+
+// formTimeLayout is the layout of a datetime-local input value.
+const formTimeLayout = "2006-01-02T15:04"
+
+// itemAuctions returns the auction of item, or the default auction for a
+// new item, and all of the auctions.
+func (app *BidApp) itemAuctions(item Item) (Auction, []Auction, error) {
+	var auction Auction
+	var err error
+
+	if item.AuctionID == 0 {
+		auction, err = app.BidDB.GetAuction(app.DefaultAuction)
+	} else {
+		auction, err = app.BidDB.GetAuctionByID(item.AuctionID)
+	}
+	if err != nil {
+		return auction, nil, err
+	}
+
+	auctions, err := app.BidDB.GetAuctions()
+
+	return auction, auctions, err
+}
+
+// parseFormTime returns the datetime-local form value name as a time in
+// loc, or nil if the value is empty.
+func parseFormTime(r *http.Request, name string, loc *time.Location) (*time.Time, error) {
+	value := r.PostFormValue(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation(formTimeLayout, value, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// uploadImage queues the uploaded image of fileHeader to be processed and
+// returns its name.
+func (app *BidApp) uploadImage(fileHeader *multipart.FileHeader) (string, error) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return app.ImageQueue.Upload(f)
+}
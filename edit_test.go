@@ -0,0 +1,158 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bnixon67/webapp/webauth"
+)
+
+// editRequestForTest returns a request to save an item with fields, which
+// replace those of a valid item, that uploads files, by file name.
+func editRequestForTest(t *testing.T, fields map[string]string, files map[string][]byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	values := map[string]string{"title": "Title", "description": "Description", "openingBid": "10", "minBidIncr": "1", "artist": "Artist", "images": "File"}
+	maps.Copy(values, fields)
+	for name, value := range values {
+		form.WriteField(name, value)
+	}
+	for fileName, data := range files {
+		part, err := form.CreateFormFile("imageFiles", fileName)
+		if err != nil {
+			t.Fatalf("CreateFormFile() failed: %v", err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/edit/1", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+
+	return r
+}
+
+func TestItemEditPostImages(t *testing.T) {
+	png := imageForTest(t, color.RGBA{R: 255, A: 255})
+
+	cases := []struct {
+		name          string
+		limits        ImageLimits
+		fields        map[string]string
+		files         map[string][]byte
+		inBody        string
+		wantImageName bool
+	}{
+		{"image", DefaultImageLimits, nil, map[string][]byte{"red.png": png}, "Updated item", true},
+		{"no images", DefaultImageLimits, map[string]string{"removeImages": "File"}, nil, "Updated item", false},
+		{"not an image", DefaultImageLimits, nil, map[string][]byte{"notes.txt": []byte("notes")}, "Could not upload notes.txt: not an image", true},
+		{"too many pixels", ImageLimits{MaxBytes: 1 << 20, MaxPixels: 16}, nil, map[string][]byte{"red.png": png}, "Could not upload red.png: image is too large", true},
+		{"too many bytes", ImageLimits{MaxBytes: 16}, nil, map[string][]byte{"big.png": make([]byte, 2<<20)}, "Could not upload images larger than 160 bytes in all", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memStoreForTest(t)
+			app := storeAppForTest(t, store)
+			app.ImageLimits = tc.limits
+			app.ImageQueue = NewImageQueue(store, app.Images, &LocalImageStore{Dir: t.TempDir()}, DefaultImageWidths, tc.limits, ImageQueueSize)
+
+			w := httptest.NewRecorder()
+			app.itemEditPostHandler(w, editRequestForTest(t, tc.fields, tc.files), 1, webauth.User{IsAdmin: true})
+
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %d want %d", w.Code, http.StatusOK)
+			}
+			if !strings.Contains(w.Body.String(), tc.inBody) {
+				t.Errorf("got body %q want %q in body", w.Body, tc.inBody)
+			}
+
+			item, err := store.GetItem(1)
+			if err != nil || (item.ImageFileName != "") != tc.wantImageName {
+				t.Errorf("GetItem(1) got ImageFileName %q err '%v' want image %t", item.ImageFileName, err, tc.wantImageName)
+			}
+		})
+	}
+}
+
+func TestItemEditPostNew(t *testing.T) {
+	png := imageForTest(t, color.RGBA{G: 255, A: 255})
+
+	cases := []struct {
+		name        string
+		fields      map[string]string
+		files       map[string][]byte
+		wantStatus  int
+		inBody      string
+		wantUploads int // images waiting to be processed
+	}{
+		{"created", nil, map[string][]byte{"green.png": png}, http.StatusSeeOther, "", 1},
+		{"closes before opens", map[string]string{"opensAt": "2024-05-02T18:00", "closesAt": "2024-05-01T18:00"}, map[string][]byte{"green.png": png}, http.StatusOK, "Closing time must be after opening time", 0},
+		{"unknown schedule", map[string]string{"incrSchedule": "nosuch"}, map[string][]byte{"green.png": png}, http.StatusOK, "Unknown increment schedule", 0},
+		{"buy now below opening bid", map[string]string{"buyNowPrice": "5"}, map[string][]byte{"green.png": png}, http.StatusOK, "Buy now price must be at least the opening bid and reserve", 0},
+		{"pledge with quantity", map[string]string{"itemType": ItemTypePledge, "quantity": "2"}, nil, http.StatusOK, "Fund-a-Need items cannot have a reserve, buy now, or quantity", 0},
+		{"not an image", nil, map[string][]byte{"green.png": png, "notes.txt": []byte("notes")}, http.StatusOK, "Could not upload notes.txt: not an image", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memStoreForTest(t)
+			app := storeAppForTest(t, store)
+
+			items, err := store.GetItems(1)
+			if err != nil {
+				t.Fatalf("GetItems(1) failed: %v", err)
+			}
+
+			fields := map[string]string{"title": "New Title"}
+			maps.Copy(fields, tc.fields)
+
+			w := httptest.NewRecorder()
+			app.itemEditPostHandler(w, editRequestForTest(t, fields, tc.files), 0, webauth.User{IsAdmin: true})
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, tc.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tc.inBody) {
+				t.Errorf("got body %q want %q in body", w.Body, tc.inBody)
+			}
+
+			pending, err := store.GetPendingImages()
+			if err != nil || len(pending) != tc.wantUploads {
+				t.Errorf("GetPendingImages() got %q err '%v' want %d", pending, err, tc.wantUploads)
+			}
+
+			after, err := store.GetItems(1)
+			if err != nil {
+				t.Fatalf("GetItems(1) failed: %v", err)
+			}
+			if tc.wantStatus == http.StatusSeeOther {
+				if len(after) != len(items)+1 {
+					t.Errorf("GetItems(1) got %d items want %d", len(after), len(items)+1)
+				}
+				return
+			}
+			if len(after) != len(items) {
+				t.Errorf("GetItems(1) got %d items want %d", len(after), len(items))
+			}
+
+			// the form is shown again as submitted, with the uploaded images
+			for _, want := range append([]string{`value="New Title"`}, pending...) {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("got body without %q", want)
+				}
+			}
+		})
+	}
+}
@@ -19,6 +19,15 @@ type GalleryPageData struct {
 	Message string
 	User    webauth.User
 	Items   []Item
+	Now     time.Time
+	Auction Auction
+
+	// Variants are the variants of the images of Items, by image name.
+	Variants map[string][]ImageVariant
+
+	// ImageStatuses are the status of processing the images of Items, by
+	// image name. A placeholder is shown until an image is ready.
+	ImageStatuses map[string]ImageStatus
 }
 
 // GalleryHandler displays a gallery of items.
@@ -45,27 +54,55 @@ func (app *BidApp) GalleryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, err := app.BidDB.GetItems()
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := app.BidDB.GetItems(auction.ID)
 	if err != nil {
 		logger.Error("failed to get items", "err", err)
 		webutil.RespondWithError(w, http.StatusInternalServerError)
 		return
 	}
+	items = inCurrency(auction.HideBids(items, user), auction.Currency)
+
+	var imageNames []string
+	for _, item := range items {
+		imageNames = append(imageNames, item.ImageFileName)
+	}
+	variants, err := app.BidDB.GetImageVariants(imageNames)
+	if err != nil {
+		// images are shown without variants
+		logger.Error("failed to get image variants", "err", err)
+	}
+	imageStatuses, err := app.BidDB.GetImageStatuses(imageNames)
+	if err != nil {
+		// images are shown as if processed
+		logger.Error("failed to get image statuses", "err", err)
+	}
 
 	layout := "Mon Jan 2, 2006 3:04 PM MST"
 	now := time.Now()
 
+	loc, err := auction.Location()
+	if err != nil {
+		logger.Error("failed to get location", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
 	var message string
 	switch {
-	case now.Before(app.AuctionStart):
+	case now.Before(auction.AuctionStart):
 		message = fmt.Sprintf("Auction opens %s",
-			app.AuctionStart.Format(layout))
-	case now.Before(app.AuctionEnd):
+			auction.AuctionStart.In(loc).Format(layout))
+	case now.Before(auction.AuctionEnd):
 		message = fmt.Sprintf("Auction closes %s",
-			app.AuctionEnd.Format(layout))
+			auction.AuctionEnd.In(loc).Format(layout))
 	default:
 		message = fmt.Sprintf("Auction closed %s",
-			app.AuctionEnd.Format(layout))
+			auction.AuctionEnd.In(loc).Format(layout))
 	}
 
 	logger.Info("GalleryHandler", "username", user.Username)
@@ -76,6 +113,11 @@ func (app *BidApp) GalleryHandler(w http.ResponseWriter, r *http.Request) {
 			Message: message,
 			User:    user,
 			Items:   items,
+			Now:     now,
+			Auction: auction,
+
+			Variants:      variants,
+			ImageStatuses: imageStatuses,
 		})
 	if err != nil {
 		logger.Error("unable to render template", "err", err)
@@ -0,0 +1,55 @@
+// Reorder the images of an item by dragging them or with the arrow
+// buttons. The order of the hidden inputs is the order that is saved.
+document.addEventListener("DOMContentLoaded", () => {
+  const list = document.getElementById("images");
+  if (!list) return;
+
+  // mark the first image as primary, as the server will
+  function updatePrimary() {
+    list.querySelectorAll("li").forEach((li, i) => {
+      li.querySelector("small")?.remove();
+      if (i === 0) {
+        const small = document.createElement("small");
+        small.textContent = "(primary)";
+        li.querySelector("span").append(" ", small);
+      }
+    });
+  }
+
+  list.addEventListener("click", event => {
+    const button = event.target.closest("button[data-move]");
+    if (!button) return;
+
+    const li = button.closest("li");
+    if (button.dataset.move === "-1" && li.previousElementSibling) {
+      list.insertBefore(li, li.previousElementSibling);
+    } else if (button.dataset.move === "1" && li.nextElementSibling) {
+      list.insertBefore(li.nextElementSibling, li);
+    }
+    button.focus();
+    updatePrimary();
+  });
+
+  let dragged = null;
+
+  list.addEventListener("dragstart", event => {
+    dragged = event.target.closest("li");
+    event.dataTransfer.effectAllowed = "move";
+  });
+
+  list.addEventListener("dragover", event => {
+    const li = event.target.closest("li");
+    if (!dragged || !li || li === dragged) return;
+    event.preventDefault();
+
+    // drop before or after the image, depending on which half it is over
+    const box = li.getBoundingClientRect();
+    const after = event.clientY > box.top + box.height / 2;
+    list.insertBefore(dragged, after ? li.nextElementSibling : li);
+  });
+
+  list.addEventListener("dragend", () => {
+    dragged = null;
+    updatePrimary();
+  });
+});
@@ -16,7 +16,7 @@ document.addEventListener("DOMContentLoaded", () => {
       const matchesType =
         filterType === "all" ||
         (filterType === "display" && type === "display") ||
-        (filterType === "biddable" && type === "biddable");
+        (filterType === "biddable" && type !== "display");
 
       card.style.display = matchesText && matchesType ? "" : "none";
     });
@@ -24,4 +24,56 @@ document.addEventListener("DOMContentLoaded", () => {
 
   filterInput.addEventListener("input", applyFilters);
   displayFilter.addEventListener("change", applyFilters);
+
+  // Show closing times within a day as a countdown, e.g., "Closes in 12m"
+  function updateCloses() {
+    const now = Date.now();
+
+    document.querySelectorAll("#gallery [data-closes]").forEach(el => {
+      const ms = Date.parse(el.getAttribute("data-closes")) - now;
+      const mins = Math.ceil(ms / 60000);
+
+      if (ms <= 0) {
+        el.textContent = "Closed";
+      } else if (mins < 60) {
+        el.textContent = `Closes in ${mins}m`;
+      } else if (mins < 24 * 60) {
+        el.textContent = `Closes in ${Math.floor(mins / 60)}h ${mins % 60}m`;
+      }
+    });
+  }
+
+  updateCloses();
+  setInterval(updateCloses, 15000);
+
+  // Update prices and closing times as bids are placed
+  if (!window.EventSource) return;
+
+  const source = new EventSource("/stream/items");
+
+  // format an amount as the server does, e.g., $1,000.00, with the
+  // symbol of the currency of the auction
+  const symbol = document.getElementById("main").getAttribute("data-currency-symbol");
+  const money = amount => symbol + amount.toLocaleString("en-US", {
+    minimumFractionDigits: 2, maximumFractionDigits: 2,
+  });
+
+  source.addEventListener("bid", event => {
+    const bid = JSON.parse(event.data);
+    const card = document.querySelector(`#gallery .card-link[data-id="${bid.id}"]`);
+    if (!card) return;
+
+    const price = card.querySelector(".price");
+    if (price && card.getAttribute("data-display") === "pledge") {
+      price.textContent = `${money(bid.pledgeTotal)} pledged`;
+    } else if (price && bid.currentBid) {
+      price.textContent = money(bid.currentBid);
+    }
+
+    const closes = card.querySelector("[data-closes]");
+    if (closes) {
+      closes.setAttribute("data-closes", bid.closesAt);
+      updateCloses();
+    }
+  });
 });
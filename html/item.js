@@ -0,0 +1,81 @@
+// Update the price on the item page as bids are placed, or the total as
+// pledges are placed.
+document.addEventListener("DOMContentLoaded", () => {
+  const id = document.getElementById("main").getAttribute("data-id");
+  const price = document.getElementById("currentPrice");
+  const pledgeTotal = document.getElementById("pledgeTotal");
+  if (!id || !(price || pledgeTotal) || !window.EventSource) return;
+
+  const status = document.getElementById("bidUpdate");
+  const minBid = document.getElementById("minBid");
+  const bidAmount = document.getElementById("bidAmount");
+  const closesAt = document.getElementById("closesAt");
+  const reserve = document.getElementById("reserve");
+  const buyNow = document.getElementById("buyNow");
+  const winningBids = document.getElementById("winningBids");
+  const pledgeCount = document.getElementById("pledgeCount");
+
+  // format an amount as the server does, e.g., $1,000.00, with the
+  // symbol of the currency of the auction
+  const symbol = document.getElementById("main").getAttribute("data-currency-symbol");
+  const money = amount => symbol + amount.toLocaleString("en-US", {
+    minimumFractionDigits: 2, maximumFractionDigits: 2,
+  });
+
+  const source = new EventSource(`/stream/item/${id}`);
+
+  source.addEventListener("bid", event => {
+    const bid = JSON.parse(event.data);
+
+    // bidding has closed, so show the page as sold
+    if (bid.sold) {
+      source.close();
+      window.location.reload();
+      return;
+    }
+
+    if (pledgeTotal) {
+      const total = money(bid.pledgeTotal);
+      if (pledgeTotal.textContent !== total) {
+        pledgeTotal.textContent = total;
+        pledgeCount.textContent = bid.pledges;
+        status.textContent = `Total pledged is now ${total}`;
+        status.hidden = false;
+      }
+      return;
+    }
+
+    if (!bid.currentBid) return;
+
+    if (buyNow && !bid.buyNow) buyNow.hidden = true;
+
+    const text = money(bid.currentBid);
+    if (price.textContent !== text) {
+      price.textContent = text;
+      // winning bids of an item with a quantity are only shown on refresh
+      status.textContent = winningBids
+        ? `Lowest winning bid is now ${text}. Refresh to see all winning bids.`
+        : `New bid of ${text} by ${bid.bidder}`;
+      status.hidden = false;
+    }
+
+    if (minBid) minBid.textContent = money(bid.minBid);
+
+    if (reserve) {
+      reserve.textContent = bid.reserveMet ? "Reserve met" : "Reserve not met";
+    }
+
+    if (bidAmount) {
+      bidAmount.min = bid.minBid;
+      if (Number(bidAmount.value) < bid.minBid) bidAmount.value = bid.minBid;
+    }
+
+    if (closesAt && closesAt.getAttribute("datetime") !== bid.closesAt) {
+      closesAt.setAttribute("datetime", bid.closesAt);
+      closesAt.textContent = new Date(bid.closesAt).toLocaleString([], {
+        weekday: "short", month: "short", day: "numeric",
+        hour: "numeric", minute: "2-digit", timeZoneName: "short",
+      });
+    }
+  });
+});
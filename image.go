@@ -4,59 +4,361 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"image"
 	"io"
 	"log/slog"
-	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/disintegration/imaging"
 )
 
-func ScaleDown(r io.ReadSeeker, maxWidth, maxHeight int) (image.Image, error) {
+// ScaleDown returns src scaled down to fit maxWidth and maxHeight, or src
+// if it is already smaller.
+func ScaleDown(src image.Image, maxWidth, maxHeight int) (image.Image, error) {
 	if maxWidth == 0 && maxHeight == 0 {
 		return nil, errors.New("invalid parameters: maxWidth and maxHeight are both 0")
 	}
 
-	src, err := imaging.Decode(r, imaging.AutoOrientation(true))
-	if err != nil {
-		return nil, err
-	}
-
 	srcWidth := src.Bounds().Dx()
 	srcHeight := src.Bounds().Dy()
 
 	// don't resize if source is already smaller
 	if srcWidth <= maxWidth || srcHeight <= maxHeight {
-		return src, err
+		return src, nil
 	}
 
 	// Resize
 	dst := imaging.Resize(src, maxWidth, maxHeight, imaging.Lanczos)
 
-	return dst, err
+	return dst, nil
 }
 
-func SaveScaledJPEG(imgFile io.ReadSeeker, name string, maxWidth, maxHeight int) error {
-	imgFile.Seek(0, io.SeekStart)
-
-	img, err := ScaleDown(imgFile, maxWidth, maxHeight)
+// SaveScaledJPEG saves src to images as a JPEG named name, scaled down to
+// fit maxWidth and maxHeight.
+func SaveScaledJPEG(src image.Image, images ImageStore, name string, maxWidth, maxHeight int) error {
+	img, err := ScaleDown(src, maxWidth, maxHeight)
 	if err != nil {
 		return fmt.Errorf("could not ScaleDown: %v", err)
 	}
 
-	flag := os.O_CREATE | os.O_WRONLY | os.O_EXCL
-	perm := os.FileMode(0o400)
-	output, err := os.OpenFile(name, flag, perm)
+	var output bytes.Buffer
+	err = imaging.Encode(&output, img, imaging.JPEG, imaging.JPEGQuality(95))
+	if err != nil {
+		return err
+	}
+
+	err = images.Put(name, &output)
 	if err != nil {
 		return err
 	}
-	defer output.Close()
 
-	imaging.Encode(output, img, imaging.JPEG, imaging.JPEGQuality(95))
+	slog.Info("SaveScaledJPEG", "name", name)
+
+	return nil
+}
+
+// ImageName returns the name an image is stored under, which is derived
+// from a hash of the image in r, so that different images never have the
+// same name and the same image is only stored once.
+func ImageName(r io.ReadSeeker) (string, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16]) + ".jpg", nil
+}
+
+// saveImage saves the image in r, a thumbnail of it, and its variants at
+// widths to images. It returns its name from ImageName and its variants,
+// or an error that wraps ErrNotImage if r cannot be decoded. An image
+// that is already saved is kept.
+func saveImage(r io.ReadSeeker, images ImageStore, widths []int) (string, []ImageVariant, error) {
+	name, err := ImageName(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return name, nil, err
+	}
+
+	// decode once for the image, its thumbnail, and its variants
+	src, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return name, nil, fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+
+	sizes := []struct {
+		name  string
+		width int
+	}{
+		{name, 1920},
+		{ThumbnailName(name), 480},
+	}
+	for _, size := range sizes {
+		err = SaveScaledJPEG(src, images, size.name, size.width, 0)
+		if err != nil {
+			return name, nil, err
+		}
+	}
+
+	variants, err := saveImageVariants(src, name, images, widths)
 
-	slog.Info("SaveScaledJPEG", "name", name, "flag", flag, "perm", perm)
+	return name, variants, err
+}
+
+// DefaultImageWidths are the widths of the variants of each image, which
+// browsers choose from to download the smallest suitable image.
+var DefaultImageWidths = []int{480, 960, 1920}
+
+// ImageVariantQuality is the quality, from 0 to 100, of image variants.
+const ImageVariantQuality = 80
+
+// Types of image variants.
+const (
+	ImageTypeJPEG = "image/jpeg"
+	ImageTypeWebP = "image/webp"
+)
+
+// ImageVariant is a copy of an image at a smaller width or in another
+// format.
+type ImageVariant struct {
+	Name  string // name in the ImageStore
+	Width int    // width in pixels
+	Type  string // MIME type, such as image/webp
+}
+
+// VariantName returns the name of the variant of the image name with
+// width and type typ.
+func VariantName(name string, width int, typ string) string {
+	ext := ".jpg"
+	if typ == ImageTypeWebP {
+		ext = ".webp"
+	}
+
+	return path.Join("variants", fmt.Sprintf("%s.%dw%s", name, width, ext))
+}
+
+// variantImageName returns the name of the image of the variant named
+// name, or false if name is not a variant.
+func variantImageName(name string) (string, bool) {
+	dir, base := path.Split(name)
+	if dir != "variants/" {
+		return "", false
+	}
+
+	base = strings.TrimSuffix(base, path.Ext(base))
+	i := strings.LastIndex(base, ".")
+	if i <= 0 || !strings.HasSuffix(base, "w") {
+		return "", false
+	}
+	if _, err := strconv.Atoi(base[i+1 : len(base)-1]); err != nil {
+		return "", false
+	}
+
+	return base[:i], true
+}
+
+// saveImageVariants saves the variants of the image name in src to images,
+// at each of widths that is narrower than src and at the width of src, as
+// JPEG and, if supported, WebP. It returns the variants in order of width.
+func saveImageVariants(src image.Image, name string, images ImageStore, widths []int) ([]ImageVariant, error) {
+	srcWidth := src.Bounds().Dx()
 
-	return err
+	var sizes []int
+	for _, width := range widths {
+		if width > 0 {
+			sizes = append(sizes, min(width, srcWidth))
+		}
+	}
+	slices.Sort(sizes)
+	sizes = slices.Compact(sizes)
+
+	types := []string{ImageTypeJPEG}
+	if webpSupported {
+		types = append(types, ImageTypeWebP)
+	}
+
+	var variants []ImageVariant
+	for _, width := range sizes {
+		img := src
+		if width < srcWidth {
+			img = imaging.Resize(src, width, 0, imaging.Lanczos)
+		}
+
+		for _, typ := range types {
+			var buf bytes.Buffer
+			var err error
+			if typ == ImageTypeWebP {
+				err = encodeWebP(&buf, img, ImageVariantQuality)
+			} else {
+				err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(ImageVariantQuality))
+			}
+			if err != nil {
+				return variants, err
+			}
+
+			variant := ImageVariant{Name: VariantName(name, width, typ), Width: width, Type: typ}
+			err = images.Put(variant.Name, &buf)
+			if err != nil {
+				return variants, err
+			}
+			variants = append(variants, variant)
+		}
+	}
+
+	return variants, nil
+}
+
+// createImageVariants saves the variants at widths of the image name in
+// images, and records them in store.
+func createImageVariants(store BidStore, images ImageStore, name string, widths []int) ([]ImageVariant, error) {
+	f, err := images.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	src, err := imaging.Decode(f, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := saveImageVariants(src, name, images, widths)
+	if err != nil {
+		return variants, err
+	}
+
+	return variants, store.SetImageVariants(name, variants)
+}
+
+// SrcSet returns the srcset attribute of the variants with type typ, such
+// as "/images/variants/a.jpg.480w.webp 480w", with URLs from images.
+func SrcSet(images ImageStore, variants []ImageVariant, typ string) string {
+	var candidates []string
+	for _, variant := range variants {
+		if variant.Type == typ {
+			candidates = append(candidates, fmt.Sprintf("%s %dw", images.URL(variant.Name), variant.Width))
+		}
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// SweepImages removes the images, thumbnails, and variants in images that
+// are not used by an item in store, with their variants and status in
+// store. Images stored within minAge, or still pending, are kept, since
+// they may be uploaded for an item that is not yet saved. It returns the
+// names of the images removed, or that would be removed if dryRun is true.
+func SweepImages(store BidStore, images ImageStore, minAge time.Duration, dryRun bool) ([]string, error) {
+	used, err := store.GetImageFileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := store.GetPendingImages()
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := images.List()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-minAge)
+
+	var removed []string
+	var removedNames []string    // names of the images of removed files
+	var removedVariants []string // names of the images of removed variants
+	for _, image := range stored {
+		name, isVariant := variantImageName(image.Name)
+		if !isVariant {
+			dir, base := path.Split(image.Name)
+			if (dir != "" && dir != "thumbnails/") || path.Ext(base) != ".jpg" {
+				continue
+			}
+			name = base
+		}
+		if slices.Contains(used, name) || slices.Contains(pending, name) || image.Modified.After(cutoff) {
+			continue
+		}
+
+		if !dryRun {
+			err = images.Delete(image.Name)
+			if err != nil {
+				return removed, err
+			}
+			if isVariant && !slices.Contains(removedVariants, name) {
+				removedVariants = append(removedVariants, name)
+			}
+			if !slices.Contains(removedNames, name) {
+				removedNames = append(removedNames, name)
+			}
+		}
+		removed = append(removed, image.Name)
+	}
+
+	for _, name := range removedVariants {
+		err = store.SetImageVariants(name, nil)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	// an image uploaded again is processed again
+	for _, name := range removedNames {
+		err = store.SetImageStatus(name, ImageStatus{})
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// How often the server removes images that are not used, and how old an
+// image must be to be removed.
+const (
+	ImageSweepInterval = time.Hour
+	ImageSweepMinAge   = 24 * time.Hour
+)
+
+// RunImageSweeper runs SweepImages every interval until ctx is done.
+func RunImageSweeper(ctx context.Context, store BidStore, images ImageStore, interval, minAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := SweepImages(store, images, minAge, false)
+			if err != nil {
+				slog.Error("failed to sweep images", "err", err)
+			}
+			if len(removed) > 0 {
+				slog.Info("swept images", "removed", removed)
+			}
+		}
+	}
 }
@@ -0,0 +1,233 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// imageForTest returns a small PNG image filled with c.
+func imageForTest(t *testing.T, c color.Color) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := imaging.Encode(&buf, imaging.New(8, 8, c), imaging.PNG)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// readCounter counts the bytes read from a ReadSeeker.
+type readCounter struct {
+	io.ReadSeeker
+	n int
+}
+
+func (r *readCounter) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestSaveImage(t *testing.T) {
+	images := &LocalImageStore{Dir: t.TempDir()}
+
+	red := imageForTest(t, color.RGBA{R: 255, A: 255})
+	blue := imageForTest(t, color.RGBA{B: 255, A: 255})
+
+	// the images are 8 wide, so there are variants 4 and 8 wide
+	widths := []int{4, 16}
+
+	redName, variants, err := saveImage(bytes.NewReader(red), images, widths)
+	if err != nil {
+		t.Fatalf("saveImage(red) failed: %v", err)
+	}
+
+	types := []string{ImageTypeJPEG}
+	if webpSupported {
+		types = append(types, ImageTypeWebP)
+	}
+	var wantVariants []ImageVariant
+	for _, width := range []int{4, 8} {
+		for _, typ := range types {
+			wantVariants = append(wantVariants, ImageVariant{VariantName(redName, width, typ), width, typ})
+		}
+	}
+	if !slices.Equal(variants, wantVariants) {
+		t.Errorf("saveImage(red) got variants %v want %v", variants, wantVariants)
+	}
+
+	// the same image is saved once, with the same name, and is read
+	// once for its name and once to decode it for every size and variant
+	r := &readCounter{ReadSeeker: bytes.NewReader(red)}
+	name, _, err := saveImage(r, images, widths)
+	if err != nil || name != redName {
+		t.Errorf("saveImage(red) again got %q err '%v' want %q", name, err, redName)
+	}
+	if r.n > 2*len(red) {
+		t.Errorf("saveImage(red) read %d bytes want at most %d", r.n, 2*len(red))
+	}
+
+	blueName, _, err := saveImage(bytes.NewReader(blue), images, nil)
+	if err != nil || blueName == redName {
+		t.Errorf("saveImage(blue) got %q err '%v' want a name other than %q", blueName, err, redName)
+	}
+
+	stored, err := images.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+
+	var names []string
+	for _, image := range stored {
+		names = append(names, image.Name)
+	}
+	slices.Sort(names)
+
+	want := []string{redName, blueName, ThumbnailName(redName), ThumbnailName(blueName)}
+	for _, variant := range wantVariants {
+		want = append(want, variant.Name)
+	}
+	slices.Sort(want)
+	if !slices.Equal(names, want) {
+		t.Errorf("images got %q want %q", names, want)
+	}
+
+	_, _, err = saveImage(bytes.NewReader([]byte("not an image")), images, widths)
+	if !errors.Is(err, ErrNotImage) {
+		t.Errorf("saveImage(not an image) got err '%v' want '%v'", err, ErrNotImage)
+	}
+}
+
+func TestVariantImageName(t *testing.T) {
+	cases := []struct {
+		name     string
+		want     string
+		wantIsOK bool
+	}{
+		{VariantName("a.jpg", 480, ImageTypeWebP), "a.jpg", true},
+		{VariantName("File", 1920, ImageTypeJPEG), "File", true},
+		{"variants/a.jpg", "", false},
+		{"variants/a.jpg.wide.jpg", "", false},
+		{"thumbnails/a.jpg.480w.jpg", "", false},
+	}
+
+	for _, tc := range cases {
+		got, ok := variantImageName(tc.name)
+		if got != tc.want || ok != tc.wantIsOK {
+			t.Errorf("variantImageName(%q) got %q, %v want %q, %v", tc.name, got, ok, tc.want, tc.wantIsOK)
+		}
+	}
+}
+
+func TestSrcSet(t *testing.T) {
+	images := &LocalImageStore{Dir: "images"}
+	variants := []ImageVariant{
+		{VariantName("a.jpg", 480, ImageTypeJPEG), 480, ImageTypeJPEG},
+		{VariantName("a.jpg", 480, ImageTypeWebP), 480, ImageTypeWebP},
+		{VariantName("a.jpg", 960, ImageTypeJPEG), 960, ImageTypeJPEG},
+	}
+
+	cases := []struct {
+		typ  string
+		want string
+	}{
+		{ImageTypeJPEG, "/images/variants/a.jpg.480w.jpg 480w, /images/variants/a.jpg.960w.jpg 960w"},
+		{ImageTypeWebP, "/images/variants/a.jpg.480w.webp 480w"},
+		{"image/avif", ""},
+	}
+
+	for _, tc := range cases {
+		got := SrcSet(images, variants, tc.typ)
+		if got != tc.want {
+			t.Errorf("SrcSet(%q) got %q want %q", tc.typ, got, tc.want)
+		}
+	}
+}
+
+func TestSweepImages(t *testing.T) {
+	store := memStoreForTest(t)
+	images := &LocalImageStore{Dir: t.TempDir()}
+
+	old := time.Now().Add(-2 * ImageSweepMinAge)
+	for _, name := range []string{"File", "used.jpg", "unused.jpg", "new.jpg", "pending.jpg", "notes.txt", "thumbnails/used.jpg", "thumbnails/unused.jpg", "thumbnails/pending.jpg", "variants/used.jpg.480w.webp", "variants/unused.jpg.480w.webp"} {
+		err := images.Put(name, strings.NewReader(name))
+		if err != nil {
+			t.Fatalf("Put(%q) failed: %v", name, err)
+		}
+		if name != "new.jpg" {
+			os.Chtimes(filepath.Join(images.Dir, name), old, old)
+		}
+	}
+
+	err := store.SetItemImages(2, []string{"used.jpg"})
+	if err != nil {
+		t.Fatalf("SetItemImages() failed: %v", err)
+	}
+	err = store.SetImageVariants("unused.jpg", []ImageVariant{{"variants/unused.jpg.480w.webp", 480, ImageTypeWebP}})
+	if err != nil {
+		t.Fatalf("SetImageVariants() failed: %v", err)
+	}
+	for _, name := range []string{"used.jpg", "unused.jpg"} {
+		err = store.SetImageStatus(name, ImageStatus{State: ImageReady})
+		if err != nil {
+			t.Fatalf("SetImageStatus(%q) failed: %v", name, err)
+		}
+	}
+	// an image being processed is kept, though stored long ago
+	err = store.SetImageStatus("pending.jpg", ImageStatus{State: ImagePending})
+	if err != nil {
+		t.Fatalf("SetImageStatus(pending.jpg) failed: %v", err)
+	}
+
+	want := []string{"unused.jpg", "thumbnails/unused.jpg", "variants/unused.jpg.480w.webp"}
+
+	removed, err := SweepImages(store, images, ImageSweepMinAge, true)
+	if err != nil || !slices.Equal(removed, want) {
+		t.Errorf("SweepImages() dry run got %q err '%v' want %q", removed, err, want)
+	}
+	_, err = os.Stat(filepath.Join(images.Dir, "unused.jpg"))
+	if err != nil {
+		t.Errorf("SweepImages() dry run removed unused.jpg: %v", err)
+	}
+
+	removed, err = SweepImages(store, images, ImageSweepMinAge, false)
+	if err != nil || !slices.Equal(removed, want) {
+		t.Errorf("SweepImages() got %q err '%v' want %q", removed, err, want)
+	}
+
+	for _, name := range []string{"File", "used.jpg", "new.jpg", "pending.jpg", "notes.txt", "thumbnails/used.jpg", "thumbnails/pending.jpg", "variants/used.jpg.480w.webp", "unused.jpg", "variants/unused.jpg.480w.webp"} {
+		_, err = os.Stat(filepath.Join(images.Dir, name))
+		if exists := err == nil; exists != !strings.Contains(name, "unused.jpg") {
+			t.Errorf("after SweepImages() %q exists %v", name, exists)
+		}
+	}
+
+	variants, err := store.GetImageVariants([]string{"unused.jpg"})
+	if err != nil || len(variants) != 0 {
+		t.Errorf("GetImageVariants() after SweepImages() got %v err '%v' want none", variants, err)
+	}
+
+	// the status of a removed image is removed, so it is processed if it
+	// is uploaded again
+	statuses, err := store.GetImageStatuses([]string{"used.jpg", "unused.jpg"})
+	wantStatuses := map[string]ImageStatus{"used.jpg": {State: ImageReady}}
+	if err != nil || !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("GetImageStatuses() after SweepImages() got %v err '%v' want %v", statuses, err, wantStatuses)
+	}
+}
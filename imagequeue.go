@@ -0,0 +1,391 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImageStatus is the status of processing an uploaded image.
+type ImageStatus struct {
+	State   string // ImagePending, ImageReady, or ImageFailed
+	Message string // why processing failed
+}
+
+// States of processing an uploaded image.
+const (
+	ImagePending = "pending"
+	ImageReady   = "ready"
+	ImageFailed  = "failed"
+)
+
+// Ready returns true if the image has been processed, which includes
+// images without a status, such as those saved before uploads were
+// processed in the background.
+func (s ImageStatus) Ready() bool {
+	return s.State == "" || s.State == ImageReady
+}
+
+// Errors returned for an upload that cannot be processed, with a reason
+// that can be shown to the user.
+var (
+	ErrNotImage      = errors.New("not an image")
+	ErrImageTooLarge = errors.New("image is too large")
+	ErrUploadMissing = errors.New("upload is missing")
+)
+
+// ImageLimits are the limits on uploaded images. A limit of zero is no
+// limit.
+type ImageLimits struct {
+	MaxBytes  int64 // size of the file
+	MaxPixels int64 // width times height, which limits memory to decode it
+}
+
+// DefaultImageLimits allow photos from cameras and phones, but not images
+// that are mostly empty, such as decompression bombs.
+var DefaultImageLimits = ImageLimits{MaxBytes: 20 << 20, MaxPixels: 50_000_000}
+
+// String returns the limits for people, such as "20.0 MB and 50.0
+// megapixels", or "" if there are none.
+func (limits ImageLimits) String() string {
+	var s []string
+	if limits.MaxBytes > 0 {
+		s = append(s, formatBytes(limits.MaxBytes))
+	}
+	if limits.MaxPixels > 0 {
+		s = append(s, fmt.Sprintf("%.1f megapixels", float64(limits.MaxPixels)/1e6))
+	}
+
+	return strings.Join(s, " and ")
+}
+
+// ImageUploadTypes are the MIME types of images that can be uploaded.
+var ImageUploadTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// Check returns an error that wraps ErrNotImage if r is not an image of
+// ImageUploadTypes, by its content rather than its file name, or that
+// wraps ErrImageTooLarge if it exceeds the limits. The image is only
+// decoded if it is within the limits.
+func (limits ImageLimits) Check(r io.ReadSeeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if limits.MaxBytes > 0 && size > limits.MaxBytes {
+		return fmt.Errorf("%w: %s is more than %s", ErrImageTooLarge, formatBytes(size), formatBytes(limits.MaxBytes))
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	typ := http.DetectContentType(head[:n])
+	if !slices.Contains(ImageUploadTypes, typ) || (typ == ImageTypeWebP && !webpSupported) {
+		return fmt.Errorf("%w: %s is not a JPEG, PNG, GIF, or WebP image", ErrNotImage, typ)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return fmt.Errorf("%w: %dx%d pixels", ErrNotImage, cfg.Width, cfg.Height)
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); limits.MaxPixels > 0 && pixels > limits.MaxPixels {
+		return fmt.Errorf("%w: %dx%d pixels is more than %.1f megapixels", ErrImageTooLarge, cfg.Width, cfg.Height, float64(limits.MaxPixels)/1e6)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+
+	return err
+}
+
+// formatBytes returns n as a number of bytes, KB, or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// How many images can wait to be processed, how many are processed at
+// once, and how often images left pending, such as by a restart, are
+// queued again.
+const (
+	ImageQueueSize    = 100
+	ImageQueueWorkers = 2
+	ImageQueueRescan  = time.Minute
+)
+
+// ImageQueue processes uploaded images in the background with a bounded
+// number of workers, so that requests do not wait while images are scaled.
+//
+// Uploads keep their metadata, such as the GPS location in EXIF, so they
+// are kept in a store of their own, which is never served, until they are
+// processed; the images saved from them are encoded anew without any
+// metadata.
+type ImageQueue struct {
+	store   BidStore
+	images  ImageStore
+	uploads ImageStore  // images as uploaded, by name
+	widths  []int       // widths of the variants of images
+	limits  ImageLimits // limits on uploaded images
+	jobs    chan string
+
+	mu     sync.Mutex
+	queued map[string]bool // names of images queued or being processed
+}
+
+// NewImageQueue returns an ImageQueue that keeps uploads in uploads until
+// it saves them, and their variants at widths, to images and their status
+// to store, with room for size images to wait. Uploads that exceed limits
+// are rejected.
+func NewImageQueue(store BidStore, images, uploads ImageStore, widths []int, limits ImageLimits, size int) *ImageQueue {
+	return &ImageQueue{
+		store:   store,
+		images:  images,
+		uploads: uploads,
+		widths:  widths,
+		limits:  limits,
+		jobs:    make(chan string, size),
+		queued:  make(map[string]bool),
+	}
+}
+
+// Upload saves the image in r as uploaded and queues it to be processed.
+// It returns the name of the image, which an item can use at once, or an
+// error from ImageLimits.Check if the image is not accepted.
+func (q *ImageQueue) Upload(r io.ReadSeeker) (string, error) {
+	// reject a file that is not an image before it is queued
+	err := q.limits.Check(r)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := ImageName(r)
+	if err != nil {
+		return "", err
+	}
+
+	statuses, err := q.store.GetImageStatuses([]string{name})
+	if err != nil {
+		return name, err
+	}
+	if statuses[name].State == ImageReady {
+		return name, nil
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return name, err
+	}
+
+	err = q.uploads.Put(name, r)
+	if err != nil {
+		return name, err
+	}
+
+	err = q.store.SetImageStatus(name, ImageStatus{State: ImagePending})
+	if err != nil {
+		return name, err
+	}
+
+	if !q.Enqueue(name) {
+		slog.Warn("image queue is full", "name", name)
+	}
+
+	return name, nil
+}
+
+// Enqueue queues the image name to be processed, unless it is already
+// queued. It returns false if the queue is full, which leaves the image
+// pending until the queue is rescanned.
+func (q *ImageQueue) Enqueue(name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queued[name] {
+		return true
+	}
+
+	select {
+	case q.jobs <- name:
+		q.queued[name] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes queued images with workers until ctx is done. The pending
+// images are queued at the start and every rescan.
+func (q *ImageQueue) Run(ctx context.Context, workers int, rescan time.Duration) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(rescan)
+	defer ticker.Stop()
+
+	for {
+		q.queuePending()
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// work processes queued images until ctx is done.
+func (q *ImageQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-q.jobs:
+			err := q.Process(name)
+			if err != nil {
+				slog.Error("failed to process image", "name", name, "err", err)
+			}
+
+			q.mu.Lock()
+			delete(q.queued, name)
+			q.mu.Unlock()
+		}
+	}
+}
+
+// ProcessQueued processes the queued images now, rather than with
+// workers, such as for a command that exits before workers would run. It
+// returns the errors of the images that failed.
+func (q *ImageQueue) ProcessQueued() error {
+	var errs []error
+	for {
+		select {
+		case name := <-q.jobs:
+			err := q.Process(name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("image %s: %w", name, err))
+			}
+
+			q.mu.Lock()
+			delete(q.queued, name)
+			q.mu.Unlock()
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// queuePending queues the pending images, until the queue is full.
+func (q *ImageQueue) queuePending() {
+	names, err := q.store.GetPendingImages()
+	if err != nil {
+		slog.Error("failed to get pending images", "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if !q.Enqueue(name) {
+			return
+		}
+	}
+}
+
+// Process saves the sizes and variants of the uploaded image name, and
+// sets its status to ready, or to failed with the reason if the upload
+// cannot be decoded or is missing. The upload is then removed, so an
+// image that failed must be uploaded again. Other errors, such as from
+// saving to the image store, keep the upload and leave the image pending,
+// so it is processed again when the queue is rescanned.
+func (q *ImageQueue) Process(name string) error {
+	statuses, err := q.store.GetImageStatuses([]string{name})
+	if err != nil {
+		return err
+	}
+	if statuses[name].State == ImageReady {
+		// processed by another server sharing the images
+		return nil
+	}
+
+	status := ImageStatus{State: ImageReady}
+
+	processErr := q.process(name)
+	switch {
+	case errors.Is(processErr, ErrNotImage) || errors.Is(processErr, ErrUploadMissing):
+		message := []rune(processErr.Error())
+		status = ImageStatus{State: ImageFailed, Message: string(message[:min(len(message), 255)])}
+	case processErr != nil:
+		return processErr
+	}
+
+	err = q.store.SetImageStatus(name, status)
+	if err != nil {
+		return errors.Join(processErr, err)
+	}
+
+	// the upload is no longer needed, and keeps its metadata
+	err = q.uploads.Delete(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("failed to delete upload", "name", name, "err", err)
+	}
+
+	return processErr
+}
+
+// process saves the sizes and variants of the uploaded image name.
+func (q *ImageQueue) process(name string) error {
+	f, err := q.uploads.Get(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrUploadMissing, err)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	_, variants, err := saveImage(bytes.NewReader(data), q.images, q.widths)
+	if err != nil {
+		return err
+	}
+
+	return q.store.SetImageVariants(name, variants)
+}
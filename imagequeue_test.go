@@ -0,0 +1,334 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestImageQueueUpload(t *testing.T) {
+	store := NewMemStore()
+	images := &LocalImageStore{Dir: t.TempDir()}
+	uploads := &LocalImageStore{Dir: t.TempDir()}
+	queue := NewImageQueue(store, images, uploads, []int{4}, DefaultImageLimits, 1)
+
+	name, err := queue.Upload(bytes.NewReader(imageForTest(t, color.RGBA{R: 255, A: 255})))
+	if err != nil {
+		t.Fatalf("Upload() failed: %v", err)
+	}
+
+	statuses, err := store.GetImageStatuses([]string{name})
+	if err != nil || statuses[name].State != ImagePending {
+		t.Errorf("after Upload() got status %v err '%v' want %q", statuses[name], err, ImagePending)
+	}
+	_, err = readImageForTest(uploads, name)
+	if err != nil {
+		t.Errorf("after Upload() uploads.Get(%q) failed: %v", name, err)
+	}
+
+	err = queue.Process(name)
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	statuses, err = store.GetImageStatuses([]string{name})
+	if err != nil || statuses[name].State != ImageReady {
+		t.Errorf("after Process() got status %v err '%v' want %q", statuses[name], err, ImageReady)
+	}
+	for _, image := range []string{name, ThumbnailName(name), VariantName(name, 4, ImageTypeJPEG)} {
+		_, err = readImageForTest(images, image)
+		if err != nil {
+			t.Errorf("after Process() Get(%q) failed: %v", image, err)
+		}
+	}
+	_, err = readImageForTest(uploads, name)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("after Process() uploads.Get(%q) got err '%v' want '%v'", name, err, fs.ErrNotExist)
+	}
+	variants, err := store.GetImageVariants([]string{name})
+	if err != nil || len(variants[name]) == 0 {
+		t.Errorf("after Process() got variants %v err '%v' want some", variants, err)
+	}
+
+	// an image that is ready is not uploaded again
+	_, err = queue.Upload(bytes.NewReader(imageForTest(t, color.RGBA{R: 255, A: 255})))
+	if err != nil {
+		t.Fatalf("Upload() again failed: %v", err)
+	}
+	_, err = readImageForTest(uploads, name)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("after Upload() again uploads.Get(%q) got err '%v' want '%v'", name, err, fs.ErrNotExist)
+	}
+
+	_, err = queue.Upload(strings.NewReader("not an image"))
+	if !errors.Is(err, ErrNotImage) {
+		t.Errorf("Upload(text) got err '%v' want '%v'", err, ErrNotImage)
+	}
+}
+
+func TestImageQueueProcessFailed(t *testing.T) {
+	// a file where the images directory should be, so images cannot be
+	// saved
+	notDir := filepath.Join(t.TempDir(), "images")
+	err := os.WriteFile(notDir, nil, 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		images     *LocalImageStore
+		upload     string // replaces the upload, or deletes it if "-"
+		wantErr    error
+		wantState  string
+		wantUpload bool // upload is kept to process again
+	}{
+		{"missing upload", &LocalImageStore{Dir: t.TempDir()}, "-", ErrUploadMissing, ImageFailed, false},
+		{"not an image", &LocalImageStore{Dir: t.TempDir()}, "not an image", ErrNotImage, ImageFailed, false},
+		{"cannot save", &LocalImageStore{Dir: notDir}, "", nil, ImagePending, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewMemStore()
+			uploads := &LocalImageStore{Dir: t.TempDir()}
+			queue := NewImageQueue(store, tc.images, uploads, []int{4}, DefaultImageLimits, 1)
+
+			name, err := queue.Upload(bytes.NewReader(imageForTest(t, color.RGBA{G: 255, A: 255})))
+			if err != nil {
+				t.Fatalf("Upload() failed: %v", err)
+			}
+
+			if tc.upload != "" {
+				err = uploads.Delete(name)
+				if err != nil {
+					t.Fatalf("Delete() failed: %v", err)
+				}
+			}
+			if tc.upload != "" && tc.upload != "-" {
+				err = uploads.Put(name, strings.NewReader(tc.upload))
+				if err != nil {
+					t.Fatalf("Put() failed: %v", err)
+				}
+			}
+
+			err = queue.Process(name)
+			if err == nil || (tc.wantErr != nil && !errors.Is(err, tc.wantErr)) {
+				t.Errorf("Process() got err '%v' want '%v'", err, tc.wantErr)
+			}
+
+			statuses, err := store.GetImageStatuses([]string{name})
+			if err != nil || statuses[name].State != tc.wantState || (tc.wantState == ImageFailed) != (statuses[name].Message != "") {
+				t.Errorf("after Process() got status %v err '%v' want %q", statuses[name], err, tc.wantState)
+			}
+			if statuses[name].Ready() {
+				t.Errorf("Ready() got true for %v", statuses[name])
+			}
+
+			// the upload, with its metadata, is only kept to be
+			// processed again
+			_, err = readImageForTest(uploads, name)
+			if tc.wantUpload != (err == nil) {
+				t.Errorf("after Process() uploads.Get(%q) got err '%v' want upload %t", name, err, tc.wantUpload)
+			}
+
+			pending, err := store.GetPendingImages()
+			if err != nil || slices.Contains(pending, name) != (tc.wantState == ImagePending) {
+				t.Errorf("GetPendingImages() got %q err '%v'", pending, err)
+			}
+
+			if !tc.wantUpload {
+				return
+			}
+
+			// processed again once images can be saved
+			queue.images = &LocalImageStore{Dir: t.TempDir()}
+			err = queue.Process(name)
+			if err != nil {
+				t.Fatalf("Process() again failed: %v", err)
+			}
+			statuses, err = store.GetImageStatuses([]string{name})
+			if err != nil || statuses[name].State != ImageReady {
+				t.Errorf("after Process() again got status %v err '%v' want %q", statuses[name], err, ImageReady)
+			}
+			_, err = readImageForTest(uploads, name)
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("after Process() again uploads.Get(%q) got err '%v' want '%v'", name, err, fs.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestImageQueueEnqueue(t *testing.T) {
+	queue := NewImageQueue(NewMemStore(), &LocalImageStore{Dir: t.TempDir()}, &LocalImageStore{Dir: t.TempDir()}, nil, DefaultImageLimits, 1)
+
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"a.jpg", true},
+		{"a.jpg", true}, // already queued
+		{"b.jpg", false},
+	}
+
+	for _, tc := range cases {
+		got := queue.Enqueue(tc.name)
+		if got != tc.want {
+			t.Errorf("Enqueue(%q) got %t want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestImageQueueRun(t *testing.T) {
+	store := NewMemStore()
+	images := &LocalImageStore{Dir: t.TempDir()}
+	uploads := &LocalImageStore{Dir: t.TempDir()}
+
+	// the image is pending, as if the server stopped before processing it
+	name, err := NewImageQueue(store, images, uploads, []int{4}, DefaultImageLimits, 1).Upload(bytes.NewReader(imageForTest(t, color.RGBA{B: 255, A: 255})))
+	if err != nil {
+		t.Fatalf("Upload() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewImageQueue(store, images, uploads, []int{4}, DefaultImageLimits, 1).Run(ctx, 1, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statuses, err := store.GetImageStatuses([]string{name})
+		if err != nil {
+			t.Fatalf("GetImageStatuses() failed: %v", err)
+		}
+		if statuses[name].State == ImageReady {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got status %v want %q", statuses[name], ImageReady)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestImageLimitsCheck(t *testing.T) {
+	png := imageForTest(t, color.RGBA{R: 255, A: 255}) // 8x8
+
+	cases := []struct {
+		name    string
+		limits  ImageLimits
+		data    []byte
+		wantErr error
+	}{
+		{"image", DefaultImageLimits, png, nil},
+		{"no limits", ImageLimits{}, png, nil},
+		{"text", DefaultImageLimits, []byte("not an image"), ErrNotImage},
+		{"svg", DefaultImageLimits, []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrNotImage},
+		{"truncated", DefaultImageLimits, png[:20], ErrNotImage},
+		{"too many bytes", ImageLimits{MaxBytes: 16}, png, ErrImageTooLarge},
+		{"too many pixels", ImageLimits{MaxPixels: 63}, png, ErrImageTooLarge},
+	}
+
+	for _, tc := range cases {
+		err := tc.limits.Check(bytes.NewReader(tc.data))
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: Check() got err '%v' want '%v'", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+// jpegWithExifForTest returns a JPEG image, 8 wide and 4 high, with EXIF
+// metadata that rotates it to be 4 wide and 8 high, and the GPS location
+// secret.
+func jpegWithExifForTest(t *testing.T, secret string) []byte {
+	t.Helper()
+
+	var img bytes.Buffer
+	err := imaging.Encode(&img, imaging.New(8, 4, color.White), imaging.JPEG)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	// big-endian TIFF with an IFD of one entry, Orientation 6, followed
+	// by a GPS location that is not parsed
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01" + "\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + "\x00\x00\x00\x00" +
+		secret)
+
+	var out bytes.Buffer
+	out.Write(img.Bytes()[:2]) // SOI
+	out.Write([]byte{0xff, 0xe1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)})
+	out.Write(exif)
+	out.Write(img.Bytes()[2:])
+
+	return out.Bytes()
+}
+
+func TestImageQueueStripsMetadata(t *testing.T) {
+	store := NewMemStore()
+	images := &LocalImageStore{Dir: t.TempDir()}
+	uploads := &LocalImageStore{Dir: t.TempDir()}
+	queue := NewImageQueue(store, images, uploads, []int{4}, DefaultImageLimits, 1)
+
+	const secret = "GPS 40.7128N 74.0060W"
+
+	name, err := queue.Upload(bytes.NewReader(jpegWithExifForTest(t, secret)))
+	if err != nil {
+		t.Fatalf("Upload() failed: %v", err)
+	}
+	upload, err := readImageForTest(uploads, name)
+	if err != nil || !strings.Contains(upload, secret) {
+		t.Fatalf("uploads.Get(%q) got err '%v' want the metadata", name, err)
+	}
+
+	err = queue.Process(name)
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	saved := []string{name, ThumbnailName(name)}
+	variants, err := store.GetImageVariants([]string{name})
+	if err != nil {
+		t.Fatalf("GetImageVariants() failed: %v", err)
+	}
+	for _, variant := range variants[name] {
+		saved = append(saved, variant.Name)
+	}
+
+	for _, image := range saved {
+		data, err := readImageForTest(images, image)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", image, err)
+		}
+		if strings.Contains(data, "Exif") || strings.Contains(data, secret) {
+			t.Errorf("%q has metadata", image)
+		}
+
+		// the orientation is applied before the metadata is removed
+		if strings.HasSuffix(image, ".webp") {
+			continue
+		}
+		img, err := imaging.Decode(strings.NewReader(data))
+		if err != nil || img.Bounds().Dx() != 4 || img.Bounds().Dy() != 8 {
+			t.Errorf("%q got bounds %v err '%v' want 4x8", image, img.Bounds(), err)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ImageStore stores the images of items by name. The thumbnail of an
+// image is stored under the name from ThumbnailName.
+type ImageStore interface {
+	// Put stores the image in r as name. Since names are derived from
+	// the content of images, an image that already exists is kept.
+	Put(name string, r io.Reader) error
+
+	// Get returns the image named name, or an error that wraps
+	// fs.ErrNotExist if there is no such image.
+	Get(name string) (io.ReadCloser, error)
+
+	// Delete removes the image named name.
+	Delete(name string) error
+
+	// URL returns the URL that browsers load the image named name from.
+	URL(name string) string
+
+	// List returns the images, thumbnails, and variants in the store.
+	List() ([]StoredImage, error)
+}
+
+// StoredImage is an image in an ImageStore.
+type StoredImage struct {
+	Name     string    // name of the image, such as thumbnails/a.jpg
+	Modified time.Time // when the image was last stored
+}
+
+// ErrImageName is returned for an image name that is not a relative path
+// within a store.
+var ErrImageName = errors.New("invalid image name")
+
+// ThumbnailName returns the name of the thumbnail of the image name.
+func ThumbnailName(name string) string {
+	return path.Join("thumbnails", name)
+}
+
+// imageURLPrefix is where the server serves images from an ImageStore.
+const imageURLPrefix = "/images/"
+
+// LocalImageStore is an ImageStore in a directory, with thumbnails in its
+// thumbnails directory, that is served by this server.
+type LocalImageStore struct {
+	Dir string // directory of images
+}
+
+// fileName returns the file of the image name.
+func (s *LocalImageStore) fileName(name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("%w: %q", ErrImageName, name)
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(name)), nil
+}
+
+// Put stores the image in r as name. The file is written in full before
+// it is given its name, so a partial image is never served. An image that
+// is already stored is kept, but its modification time is refreshed, so
+// SweepImages does not remove it before an item uses it again.
+func (s *LocalImageStore) Put(name string, r io.Reader) error {
+	fileName, err := s.fileName(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fileName), 0o755)
+	if err != nil {
+		return err
+	}
+
+	output, err := os.CreateTemp(filepath.Dir(fileName), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output.Name())
+
+	_, err = io.Copy(output, r)
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	err = output.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(output.Name(), 0o400)
+	if err != nil {
+		return err
+	}
+
+	// link fails if name exists, unlike rename
+	err = os.Link(output.Name(), fileName)
+	if errors.Is(err, os.ErrExist) {
+		now := time.Now()
+		return os.Chtimes(fileName, now, now)
+	}
+
+	return err
+}
+
+// Get returns the image named name.
+func (s *LocalImageStore) Get(name string) (io.ReadCloser, error) {
+	fileName, err := s.fileName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(fileName)
+}
+
+// Delete removes the image named name.
+func (s *LocalImageStore) Delete(name string) error {
+	fileName, err := s.fileName(name)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(fileName)
+}
+
+// URL returns the URL of the image named name on this server.
+func (s *LocalImageStore) URL(name string) string {
+	return imageURLPrefix + name
+}
+
+// List returns the images in Dir and its thumbnails and variants
+// directories.
+func (s *LocalImageStore) List() ([]StoredImage, error) {
+	var images []StoredImage
+	for _, dir := range []string{".", "thumbnails", "variants"} {
+		entries, err := os.ReadDir(filepath.Join(s.Dir, dir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return images, err
+		}
+
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return images, err
+			}
+
+			images = append(images, StoredImage{
+				Name:     path.Join(dir, entry.Name()),
+				Modified: info.ModTime(),
+			})
+		}
+	}
+
+	return images, nil
+}
+
+// ImageServer returns a handler that serves the images of store, with the
+// image name as the request path.
+func ImageServer(store ImageStore) http.Handler {
+	if local, ok := store.(*LocalImageStore); ok {
+		return http.FileServer(http.Dir(local.Dir))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		image, err := store.Get(r.URL.Path)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrImageName) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		defer image.Close()
+
+		if contentType := mime.TypeByExtension(path.Ext(r.URL.Path)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		io.Copy(w, image)
+	})
+}
+
+// ImageStoreConfig is the Images object of the config file, which selects
+// where images are stored. Without it, images are stored in the images
+// directory.
+type ImageStoreConfig struct {
+	Type string // "local" (the default) or "s3"
+
+	Dir string // directory of a local store, images if empty
+
+	// Endpoint, Region, Bucket, and Prefix locate the images of an s3
+	// store, which are requested in path style so that S3-compatible
+	// servers, such as MinIO, work too.
+	Endpoint string
+	Region   string
+	Bucket   string
+	Prefix   string
+
+	// AccessKey and SecretKey are the credentials of an s3 store, which
+	// default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	AccessKey string
+	SecretKey string
+
+	// BaseURL is the public URL of the images of an s3 store, such as a
+	// CDN. If empty, this server serves the images.
+	BaseURL string
+
+	// Widths are the widths of the variants of each image, which are
+	// DefaultImageWidths if empty.
+	Widths []int
+
+	// MaxUploadBytes and MaxImagePixels limit uploaded images, and are
+	// those of DefaultImageLimits if zero.
+	MaxUploadBytes int64
+	MaxImagePixels int64
+
+	// Uploads is the store of images as uploaded, which keep metadata
+	// such as their GPS location until they are processed, so it must
+	// not be public. Servers that share images must share it too. If nil,
+	// uploads are stored in the uploads directory.
+	Uploads *ImageStoreConfig
+}
+
+// ImageWidths returns the widths of the variants of each image.
+func (cfg ImageStoreConfig) ImageWidths() []int {
+	if len(cfg.Widths) == 0 {
+		return DefaultImageWidths
+	}
+
+	return cfg.Widths
+}
+
+// ImageLimits returns the limits on uploaded images.
+func (cfg ImageStoreConfig) ImageLimits() ImageLimits {
+	limits := ImageLimits{MaxBytes: cfg.MaxUploadBytes, MaxPixels: cfg.MaxImagePixels}
+	if limits.MaxBytes == 0 {
+		limits.MaxBytes = DefaultImageLimits.MaxBytes
+	}
+	if limits.MaxPixels == 0 {
+		limits.MaxPixels = DefaultImageLimits.MaxPixels
+	}
+
+	return limits
+}
+
+// ErrImageStoreConfig is returned for an Images config that is not valid.
+var ErrImageStoreConfig = errors.New("invalid image store config")
+
+// LoadImageStoreConfig reads the Images object of the config file.
+func LoadImageStoreConfig(fileName string) (ImageStoreConfig, error) {
+	var cfg struct{ Images ImageStoreConfig }
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return cfg.Images, err
+	}
+
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return cfg.Images, fmt.Errorf("%w: %v", ErrImageStoreConfig, err)
+	}
+
+	return cfg.Images, nil
+}
+
+// NewUploadStore returns the ImageStore of the uploads of cfg, which is
+// never served, so it cannot have a BaseURL or hold the images of cfg.
+func NewUploadStore(cfg ImageStoreConfig) (ImageStore, error) {
+	uploads := ImageStoreConfig{Dir: "uploads"}
+	if cfg.Uploads != nil {
+		uploads = *cfg.Uploads
+	}
+
+	if uploads.BaseURL != "" {
+		return nil, fmt.Errorf("%w: Uploads cannot have a BaseURL", ErrImageStoreConfig)
+	}
+
+	sameBucket := uploads.Type == "s3" && cfg.Type == "s3" && uploads.Endpoint == cfg.Endpoint && uploads.Bucket == cfg.Bucket
+	if sameBucket && (strings.HasPrefix(uploads.Prefix, cfg.Prefix) || strings.HasPrefix(cfg.Prefix, uploads.Prefix)) {
+		return nil, fmt.Errorf("%w: Uploads must have a Prefix apart from the images", ErrImageStoreConfig)
+	}
+
+	store, err := NewImageStore(uploads)
+	if err != nil {
+		return nil, err
+	}
+
+	// a local store of images is served with all of its directories
+	local, ok := store.(*LocalImageStore)
+	if ok && (cfg.Type == "" || cfg.Type == "local") {
+		imagesDir := cfg.Dir
+		if imagesDir == "" {
+			imagesDir = "images"
+		}
+		rel, err := filepath.Rel(imagesDir, local.Dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%w: Uploads must have a Dir apart from the images", ErrImageStoreConfig)
+		}
+	}
+
+	return store, nil
+}
+
+// NewImageStore returns the ImageStore of cfg.
+func NewImageStore(cfg ImageStoreConfig) (ImageStore, error) {
+	switch cfg.Type {
+	case "", "local":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "images"
+		}
+		return &LocalImageStore{Dir: dir}, nil
+
+	case "s3":
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("%w: s3 requires Endpoint and Bucket", ErrImageStoreConfig)
+		}
+
+		store := &S3ImageStore{
+			Endpoint:  cfg.Endpoint,
+			Region:    cfg.Region,
+			Bucket:    cfg.Bucket,
+			Prefix:    cfg.Prefix,
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			BaseURL:   cfg.BaseURL,
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if store.AccessKey == "" {
+			store.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if store.SecretKey == "" {
+			store.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if store.AccessKey == "" || store.SecretKey == "" {
+			return nil, fmt.Errorf("%w: s3 requires AccessKey and SecretKey", ErrImageStoreConfig)
+		}
+
+		return store, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown Type %q", ErrImageStoreConfig, cfg.Type)
+}
@@ -0,0 +1,467 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/bnixon67/webapp/webhandler"
+	"github.com/bnixon67/webapp/webutil"
+)
+
+// ImportColumns are the columns of a file of items to import, which are
+// matched to the header of a CSV file or the keys of the objects in a JSON
+// array without regard to case. The image file name is optional.
+var ImportColumns = []string{"title", "description", "artist", "openingBid", "minBidIncr", "imageFileName"}
+
+// importMaxLengths are the most characters the database allows for each
+// text column of an item.
+var importMaxLengths = map[string]int{
+	"title":         40,
+	"description":   255,
+	"artist":        30,
+	"imageFileName": 255,
+}
+
+// ImportRow is an item read from a row of an import file.
+type ImportRow struct {
+	Row    int // number of the row, starting at one for the first item
+	Item   Item
+	Image  string   // name of the matching file in the images zip, if any
+	Errors []string // why the item cannot be imported
+}
+
+// Valid returns true if the row can be imported.
+func (row ImportRow) Valid() bool {
+	return len(row.Errors) == 0
+}
+
+var (
+	ErrImportFormat = errors.New("unknown import format")
+	ErrImportRows   = errors.New("import has rows with errors")
+)
+
+// ImportFormat returns the format of the import file fileName from its
+// extension, which is csv or json.
+func ImportFormat(fileName string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+}
+
+// ReadImportRows reads the items of auctionID in r, which has format csv
+// or json, and validates each with the same rules as CreateItem.
+func ReadImportRows(r io.Reader, format string, auctionID int) ([]ImportRow, error) {
+	records, err := readImportRecords(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ImportRow, 0, len(records))
+	for n, record := range records {
+		rows = append(rows, importRow(n+1, record, auctionID))
+	}
+
+	return rows, nil
+}
+
+// readImportRecords returns the values of each row in r by the lower case
+// name of its column.
+func readImportRecords(r io.Reader, format string) ([]map[string]string, error) {
+	var records []map[string]string
+
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		lines, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return nil, nil
+		}
+
+		header := lines[0]
+		for _, line := range lines[1:] {
+			record := make(map[string]string)
+			for i, value := range line {
+				if i < len(header) {
+					record[strings.ToLower(strings.TrimSpace(header[i]))] = strings.TrimSpace(value)
+				}
+			}
+			records = append(records, record)
+		}
+
+	case "json":
+		var objects []map[string]any
+
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		err := decoder.Decode(&objects)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			record := make(map[string]string)
+			for key, value := range object {
+				if value != nil {
+					record[strings.ToLower(key)] = strings.TrimSpace(fmt.Sprint(value))
+				}
+			}
+			records = append(records, record)
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrImportFormat, format)
+	}
+
+	return records, nil
+}
+
+// importRow returns the item of auctionID in record, which is row n.
+func importRow(n int, record map[string]string, auctionID int) ImportRow {
+	value := func(column string) string {
+		return record[strings.ToLower(column)]
+	}
+
+	row := ImportRow{
+		Row: n,
+		Item: Item{
+			AuctionID:     auctionID,
+			ItemType:      ItemTypeAuction,
+			Title:         value("title"),
+			Description:   value("description"),
+			Artist:        value("artist"),
+			ImageFileName: value("imageFileName"),
+			Quantity:      1,
+		},
+	}
+
+	for _, column := range ImportColumns {
+		maxLength, ok := importMaxLengths[column]
+		switch {
+		case ok && column != "imageFileName" && value(column) == "":
+			row.Errors = append(row.Errors, column+" is required")
+		case ok && utf8.RuneCountInString(value(column)) > maxLength:
+			row.Errors = append(row.Errors,
+				fmt.Sprintf("%s is longer than %d characters", column, maxLength))
+		}
+	}
+
+	amount := func(column string) Money {
+		if value(column) == "" {
+			row.Errors = append(row.Errors, column+" is required")
+			return Money{}
+		}
+		m, err := ParseMoney(value(column))
+		if err == nil && m.Cents < 0 {
+			err = ErrInvalidMoney
+		}
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s %q is not a valid amount", column, value(column)))
+		}
+		return m
+	}
+	row.Item.OpeningBid = amount("openingBid")
+	row.Item.MinBidIncr = amount("minBidIncr")
+
+	if row.Valid() && !validNewItem(row.Item) {
+		row.Errors = append(row.Errors, "item is not valid")
+	}
+
+	return row
+}
+
+// MatchImportImages matches the image file name of each row to a file in
+// the images zip, which may be nil, without regard to case or directory. A
+// row with an image file name that does not match is an error.
+func MatchImportImages(rows []ImportRow, images *zip.Reader) {
+	files := make(map[string]string)
+	if images != nil {
+		for _, f := range images.File {
+			if !f.FileInfo().IsDir() {
+				files[strings.ToLower(path.Base(f.Name))] = f.Name
+			}
+		}
+	}
+
+	for i := range rows {
+		imageFileName := rows[i].Item.ImageFileName
+		if imageFileName == "" {
+			continue
+		}
+
+		if images == nil {
+			rows[i].Errors = append(rows[i].Errors,
+				fmt.Sprintf("image %q needs a zip of images", imageFileName))
+			continue
+		}
+
+		name, ok := files[strings.ToLower(path.Base(imageFileName))]
+		if !ok {
+			rows[i].Errors = append(rows[i].Errors,
+				fmt.Sprintf("image %q is not in the zip", imageFileName))
+			continue
+		}
+
+		rows[i].Image = name
+	}
+}
+
+// ImportItems creates the items of rows, which must all be valid, and
+// uploads the image of each row from images to queue, which names it by
+// its content and processes it. The images are uploaded before any item is
+// created, so an image that is not accepted creates no items. It returns
+// the ids of the created items.
+func ImportItems(store BidStore, rows []ImportRow, images *zip.Reader, queue *ImageQueue) ([]int64, error) {
+	for _, row := range rows {
+		if !row.Valid() {
+			return nil, fmt.Errorf("%w: row %d", ErrImportRows, row.Row)
+		}
+	}
+
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		if row.Image != "" {
+			var err error
+			names[i], err = uploadImportImage(queue, images, row.Image)
+			if err != nil {
+				return nil, fmt.Errorf("row %d image %q: %w", row.Row, row.Image, err)
+			}
+		}
+	}
+
+	var ids []int64
+	for i, row := range rows {
+		if names[i] != "" {
+			row.Item.ImageFileName = names[i]
+		}
+
+		id, err := store.CreateItem(row.Item)
+		if err != nil {
+			return ids, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// uploadImportImage uploads the file named zipName in images to queue and
+// returns its name. No more of the file is read than the limits of queue
+// allow, whatever size the zip claims it is.
+func uploadImportImage(queue *ImageQueue, images *zip.Reader, zipName string) (string, error) {
+	f, err := images.Open(zipName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	maxBytes := queue.limits.MaxBytes
+	if maxBytes > 0 {
+		r = io.LimitReader(f, maxBytes+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("%w: more than %s", ErrImageTooLarge, formatBytes(maxBytes))
+	}
+
+	return queue.Upload(bytes.NewReader(data))
+}
+
+// ImportPageData contains data passed to the HTML template.
+type ImportPageData struct {
+	Title    string
+	Message  string
+	User     webauth.User
+	Auction  Auction   // auction the items are imported into
+	Auctions []Auction // auctions items can be imported into
+	Columns  []string  // columns of an import file
+	Rows     []ImportRow
+	Imported int // number of items created
+}
+
+// MaxImportImages is how many images of the largest size allowed can be in
+// the images zip of an import, which limits the size of the request.
+const MaxImportImages = 50
+
+// maxImportFormBytes is how much of the import form is kept in memory, with
+// the rest in temporary files, and the size allowed besides the images.
+const maxImportFormBytes = 1 << 20
+
+// ImportHandler handles the page to import items from a CSV or JSON file,
+// which previews the items and their errors before they are created.
+func (app *BidApp) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	// Check if the HTTP method is valid.
+	if !webutil.CheckAllowedMethods(w, r, http.MethodGet, http.MethodPost) {
+		logger.Error("invalid method")
+		return
+	}
+
+	user, err := app.DB.UserFromRequest(w, r)
+	if err != nil {
+		logger.Error("failed to get user", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// only allowed by admin users
+	if !user.IsAdmin {
+		logger.Warn("attempt by non-admin user", "user", user)
+		webutil.RespondWithError(w, http.StatusUnauthorized)
+		return
+	}
+
+	data := ImportPageData{
+		Title:   app.Cfg.App.Name,
+		User:    user,
+		Columns: ImportColumns,
+	}
+
+	// limit the size of the request, which is mostly images, before the
+	// form is parsed
+	var parseErr error
+	if r.Method == http.MethodPost {
+		if app.ImageLimits.MaxBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, MaxImportImages*app.ImageLimits.MaxBytes+maxImportFormBytes)
+		}
+		parseErr = r.ParseMultipartForm(maxImportFormBytes)
+	}
+
+	data.Auctions, err = app.BidDB.GetAuctions()
+	if err != nil {
+		logger.Error("unable to get auctions", "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// get auction, which defaults to the default auction
+	auctionIDStr := r.FormValue("auctionId")
+	if auctionIDStr == "" {
+		data.Auction, err = app.BidDB.GetAuction(app.DefaultAuction)
+	} else {
+		var auctionID int
+		auctionID, err = strconv.Atoi(auctionIDStr)
+		if err == nil {
+			data.Auction, err = app.BidDB.GetAuctionByID(auctionID)
+		}
+	}
+	if err != nil {
+		logger.Error("unable to get auction",
+			"auctionIdStr", auctionIDStr,
+			"err", err,
+		)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(parseErr, &maxBytesErr):
+		logger.Warn("request too large", "limit", maxBytesErr.Limit)
+		data.Message = fmt.Sprintf("Could not import files larger than %s in all, try fewer images at once",
+			formatBytes(maxBytesErr.Limit-maxImportFormBytes))
+	case r.Method == http.MethodPost:
+		data.Message, data.Rows, data.Imported = app.importPost(r, data.Auction)
+	}
+	for i := range data.Rows {
+		data.Rows[i].Item = data.Rows[i].Item.InCurrency(data.Auction.Currency)
+	}
+
+	err = webutil.RenderTemplateOrError(app.Tmpl, w, "import.html", data)
+	if err != nil {
+		logger.Error("unable to render template", "err", err)
+		return
+	}
+
+	logger.Info("success", "user", user, "rows", len(data.Rows), "imported", data.Imported)
+}
+
+// importPost reads the items file and images zip posted to the import
+// page into auction, and creates the items unless only a preview was
+// requested or a row has an error. It returns a message for the page, the
+// rows, and the number of items created.
+func (app *BidApp) importPost(r *http.Request, auction Auction) (string, []ImportRow, int) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	itemsFile, itemsHeader, err := r.FormFile("itemsFile")
+	if err != nil {
+		logger.Warn("no itemsFile", "err", err)
+		return "Choose a CSV or JSON file of items", nil, 0
+	}
+	defer itemsFile.Close()
+
+	rows, err := ReadImportRows(itemsFile, ImportFormat(itemsHeader.Filename), auction.ID)
+	if err != nil {
+		logger.Warn("unable to read items", "file", itemsHeader.Filename, "err", err)
+		return "Could not read items: " + err.Error(), nil, 0
+	}
+
+	var images *zip.Reader
+	imagesFile, imagesHeader, err := r.FormFile("imagesFile")
+	if err != nil && err != http.ErrMissingFile {
+		logger.Warn("no imagesFile", "err", err)
+		return "Could not read images", rows, 0
+	}
+	if err == nil {
+		defer imagesFile.Close()
+
+		images, err = zip.NewReader(imagesFile, imagesHeader.Size)
+		if err != nil {
+			logger.Warn("unable to read zip", "file", imagesHeader.Filename, "err", err)
+			return "Could not read images: " + err.Error(), rows, 0
+		}
+	}
+	MatchImportImages(rows, images)
+
+	invalid := 0
+	for _, row := range rows {
+		if !row.Valid() {
+			invalid++
+		}
+	}
+
+	switch {
+	case len(rows) == 0:
+		return "No items to import", rows, 0
+	case invalid > 0:
+		return fmt.Sprintf("Fix the %d of %d rows with errors and try again", invalid, len(rows)), rows, 0
+	case r.PostFormValue("action") != "import":
+		return fmt.Sprintf("All %d rows are valid, choose the files again to import them", len(rows)), rows, 0
+	}
+
+	ids, err := ImportItems(app.BidDB, rows, images, app.ImageQueue)
+	if err != nil {
+		logger.Error("unable to import items", "imported", len(ids), "err", err)
+		return fmt.Sprintf("Imported %d of %d items: %v", len(ids), len(rows), err), rows, len(ids)
+	}
+
+	logger.Info("imported items", "ids", ids)
+
+	return fmt.Sprintf("Imported %d items", len(ids)), rows, len(ids)
+}
@@ -0,0 +1,336 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadImportRows(t *testing.T) {
+	cases := []struct {
+		name       string
+		format     string
+		in         string
+		wantTitles []string
+		wantErrors [][]string
+		wantErr    error
+	}{
+		{
+			name:       "csv",
+			format:     "csv",
+			in:         "Title,Description,Artist,OpeningBid,MinBidIncr,ImageFileName\nPainting,Oil,Ann,10.00,1,painting.png\n",
+			wantTitles: []string{"Painting"},
+			wantErrors: [][]string{nil},
+		},
+		{
+			name:       "csv errors",
+			format:     "csv",
+			in:         "title,description,artist,openingBid,minBidIncr\n,Oil,Ann,ten,0\nVase,Clay,Bo,10,0\n",
+			wantTitles: []string{"", "Vase"},
+			wantErrors: [][]string{{"title is required", `openingBid "ten" is not a valid amount`}, nil},
+		},
+		{
+			name:       "json",
+			format:     "json",
+			in:         `[{"title": "Painting", "description": "Oil", "artist": "Ann", "openingBid": 10, "minBidIncr": "1.50"}]`,
+			wantTitles: []string{"Painting"},
+			wantErrors: [][]string{nil},
+		},
+		{
+			name:       "json too long",
+			format:     "json",
+			in:         `[{"title": "` + strings.Repeat("x", 41) + `", "description": "Oil", "artist": "Ann", "openingBid": 10, "minBidIncr": -1}]`,
+			wantTitles: []string{strings.Repeat("x", 41)},
+			wantErrors: [][]string{{"title is longer than 40 characters", `minBidIncr "-1" is not a valid amount`}},
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: ErrImportFormat,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := ReadImportRows(strings.NewReader(tc.in), tc.format, 1)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got err '%v' want '%v'", err, tc.wantErr)
+			}
+
+			var titles []string
+			var rowErrors [][]string
+			for n, row := range rows {
+				if row.Row != n+1 || row.Item.AuctionID != 1 {
+					t.Errorf("row %d got Row %d AuctionID %d", n, row.Row, row.Item.AuctionID)
+				}
+				titles = append(titles, row.Item.Title)
+				rowErrors = append(rowErrors, row.Errors)
+			}
+
+			if diff := cmp.Diff(tc.wantTitles, titles); diff != "" {
+				t.Errorf("titles mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErrors, rowErrors); diff != "" {
+				t.Errorf("errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// zipForTest returns a zip with a small image for each name.
+func zipForTest(t *testing.T, names ...string) *zip.Reader {
+	t.Helper()
+
+	return zipWithDataForTest(t, imageForTest(t, color.White), names...)
+}
+
+// zipWithDataForTest returns a zip with data for each name.
+func zipWithDataForTest(t *testing.T, data []byte, names ...string) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q) failed: %v", name, err)
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			t.Fatalf("Write(%q) failed: %v", name, err)
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+
+	return zr
+}
+
+func TestMatchImportImages(t *testing.T) {
+	cases := []struct {
+		name       string
+		images     *zip.Reader
+		fileName   string
+		wantImage  string
+		wantErrors int
+	}{
+		{"no image", nil, "", "", 0},
+		{"match", zipForTest(t, "photos/painting.png"), "Painting.PNG", "photos/painting.png", 0},
+		{"not in zip", zipForTest(t, "photos/painting.png"), "vase.png", "", 1},
+		{"no zip", nil, "painting.png", "", 1},
+	}
+
+	for _, tc := range cases {
+		rows := []ImportRow{{Row: 1, Item: Item{ImageFileName: tc.fileName}}}
+
+		MatchImportImages(rows, tc.images)
+
+		if rows[0].Image != tc.wantImage || len(rows[0].Errors) != tc.wantErrors {
+			t.Errorf("%s: got Image %q Errors %q want %q and %d errors", tc.name, rows[0].Image, rows[0].Errors, tc.wantImage, tc.wantErrors)
+		}
+	}
+}
+
+func TestImportItems(t *testing.T) {
+	store := memStoreForTest(t)
+
+	in := "title,description,artist,openingBid,minBidIncr,imageFileName\n" +
+		"Painting,Oil,Ann,10,1,Painting.PNG\n" +
+		"Sketch,Pencil,Ann,5,1,\n" +
+		"Vase,Clay,Bo,20,2,vase.png\n"
+
+	rows, err := ReadImportRows(strings.NewReader(in), "csv", 2)
+	if err != nil {
+		t.Fatalf("ReadImportRows() failed: %v", err)
+	}
+
+	MatchImportImages(rows, zipForTest(t, "photos/painting.png"))
+
+	if !rows[0].Valid() || rows[0].Image != "photos/painting.png" {
+		t.Errorf("row 1 got Image %q Errors %q", rows[0].Image, rows[0].Errors)
+	}
+	if !rows[1].Valid() || rows[1].Image != "" {
+		t.Errorf("row 2 got Image %q Errors %q", rows[1].Image, rows[1].Errors)
+	}
+	if rows[2].Valid() {
+		t.Errorf("row 3 with missing image is valid")
+	}
+
+	images := &LocalImageStore{Dir: t.TempDir()}
+	queue := NewImageQueue(store, images, &LocalImageStore{Dir: t.TempDir()}, DefaultImageWidths, DefaultImageLimits, ImageQueueSize)
+
+	_, err = ImportItems(store, rows, nil, queue)
+	if !errors.Is(err, ErrImportRows) {
+		t.Errorf("ImportItems() with invalid row got err '%v' want '%v'", err, ErrImportRows)
+	}
+
+	ids, err := ImportItems(store, rows[:2], zipForTest(t, "photos/painting.png"), queue)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("ImportItems() got ids %v err '%v' want 2 ids", ids, err)
+	}
+
+	item, err := store.GetItem(int(ids[0]))
+	if err != nil || item.Title != "Painting" || item.AuctionID != 2 {
+		t.Errorf("GetItem(%d) got %+v err '%v'", ids[0], item, err)
+	}
+
+	err = queue.ProcessQueued()
+	if err != nil {
+		t.Fatalf("ProcessQueued() failed: %v", err)
+	}
+
+	variants, err := store.GetImageVariants([]string{item.ImageFileName})
+	if err != nil || len(variants[item.ImageFileName]) == 0 {
+		t.Errorf("GetImageVariants(%q) got %v err '%v' want variants", item.ImageFileName, variants, err)
+	}
+
+	for _, name := range []string{item.ImageFileName, ThumbnailName(item.ImageFileName)} {
+		f, err := images.Get(name)
+		if err != nil {
+			t.Errorf("image %q not saved: %v", name, err)
+			continue
+		}
+		f.Close()
+	}
+}
+
+func TestImportItemsImageLimits(t *testing.T) {
+	cases := []struct {
+		name    string
+		limits  ImageLimits
+		zip     *zip.Reader
+		wantErr error
+	}{
+		{"too many bytes", ImageLimits{MaxBytes: 16}, zipForTest(t, "painting.png"), ErrImageTooLarge},
+		{"too many pixels", ImageLimits{MaxPixels: 16}, zipForTest(t, "painting.png"), ErrImageTooLarge},
+		{"not an image", DefaultImageLimits, zipWithDataForTest(t, []byte("not an image"), "painting.png"), ErrNotImage},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memStoreForTest(t)
+			queue := NewImageQueue(store, &LocalImageStore{Dir: t.TempDir()}, &LocalImageStore{Dir: t.TempDir()}, DefaultImageWidths, tc.limits, ImageQueueSize)
+
+			rows := []ImportRow{
+				{Row: 1, Item: Item{Title: "Sketch", Artist: "Ann", AuctionID: 1}},
+				{Row: 2, Item: Item{Title: "Painting", Artist: "Ann", AuctionID: 1}, Image: "painting.png"},
+			}
+
+			ids, err := ImportItems(store, rows, tc.zip, queue)
+			if !errors.Is(err, tc.wantErr) || len(ids) != 0 {
+				t.Errorf("ImportItems() got ids %v err '%v' want no ids and '%v'", ids, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportHandler(t *testing.T) {
+	db := sqliteDBForTest(t)
+
+	app := storeAppForTest(t, db)
+	app.DB = db.sqlDB
+	app.Cfg.Auth.LoginExpires = "1h"
+
+	err := db.sqlDB.RegisterUser("importer", "Import User", "import@user", "password")
+	if err != nil {
+		t.Fatalf("RegisterUser() failed: %v", err)
+	}
+	err = setAdmin(db.sqlDB, "importer", true)
+	if err != nil {
+		t.Fatalf("setAdmin() failed: %v", err)
+	}
+	token, err := app.LoginUser("importer", "password")
+	if err != nil {
+		t.Fatalf("LoginUser() failed: %v", err)
+	}
+
+	// a request of more than 1.0 MB and 50.0 KB is too large
+	app.ImageLimits = ImageLimits{MaxBytes: 1 << 10}
+
+	csvFile := "title,description,artist,openingBid,minBidIncr\nImport Test,About,Artist,10,1\n"
+	csvWithImage := "title,description,artist,openingBid,minBidIncr,imageFileName\nImage Test,About,Artist,10,1,a.png\n"
+
+	var imagesZip bytes.Buffer
+	zw := zip.NewWriter(&imagesZip)
+	fw, _ := zw.Create("a.png")
+	fw.Write(imageForTest(t, color.White))
+	zw.Close()
+
+	cases := []struct {
+		name       string
+		method     string
+		action     string
+		itemsFile  string
+		imagesFile []byte
+		wantStatus int
+		wantInBody string
+		wantItems  int
+	}{
+		{"get", http.MethodGet, "", "", nil, http.StatusOK, "Import Items", 1},
+		{"invalid method", http.MethodPut, "", "", nil, http.StatusMethodNotAllowed, "Method Not Allowed", 1},
+		{"no file", http.MethodPost, "preview", "", nil, http.StatusOK, "Choose a CSV or JSON file", 1},
+		{"preview", http.MethodPost, "preview", csvFile, nil, http.StatusOK, "All 1 rows are valid", 1},
+		{"errors", http.MethodPost, "import", csvFile + ",,,,\n", nil, http.StatusOK, "Fix the 1 of 2 rows", 1},
+		{"import", http.MethodPost, "import", csvFile, nil, http.StatusOK, "Imported 1 items", 2},
+		{"image without zip", http.MethodPost, "import", csvWithImage, nil, http.StatusOK, "needs a zip of images", 2},
+		{"import image", http.MethodPost, "import", csvWithImage, imagesZip.Bytes(), http.StatusOK, "Imported 1 items", 3},
+		{"too large", http.MethodPost, "import", csvFile, make([]byte, 2<<20), http.StatusOK, "Could not import files larger than 50.0 KB in all", 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			mw.WriteField("auctionId", "2")
+			mw.WriteField("action", tc.action)
+			if tc.itemsFile != "" {
+				fw, _ := mw.CreateFormFile("itemsFile", "items.csv")
+				io.WriteString(fw, tc.itemsFile)
+			}
+			if tc.imagesFile != nil {
+				fw, _ := mw.CreateFormFile("imagesFile", "images.zip")
+				fw.Write(tc.imagesFile)
+			}
+			mw.Close()
+
+			r := httptest.NewRequest(tc.method, "/import", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			r.AddCookie(&http.Cookie{Name: webauth.LoginTokenCookieName, Value: token.Value})
+			w := httptest.NewRecorder()
+
+			app.ImportHandler(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, tc.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantInBody) {
+				t.Errorf("got body %q want %q", w.Body, tc.wantInBody)
+			}
+
+			items, err := db.GetItems(2)
+			if err != nil || len(items) != tc.wantItems {
+				t.Errorf("GetItems(2) got %d items err '%v' want %d", len(items), err, tc.wantItems)
+			}
+		})
+	}
+}
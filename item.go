@@ -4,10 +4,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bnixon67/webapp/webauth"
 	"github.com/bnixon67/webapp/webhandler"
@@ -20,8 +22,27 @@ type ItemPageData struct {
 	Message       string
 	User          webauth.User
 	Item          Item
+	Auction       Auction // auction of Item
 	IsAuctionOpen bool
+	BuyNow        bool // true if Item can be bought with buy now
+	BidsHidden    bool // true if only bids of User are shown
 	Bids          []Bid
+	WinningBids   []WinningBid // if Item has more than one unit
+	MaxBid        Money        // maximum bid of User for Item
+	OpensAt       time.Time    // when bidding opens for Item
+	ClosesAt      time.Time    // when bidding closes for Item
+
+	// Variants are the variants of the images of Item, by image name.
+	Variants map[string][]ImageVariant
+
+	// ImageStatuses are the status of processing the images of Item, by
+	// image name. A placeholder is shown until an image is ready.
+	ImageStatuses map[string]ImageStatus
+}
+
+// NotStarted returns true if bidding has not opened yet for Item.
+func (d ItemPageData) NotStarted() bool {
+	return time.Now().Before(d.OpensAt)
 }
 
 // ItemHandler display an item.
@@ -84,6 +105,24 @@ func (app *BidApp) itemGetHandler(w http.ResponseWriter, r *http.Request, id int
 		return
 	}
 
+	// get auction of item from database
+	auction, err := app.BidDB.GetAuctionByID(item.AuctionID)
+	if err != nil {
+		logger.Error("unable to GetAuctionByID", "id", id, "err", err)
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// get variants of images of item from database
+	variants, err := app.BidDB.GetImageVariants(item.Images)
+	if err != nil {
+		logger.Error("unable to GetImageVariants", "id", id, "err", err)
+	}
+	imageStatuses, err := app.BidDB.GetImageStatuses(item.Images)
+	if err != nil {
+		logger.Error("unable to GetImageStatuses", "id", id, "err", err)
+	}
+
 	// get bids for item from database
 	bids, err := app.BidDB.GetBidsForItem(id)
 	if err != nil {
@@ -91,6 +130,34 @@ func (app *BidApp) itemGetHandler(w http.ResponseWriter, r *http.Request, id int
 		// TODO: what to display to user if this fails
 	}
 
+	// get maximum bid of user for item from database
+	maxBid, err := app.BidDB.GetMaxBid(id, user.Username)
+	if err != nil {
+		logger.Error("unable to GetMaxBid", "id", id, "err", err)
+	}
+
+	// hide other bids on a sealed item until it closes
+	bidsHidden := auction.BidsHidden(item, user)
+	if bidsHidden {
+		item = item.WithBidsHidden()
+		bids = OwnBids(bids, user.Username)
+	}
+
+	// get winning bids for item with more than one unit from database
+	var winningBids []WinningBid
+	if item.MultiQuantity() {
+		winningBids, err = app.BidDB.GetWinningBids(id)
+		if err != nil {
+			logger.Error("unable to GetWinningBids", "id", id, "err", err)
+		}
+	}
+
+	// show amounts in the currency of the auction
+	item = item.InCurrency(auction.Currency)
+	bids = inCurrency(bids, auction.Currency)
+	winningBids = inCurrency(winningBids, auction.Currency)
+	maxBid = maxBid.In(auction.Currency)
+
 	// display page
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "item.html",
 		ItemPageData{
@@ -98,8 +165,17 @@ func (app *BidApp) itemGetHandler(w http.ResponseWriter, r *http.Request, id int
 			Message:       "",
 			User:          user,
 			Item:          item,
-			IsAuctionOpen: app.IsAuctionOpen(),
+			Auction:       auction,
+			IsAuctionOpen: auction.IsAuctionOpen(item),
+			BuyNow:        auction.BuyNowAvailable(item),
+			BidsHidden:    bidsHidden,
+			OpensAt:       auction.ItemOpensAt(item),
+			ClosesAt:      auction.ItemClosesAt(item),
 			Bids:          bids,
+			WinningBids:   winningBids,
+			MaxBid:        maxBid,
+			Variants:      variants,
+			ImageStatuses: imageStatuses,
 		})
 	if err != nil {
 		logger.Error("unable to RenderTemplate", "err", err)
@@ -107,7 +183,7 @@ func (app *BidApp) itemGetHandler(w http.ResponseWriter, r *http.Request, id int
 	}
 
 	logger.Info("displayed item", "username", user.Username, "item", item,
-		"auction open", app.IsAuctionOpen(), "bids", len(bids))
+		"auction open", auction.IsAuctionOpen(item), "bids", len(bids))
 }
 
 func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id int, user webauth.User) {
@@ -117,26 +193,66 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 	var msg string
 	var err error
 
-	// get bidAmount
-	bidAmountStr := r.PostFormValue("bidAmount")
-	if bidAmountStr == "" {
-		logger.Warn("no bidAmount")
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	bidAmount, err := strconv.ParseFloat(bidAmountStr, 64)
-	if err != nil {
-		msg = "Invalid bid amount."
-		logger.Error("unable to parse",
-			"bidAmountStr", bidAmountStr,
-			"err", err)
+	// buy now instead of placing a bid
+	buyNow := r.PostFormValue("buyNow") != ""
+
+	// pledge instead of placing a bid
+	pledgeAmountStr := r.PostFormValue("pledgeAmount")
+	pledge := pledgeAmountStr != ""
+
+	var pledgeAmount Money
+	if pledge {
+		pledgeAmount, err = ParseMoney(pledgeAmountStr)
+		if errors.Is(err, ErrSubCent) {
+			msg = "Pledge must be in whole cents."
+		} else if err != nil || pledgeAmount.IsZero() {
+			msg = "Invalid pledge amount."
+		}
+		if msg != "" {
+			logger.Error("invalid pledgeAmount",
+				"pledgeAmountStr", pledgeAmountStr,
+				"err", err)
+		}
 	}
 
-	// negative bid
-	if bidAmount <= 0 {
-		msg = "Invalid bid amount."
-		logger.Error("zero or negative bid",
-			"bidAmount", bidAmount)
+	// get bidAmount and units, which are not needed to buy now or pledge
+	var bidAmount Money
+	units := 1
+	if !buyNow && !pledge {
+		bidAmountStr := r.PostFormValue("bidAmount")
+		if bidAmountStr == "" {
+			logger.Warn("no bidAmount")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bidAmount, err = ParseMoney(bidAmountStr)
+		if errors.Is(err, ErrSubCent) {
+			msg = "Bid must be in whole cents."
+			logger.Error("fraction of a cent",
+				"bidAmountStr", bidAmountStr)
+		} else if err != nil {
+			msg = "Invalid bid amount."
+			logger.Error("unable to parse",
+				"bidAmountStr", bidAmountStr,
+				"err", err)
+		} else if bidAmount.IsZero() {
+			// negative bids are rejected by ParseMoney
+			msg = "Invalid bid amount."
+			logger.Error("zero bid",
+				"bidAmount", bidAmount)
+		}
+
+		// units is optional and only for items with a quantity
+		unitsStr := r.PostFormValue("units")
+		if unitsStr != "" {
+			units, err = strconv.Atoi(unitsStr)
+			if err != nil || units < 1 {
+				msg = "Invalid quantity."
+				logger.Error("invalid units",
+					"unitsStr", unitsStr,
+					"err", err)
+			}
+		}
 	}
 
 	// invalid user
@@ -145,24 +261,70 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 		logger.Error("invalid user")
 	}
 
-	// submit bid if we have a valid user and bidAmount and open Auction
-	if user != (webauth.User{}) && bidAmount > 0 && app.IsAuctionOpen() {
-		bidResult, err := app.BidDB.PlaceBid(id, bidAmount, user.Username)
+	// get item from database
+	item, err := app.BidDB.GetItem(id)
+	if err != nil {
+		logger.Error("unable to get item", "id", id, "err", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// get auction of item from database
+	auction, err := app.BidDB.GetAuctionByID(item.AuctionID)
+	if err != nil {
+		logger.Error("unable to get auction", "id", id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// submit pledge if we have a valid user and pledgeAmount and open Auction
+	if pledge {
+		if user != (webauth.User{}) && pledgeAmount.Cents > 0 && auction.IsAuctionOpen(item) {
+			pledgeResult, err := app.Pledge(id, pledgeAmount, user.Username)
+			if err != nil {
+				logger.Error("unable to Pledge",
+					"id", id, "pledgeAmount", pledgeAmount,
+					"user", user, "err", err)
+			} else {
+				logger.Info("Pledge",
+					"id", id,
+					"pledgeAmount", pledgeAmount,
+					"user", user,
+					"pledgeResult", pledgeResult,
+				)
+			}
+			msg = pledgeResult.Message
+		} else if !auction.IsAuctionOpen(item) {
+			msg = "Auction is not open"
+		}
+	} else if user != (webauth.User{}) && (buyNow || bidAmount.Cents > 0) && units > 0 && auction.IsAuctionOpen(item) {
+		// submit bid if we have a valid user and bidAmount and open Auction
+		var bidResult BidResult
+		if buyNow {
+			bidResult, err = app.BuyNow(id, user.Username)
+		} else {
+			bidResult, err = app.PlaceUnitsBid(id, bidAmount, units, user.Username)
+		}
 		if err != nil {
 			logger.Error("unable to PlaceBid",
-				"id", id, "bidAmount", bidAmount, "user", user,
-				"err", err)
+				"id", id, "bidAmount", bidAmount, "units", units,
+				"buyNow", buyNow,
+				"user", user, "err", err)
 			msg = bidResult.Message
 		} else {
 			logger.Info("PlaceBid",
 				"id", id,
 				"bidAmount", bidAmount,
+				"units", units,
+				"buyNow", buyNow,
 				"user", user,
 				"bidResult", bidResult,
 			)
 			msg = bidResult.Message
 
-			if bidResult.BidPlaced && bidResult.PriorBidder != "" && bidResult.PriorBidder != user.Username {
+			// only notify the prior bidder once their maximum bid
+			// is exceeded, since automatic bids keep them ahead
+			if bidResult.Outbid() {
 				user, err := app.DB.UserForName(bidResult.PriorBidder)
 				if err != nil {
 					logger.Error("unable to GetUserForName",
@@ -170,16 +332,14 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 						"err", err)
 				}
 
-				// get item from database
-				// TODO: eliminate extra GetItem call
-				item, err := app.BidDB.GetItem(id)
-				if err != nil {
-					logger.Error("unable to GetItem", "id", id, "err", err)
-				}
-
 				emailText := fmt.Sprintf(
 					"You have been outbid on %q. Visit %s/item/%d to rebid.",
 					item.Title, app.Cfg.Auth.BaseURL, id)
+				if buyNow {
+					emailText = fmt.Sprintf(
+						"%q was bought with Buy Now, so bidding has closed.",
+						item.Title)
+				}
 
 				err = app.Cfg.SMTP.SendMessage(app.Cfg.EmailFrom, []string{user.Email}, app.Cfg.App.Name, emailText)
 				if err != nil {
@@ -189,18 +349,30 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 				}
 			}
 		}
-	} else if !app.IsAuctionOpen() {
+	} else if item.Sold() {
+		msg = "Item already sold"
+	} else if !auction.IsAuctionOpen(item) {
 		msg = "Auction is not open"
 	}
 
-	// get item from database
-	item, err := app.BidDB.GetItem(id)
+	// get item from database to reflect the bid
+	item, err = app.BidDB.GetItem(id)
 	if err != nil {
 		logger.Error("unable to get item", "id", id, "err", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	// get variants of images of item from database
+	variants, err := app.BidDB.GetImageVariants(item.Images)
+	if err != nil {
+		logger.Error("unable to get image variants", "id", id, "err", err)
+	}
+	imageStatuses, err := app.BidDB.GetImageStatuses(item.Images)
+	if err != nil {
+		logger.Error("unable to get image statuses", "id", id, "err", err)
+	}
+
 	// get bids for item from database
 	bids, err := app.BidDB.GetBidsForItem(id)
 	if err != nil {
@@ -208,6 +380,34 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 		// TODO: what to display to user if this fails
 	}
 
+	// get maximum bid of user for item from database
+	maxBid, err := app.BidDB.GetMaxBid(id, user.Username)
+	if err != nil {
+		logger.Error("unable to get max bid", "id", id, "err", err)
+	}
+
+	// hide other bids on a sealed item until it closes
+	bidsHidden := auction.BidsHidden(item, user)
+	if bidsHidden {
+		item = item.WithBidsHidden()
+		bids = OwnBids(bids, user.Username)
+	}
+
+	// get winning bids for item with more than one unit from database
+	var winningBids []WinningBid
+	if item.MultiQuantity() {
+		winningBids, err = app.BidDB.GetWinningBids(id)
+		if err != nil {
+			logger.Error("unable to get winning bids", "id", id, "err", err)
+		}
+	}
+
+	// show amounts in the currency of the auction
+	item = item.InCurrency(auction.Currency)
+	bids = inCurrency(bids, auction.Currency)
+	winningBids = inCurrency(winningBids, auction.Currency)
+	maxBid = maxBid.In(auction.Currency)
+
 	// display page
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "item.html",
 		ItemPageData{
@@ -215,8 +415,17 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 			Message:       msg,
 			User:          user,
 			Item:          item,
-			IsAuctionOpen: app.IsAuctionOpen(),
+			Auction:       auction,
+			IsAuctionOpen: auction.IsAuctionOpen(item),
+			BuyNow:        auction.BuyNowAvailable(item),
+			BidsHidden:    bidsHidden,
+			OpensAt:       auction.ItemOpensAt(item),
+			ClosesAt:      auction.ItemClosesAt(item),
 			Bids:          bids,
+			WinningBids:   winningBids,
+			MaxBid:        maxBid,
+			Variants:      variants,
+			ImageStatuses: imageStatuses,
 		})
 	if err != nil {
 		logger.Error("unable to RenderTemplate", "err", err)
@@ -227,7 +436,7 @@ func (app *BidApp) itemPostHandler(w http.ResponseWriter, r *http.Request, id in
 		"message", msg,
 		"username", user.Username,
 		"item", item,
-		"auction open", app.IsAuctionOpen(),
+		"auction open", auction.IsAuctionOpen(item),
 		"bids", len(bids),
 	)
 }
@@ -63,12 +63,24 @@ func TestItemHandler(t *testing.T) {
 		t.Fatalf("unable to get item: %v", err)
 	}
 
+	// get auction of item from database
+	auction, err := app.BidDB.GetAuctionByID(item.AuctionID)
+	if err != nil {
+		t.Fatalf("unable to get auction: %v", err)
+	}
+
 	// get bids for item from database
 	bids, err := app.BidDB.GetBidsForItem(id)
 	if err != nil {
 		t.Fatalf("unable to get bids for item: %v", err)
 	}
 
+	// get maximum bid of user for item from database
+	maxBid, err := app.BidDB.GetMaxBid(id, user.Username)
+	if err != nil {
+		t.Fatalf("unable to get max bid for item: %v", err)
+	}
+
 	tests := []webhandler.TestCase{
 		{
 			Name:          "Invalid Method",
@@ -111,9 +123,14 @@ func TestItemHandler(t *testing.T) {
 			RequestMethod: http.MethodGet,
 			WantStatus:    http.StatusOK,
 			WantBody: itemBody(t, ItemPageData{
-				Title: app.Cfg.App.Name,
-				Item:  item,
-				Bids:  bids,
+				Title:         app.Cfg.App.Name,
+				Item:          item,
+				Auction:       auction,
+				IsAuctionOpen: auction.IsAuctionOpen(item),
+				BuyNow:        auction.BuyNowAvailable(item),
+				Bids:          bids,
+				OpensAt:       auction.ItemOpensAt(item),
+				ClosesAt:      auction.ItemClosesAt(item),
 			}),
 		},
 		{
@@ -125,10 +142,16 @@ func TestItemHandler(t *testing.T) {
 			},
 			WantStatus: http.StatusOK,
 			WantBody: itemBody(t, ItemPageData{
-				Title: app.Cfg.App.Name,
-				User:  user,
-				Item:  item,
-				Bids:  bids,
+				Title:         app.Cfg.App.Name,
+				User:          user,
+				Item:          item,
+				Auction:       auction,
+				IsAuctionOpen: auction.IsAuctionOpen(item),
+				BuyNow:        auction.BuyNowAvailable(item),
+				Bids:          bids,
+				MaxBid:        maxBid,
+				OpensAt:       auction.ItemOpensAt(item),
+				ClosesAt:      auction.ItemClosesAt(item),
 			}),
 		},
 	}
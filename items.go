@@ -17,6 +17,7 @@ type ItemsPageData struct {
 	Message string
 	User    webauth.User
 	Items   []Item
+	Auction Auction
 }
 
 // ItemsHandler displays all the items in a table.
@@ -43,12 +44,18 @@ func (app *BidApp) ItemsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, err := app.BidDB.GetItems()
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := app.BidDB.GetItems(auction.ID)
 	if err != nil {
 		logger.Error("failed to get items", "err", err)
 		webutil.RespondWithError(w, http.StatusInternalServerError)
 		return
 	}
+	items = inCurrency(auction.HideBids(items, user), auction.Currency)
 
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "items.html",
 		ItemsPageData{
@@ -56,6 +63,7 @@ func (app *BidApp) ItemsHandler(w http.ResponseWriter, r *http.Request) {
 			Message: "",
 			User:    user,
 			Items:   items,
+			Auction: auction,
 		})
 	if err != nil {
 		logger.Error("unable to render template", "err", err)
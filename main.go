@@ -5,12 +5,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/bnixon67/webapp/webapp"
 	"github.com/bnixon67/webapp/webauth"
@@ -23,8 +23,13 @@ import (
 
 type BidApp struct {
 	*webauth.AuthApp
-	*BidDB
-	AuctionStart, AuctionEnd time.Time
+	BidDB          BidStore     // auctions, items, and bids
+	Images         ImageStore   // images of items
+	ImageWidths    []int        // widths of the variants of images
+	ImageLimits    ImageLimits  // limits on uploaded images
+	ImageQueue     *ImageQueue  // uploaded images waiting to be processed
+	DefaultAuction string       // slug of auction for requests without one
+	Updates        *Broadcaster // bid updates sent to browsers
 }
 
 const (
@@ -35,17 +40,13 @@ const (
 	ExitDB                  // ExitConfig indicates a database error.
 	ExitApp                 // ExitHandler indicates an app error.
 	ExitServer              // ExitServer indicates a server error.
+	ExitSchema              // ExitSchema indicates the database schema is not current.
 )
 
-func main() {
-	// Check for command line argument with config file.
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [config file]\n", os.Args[0])
-		os.Exit(ExitUsage)
-	}
-
+// loadConfig reads and validates the config file, exiting on an error.
+func loadConfig(fileName string) *webauth.Config {
 	// Read config.
-	cfg, err := webauth.LoadConfigFromJSON(os.Args[1])
+	cfg, err := webauth.LoadConfigFromJSON(fileName)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
 		os.Exit(ExitConfig)
@@ -62,6 +63,132 @@ func main() {
 		os.Exit(ExitConfig)
 	}
 
+	return cfg
+}
+
+// loadImageStore returns the image store of the config file, and its
+// config, exiting on an error.
+func loadImageStore(fileName string) (ImageStore, ImageStoreConfig) {
+	imagesCfg, err := LoadImageStoreConfig(fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load images config:", err)
+		os.Exit(ExitConfig)
+	}
+
+	images, err := NewImageStore(imagesCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init image store:", err)
+		os.Exit(ExitConfig)
+	}
+
+	return images, imagesCfg
+}
+
+// templateFuncs returns the custom functions of the templates, which get
+// the URLs of images from images.
+func templateFuncs(images ImageStore) template.FuncMap {
+	return template.FuncMap{
+		"ToTimeZone": webutil.ToTimeZone,
+		"ImageURL":   images.URL,
+		"ThumbnailURL": func(name string) string {
+			return images.URL(ThumbnailName(name))
+		},
+		"SrcSet": func(variants []ImageVariant, typ string) string {
+			return SrcSet(images, variants, typ)
+		},
+	}
+}
+
+// migrateMain runs the migrate command for action with the database of
+// the config file.
+func migrateMain(action, configFileName string) {
+	cfg := loadConfig(configFileName)
+
+	dataSourceName, err := MigrateDataSourceName(cfg.SQL.DriverName, cfg.SQL.DataSourceName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse data source name:", err)
+		os.Exit(ExitConfig)
+	}
+
+	db, err := webauth.InitDB(cfg.SQL.DriverName, dataSourceName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init db:", err)
+		os.Exit(ExitDB)
+	}
+	defer db.Close()
+
+	err = Migrate(os.Stdout, db.DB, cfg.SQL.DriverName, action)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to migrate:", err)
+		if errors.Is(err, ErrMigrateCommand) {
+			os.Exit(ExitUsage)
+		}
+		if errors.Is(err, ErrSchemaBehind) || errors.Is(err, ErrSchemaAhead) {
+			os.Exit(ExitSchema)
+		}
+		os.Exit(ExitDB)
+	}
+}
+
+func main() {
+	args := os.Args[1:]
+
+	// Check for command line arguments.
+	switch {
+	case len(args) == 1 && !isCommandWord(args[0]):
+		// a config file alone runs the server, as before subcommands
+		serve(args[0])
+	case len(args) == 2 && args[0] == "serve":
+		serve(args[1])
+	case len(args) == 3 && args[0] == "migrate":
+		migrateMain(args[1], args[2])
+	default:
+		cmd, rest, ok := findCommand(args)
+		if !ok || len(rest) == 0 {
+			usage(os.Stderr)
+			os.Exit(ExitUsage)
+		}
+		os.Exit(runCommand(cmd, rest[0], rest[1:]))
+	}
+}
+
+// openDB opens the database of cfg, exiting on an error or if its schema
+// does not match the migrations.
+func openDB(cfg *webauth.Config) *webauth.AuthDB {
+	dataSourceName := cfg.SQL.DataSourceName
+	if cfg.SQL.DriverName == DriverSQLite {
+		dataSourceName = SQLiteDataSourceName(dataSourceName)
+	}
+	db, err := webauth.InitDB(cfg.SQL.DriverName, dataSourceName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init db:", err)
+		os.Exit(ExitDB)
+	}
+
+	// Refuse to start unless the schema matches the migrations.
+	err = CheckSchema(db.DB, cfg.SQL.DriverName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to check schema:", err)
+		if errors.Is(err, ErrSchemaBehind) || errors.Is(err, ErrSchemaAhead) {
+			fmt.Fprintf(os.Stderr, "Run: %s migrate up [config file]\n", os.Args[0])
+			os.Exit(ExitSchema)
+		}
+		os.Exit(ExitDB)
+	}
+
+	return db
+}
+
+// serve runs the web server with the config file.
+func serve(configFileName string) {
+	cfg := loadConfig(configFileName)
+	images, imagesCfg := loadImageStore(configFileName)
+	uploads, err := NewUploadStore(imagesCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init upload store:", err)
+		os.Exit(ExitConfig)
+	}
+
 	// Initialize logging.
 	err = weblog.Init(cfg.Log)
 	if err != nil {
@@ -69,10 +196,8 @@ func main() {
 		os.Exit(ExitLog)
 	}
 
-	// Define the custom function
-	funcMap := template.FuncMap{
-		"ToTimeZone": webutil.ToTimeZone,
-	}
+	// Define the custom functions.
+	funcMap := templateFuncs(images)
 
 	// Initialize templates with custom functions.
 	tmpl, err := webutil.TemplatesWithFuncs(cfg.App.TmplPattern, funcMap)
@@ -82,11 +207,7 @@ func main() {
 	}
 
 	// Initialize db
-	db, err := webauth.InitDB(cfg.SQL.DriverName, cfg.SQL.DataSourceName)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to init db:", err)
-		os.Exit(ExitDB)
-	}
+	db := openDB(cfg)
 
 	// Create the web login app.
 	app, err := webauth.NewApp(webapp.WithName(cfg.App.Name), webapp.WithTemplate(tmpl), webauth.WithConfig(*cfg), webauth.WithDB(db))
@@ -95,14 +216,32 @@ func main() {
 		os.Exit(ExitApp)
 	}
 
+	// Create the store for the database driver.
+	bidDB, err := NewBidStore(cfg.SQL.DriverName, app.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to init bid db:", err)
+		os.Exit(ExitDB)
+	}
+
 	// Embed web login app into BidApp
-	bidApp := BidApp{AuthApp: app, BidDB: &BidDB{}}
-	bidApp.BidDB.sqlDB = app.DB
+	bidApp := BidApp{
+		AuthApp:     app,
+		BidDB:       bidDB,
+		Images:      images,
+		ImageWidths: imagesCfg.ImageWidths(),
+		ImageLimits: imagesCfg.ImageLimits(),
+		ImageQueue:  NewImageQueue(bidDB, images, uploads, imagesCfg.ImageWidths(), imagesCfg.ImageLimits(), ImageQueueSize),
+		Updates:     NewBroadcaster(),
+	}
 
 	err = bidApp.ConfigAuction()
 	if err != nil {
-		slog.Error("failed to ConfigAuction", "err", err)
-		return
+		fmt.Fprintln(os.Stderr, "Failed to config auction:", err)
+		if errors.Is(err, ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "Run: %s auction create [config file] -slug slug -name name -start time -end time\n", os.Args[0])
+			fmt.Fprintf(os.Stderr, " or: %s auction set-current [config file] slug\n", os.Args[0])
+		}
+		os.Exit(ExitConfig)
 	}
 
 	slog.Info("create app", "bidApp", bidApp)
@@ -116,9 +255,12 @@ func main() {
 	mux.HandleFunc("/gobid.css", webhandler.FileHandler("html/gobid.css"))
 	mux.HandleFunc("/bids.js", webhandler.FileHandler("html/bids.js"))
 	mux.HandleFunc("/gallery.js", webhandler.FileHandler("html/gallery.js"))
+	mux.HandleFunc("/item.js", webhandler.FileHandler("html/item.js"))
+	mux.HandleFunc("/edit.js", webhandler.FileHandler("html/edit.js"))
 	mux.HandleFunc("/toggle.js", webhandler.FileHandler("html/toggle.js"))
 	mux.HandleFunc("/favicon.ico", webhandler.FileHandler("html/favicon.ico"))
-	mux.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir("images"))))
+	mux.HandleFunc("/placeholder.svg", webhandler.FileHandler("html/placeholder.svg"))
+	mux.Handle(imageURLPrefix, http.StripPrefix(imageURLPrefix, ImageServer(images)))
 
 	mux.HandleFunc("GET /login", app.LoginGetHandler)
 	mux.HandleFunc("POST /login", app.LoginPostHandler)
@@ -135,9 +277,20 @@ func main() {
 	mux.HandleFunc("/items", bidApp.ItemsHandler)
 	mux.HandleFunc("/item/", bidApp.ItemHandler)
 	mux.HandleFunc("/edit/", bidApp.ItemEditHandler)
+	mux.HandleFunc("/import", bidApp.ImportHandler)
 	mux.HandleFunc("/winners", bidApp.WinnerHandler)
 	mux.HandleFunc("/winnerscsv", bidApp.WinnersCSVHandler)
+	mux.HandleFunc("/archive", bidApp.ArchiveHandler)
 	mux.HandleFunc("/bids", bidApp.BidsHandler)
+	mux.HandleFunc("/auctions", bidApp.AuctionsHandler)
+	mux.HandleFunc("/stream/items", bidApp.ItemsStreamHandler)
+	mux.HandleFunc("/stream/item/{id}", bidApp.ItemStreamHandler)
+	mux.HandleFunc("/a/{slug}/gallery", bidApp.GalleryHandler)
+	mux.HandleFunc("/a/{slug}/items", bidApp.ItemsHandler)
+	mux.HandleFunc("/a/{slug}/winners", bidApp.WinnerHandler)
+	mux.HandleFunc("/a/{slug}/winnerscsv", bidApp.WinnersCSVHandler)
+	mux.HandleFunc("/a/{slug}/archive", bidApp.ArchiveHandler)
+	mux.HandleFunc("/a/{slug}/bids", bidApp.BidsHandler)
 	mux.HandleFunc("/events", app.EventsHandler)
 	mux.HandleFunc("/eventscsv", app.EventsCSVHandler)
 	mux.HandleFunc("GET /confirm", app.ConfirmHandlerGet)
@@ -161,6 +314,12 @@ func main() {
 	// Create a new context.
 	ctx := context.Background()
 
+	// Remove images that are no longer used by any item.
+	go RunImageSweeper(ctx, bidDB, images, ImageSweepInterval, ImageSweepMinAge)
+
+	// Process uploaded images in the background.
+	go bidApp.ImageQueue.Run(ctx, ImageQueueWorkers, ImageQueueRescan)
+
 	// Run the web server.
 	err = srv.Run(ctx)
 	if err != nil {
@@ -5,6 +5,7 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"testing"
 )
@@ -15,28 +16,40 @@ const (
 	file           = "sql/test.sql"
 )
 
-func setup() {
+// errSetup is the error from setup, if any. Tests that need the test
+// database are skipped if it cannot be set up, which leaves the tests that
+// use a MemStore.
+var errSetup error
+
+func setup() error {
 	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer db.Close()
 
-	script, err := os.ReadFile(file)
+	_, err = MigrateUp(db, driverName)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	_, err = db.Exec(string(script))
+	script, err := os.ReadFile(file)
 	if err != nil {
-		panic(err)
+		return err
 	}
+
+	_, err = db.Exec(string(script))
+	return err
 }
 
 func teardown() {
 }
 
 func TestMain(m *testing.M) {
-	setup()
+	errSetup = setup()
+	if errSetup != nil {
+		fmt.Fprintln(os.Stderr, "skipping database tests:", errSetup)
+	}
 	ret := m.Run()
 	if ret == 0 {
 		teardown()
@@ -0,0 +1,922 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemStore is a BidStore that keeps everything in memory, such as for tests
+// that do not have a database. Bids and pledges follow the same rules as
+// the procedures in the database, and each is placed while holding a lock
+// as the procedures do within a transaction.
+//
+// The zero value is not usable. Use NewMemStore.
+type MemStore struct {
+	mu         sync.Mutex
+	config     map[string]ConfigItem
+	auctions   map[int]Auction
+	items      map[int]Item // without bids or pledges
+	images     map[int][]string
+	variants   map[string][]ImageVariant // by image name
+	statuses   map[string]ImageStatus    // by image name
+	increments map[string][]Increment
+	users      map[string]memUser
+	bids       []memBid
+	maxBids    map[memMaxBidKey]Money
+	pledges    []memPledge
+	lastTime   time.Time // time of the latest bid or pledge
+}
+
+// memUser is the name and email of a bidder, which the database gets from
+// the users table.
+type memUser struct {
+	FullName string
+	Email    string
+}
+
+// memBid is a row of the bids table.
+type memBid struct {
+	ItemID  int
+	Created time.Time
+	Bidder  string
+	Amount  Money
+	Units   int
+	BuyNow  bool
+}
+
+// memMaxBidKey is the key of the max_bids table.
+type memMaxBidKey struct {
+	ItemID int
+	Bidder string
+}
+
+// memPledge is a row of the pledges table.
+type memPledge struct {
+	ItemID  int
+	Created time.Time
+	Pledger string
+	Amount  Money
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		config:     make(map[string]ConfigItem),
+		auctions:   make(map[int]Auction),
+		items:      make(map[int]Item),
+		images:     make(map[int][]string),
+		variants:   make(map[string][]ImageVariant),
+		statuses:   make(map[string]ImageStatus),
+		increments: make(map[string][]Increment),
+		users:      make(map[string]memUser),
+		maxBids:    make(map[memMaxBidKey]Money),
+	}
+}
+
+// SetConfigItem adds or replaces the config item with the name of config.
+func (s *MemStore) SetConfigItem(config ConfigItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config[config.Name] = config
+}
+
+// SetUser adds or replaces the name and email of userName, which are shown
+// with their bids and wins.
+func (s *MemStore) SetUser(userName, fullName, email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userName] = memUser{FullName: fullName, Email: email}
+}
+
+// SetIncrements adds or replaces the increment schedule named name. The
+// upper limit of each increment is set from the next one in the schedule.
+func (s *MemStore) SetIncrements(name string, increments []Increment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule := slices.Clone(increments)
+	slices.SortFunc(schedule, func(a, b Increment) int {
+		return a.From.Cmp(b.From)
+	})
+	for i := range schedule {
+		schedule[i].To = Money{}
+		if i > 0 {
+			schedule[i-1].To = schedule[i].From
+		}
+	}
+
+	s.increments[name] = schedule
+}
+
+// CreateAuction adds auction and returns its ID. The slug of the auction
+// must be unique.
+func (s *MemStore) CreateAuction(auction Auction) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.auctions {
+		if a.Slug == auction.Slug {
+			return 0, fmt.Errorf("%w: duplicate slug %q", ErrCreateFailed, auction.Slug)
+		}
+	}
+
+	auction.ID = len(s.auctions) + 1
+	auction.Currency = cmp.Or(auction.Currency, DefaultCurrency)
+	if auction.Created.IsZero() {
+		auction.Created = time.Now().UTC().Truncate(time.Second)
+	}
+	s.auctions[auction.ID] = auction
+
+	return int64(auction.ID), nil
+}
+
+func (s *MemStore) GetConfigItem(name string) (ConfigItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, ok := s.config[name]
+	if !ok {
+		return ConfigItem{}, fmt.Errorf("name %q: %w", name, ErrNotFound)
+	}
+
+	return config, nil
+}
+
+// GetAuction returns the auction identified by slug.
+func (s *MemStore) GetAuction(slug string) (Auction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, auction := range s.auctions {
+		if auction.Slug == slug {
+			return auction, nil
+		}
+	}
+
+	return Auction{}, fmt.Errorf("auction %q: %w", slug, ErrNotFound)
+}
+
+// GetAuctionByID returns the auction identified by id.
+func (s *MemStore) GetAuctionByID(id int) (Auction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auction, ok := s.auctions[id]
+	if !ok {
+		return Auction{}, fmt.Errorf("auction %d: %w", id, ErrNotFound)
+	}
+
+	return auction, nil
+}
+
+// GetAuctions returns all auctions, most recent first.
+func (s *MemStore) GetAuctions() ([]Auction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auctions := slices.Collect(maps.Values(s.auctions))
+	slices.SortFunc(auctions, func(a, b Auction) int {
+		return cmp.Or(b.AuctionStart.Compare(a.AuctionStart), cmp.Compare(a.ID, b.ID))
+	})
+
+	return auctions, nil
+}
+
+// SetAuctionTimes sets the start and end of auction id.
+func (s *MemStore) SetAuctionTimes(id int, startsAt, endsAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auction, ok := s.auctions[id]
+	if !ok {
+		return fmt.Errorf("auction %d: %w", id, ErrNotFound)
+	}
+
+	auction.AuctionStart = startsAt
+	auction.AuctionEnd = endsAt
+	s.auctions[id] = auction
+
+	return nil
+}
+
+// SetCurrentAuction sets the current_auction config item to slug, which
+// must name an auction.
+func (s *MemStore) SetCurrentAuction(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, auction := range s.auctions {
+		if auction.Slug == slug {
+			s.config["current_auction"] = ConfigItem{Name: "current_auction", Value: slug, ValueType: "string"}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("auction %q: %w", slug, ErrNotFound)
+}
+
+// GetIncrements returns the increments of the increment schedule named
+// name, ordered by the amount each applies from.
+func (s *MemStore) GetIncrements(name string) ([]Increment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.increments[name]), nil
+}
+
+// GetIncrementSchedules returns the increments of all increment schedules
+// by name.
+func (s *MemStore) GetIncrementSchedules() (map[string][]Increment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make(map[string][]Increment)
+	for name, increments := range s.increments {
+		schedules[name] = slices.Clone(increments)
+	}
+
+	return schedules, nil
+}
+
+func (s *MemStore) GetItem(id int) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.item(id)
+	if !ok {
+		return item, fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+
+	item.Images = slices.Clone(s.images[id])
+	if len(item.Images) == 0 && item.ImageFileName != "" {
+		item.Images = []string{item.ImageFileName}
+	}
+
+	return item, nil
+}
+
+// GetItems returns the items in auction auctionID.
+func (s *MemStore) GetItems(auctionID int) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []Item
+	for _, id := range s.itemIDs(auctionID) {
+		item, _ := s.item(id)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetItemsWithBids returns the items in auction auctionID that have bids.
+func (s *MemStore) GetItemsWithBids(auctionID int) ([]ItemWithBids, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []ItemWithBids
+	for _, id := range s.itemIDs(auctionID) {
+		bids := s.userBids(id)
+		if len(bids) == 0 {
+			continue
+		}
+
+		item := s.items[id]
+		items = append(items, ItemWithBids{
+			ID:            item.ID,
+			Title:         item.Title,
+			Created:       item.Created,
+			Description:   item.Description,
+			OpeningBid:    item.OpeningBid,
+			MinBidIncr:    item.MinBidIncr,
+			Artist:        item.Artist,
+			ImageFileName: item.ImageFileName,
+			Bids:          bids,
+		})
+	}
+
+	return items, nil
+}
+
+func (s *MemStore) CreateItem(item Item) (int64, error) {
+	if !validNewItem(item) {
+		return 0, ErrInvalidItem
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item.ID = 1
+	for id := range s.items {
+		item.ID = max(item.ID, id+1)
+	}
+	item.Created = time.Now().UTC().Truncate(time.Second)
+	item.ExtendedTo = nil
+	item.SoldAt = nil
+	s.items[item.ID] = storedItem(item)
+
+	return int64(item.ID), nil
+}
+
+func (s *MemStore) UpdateItem(item Item) (int64, error) {
+	// require non-empty strings for some fields
+	if AnyEmpty(
+		item.Title,
+		item.Description,
+		item.Artist,
+	) {
+		return 0, ErrInvalidItem
+	}
+
+	if !item.Valid() {
+		return 0, ErrInvalidItem
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prior, ok := s.items[item.ID]
+	if !ok {
+		return 0, nil
+	}
+
+	// the time created and the times set by bids are not updated
+	item.Created = prior.Created
+	item.ExtendedTo = prior.ExtendedTo
+	item.SoldAt = prior.SoldAt
+	s.items[item.ID] = storedItem(item)
+
+	return 1, nil
+}
+
+// GetImageFileNames returns the names of the images used by any item.
+func (s *MemStore) GetImageFileNames() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for id, item := range s.items {
+		names = append(names, item.ImageFileName)
+		names = append(names, s.images[id]...)
+	}
+	slices.Sort(names)
+
+	return slices.Compact(names), nil
+}
+
+// SetItemImages replaces the images of item id with images, in order, and
+// sets its ImageFileName to the first image.
+func (s *MemStore) SetItemImages(id int, images []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+
+	item.ImageFileName = ""
+	if len(images) > 0 {
+		item.ImageFileName = images[0]
+	}
+	s.items[id] = item
+	s.images[id] = slices.Clone(images)
+
+	return nil
+}
+
+// GetImageVariants returns the variants of the images named fileNames, by
+// image name, in order of width.
+func (s *MemStore) GetImageVariants(fileNames []string) (map[string][]ImageVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	variants := make(map[string][]ImageVariant)
+	for _, fileName := range fileNames {
+		if v, ok := s.variants[fileName]; ok {
+			variants[fileName] = slices.Clone(v)
+		}
+	}
+
+	return variants, nil
+}
+
+// SetImageVariants replaces the variants of the image named fileName with
+// variants.
+func (s *MemStore) SetImageVariants(fileName string, variants []ImageVariant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(variants) == 0 {
+		delete(s.variants, fileName)
+		return nil
+	}
+
+	v := slices.Clone(variants)
+	slices.SortStableFunc(v, func(a, b ImageVariant) int {
+		return cmp.Or(cmp.Compare(a.Width, b.Width), strings.Compare(a.Type, b.Type))
+	})
+	s.variants[fileName] = v
+
+	return nil
+}
+
+// GetImageStatuses returns the status of processing the images named
+// fileNames, by image name.
+func (s *MemStore) GetImageStatuses(fileNames []string) (map[string]ImageStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make(map[string]ImageStatus)
+	for _, fileName := range fileNames {
+		if status, ok := s.statuses[fileName]; ok {
+			statuses[fileName] = status
+		}
+	}
+
+	return statuses, nil
+}
+
+// GetPendingImages returns the names of the images that are waiting to be
+// processed.
+func (s *MemStore) GetPendingImages() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for fileName, status := range s.statuses {
+		if status.State == ImagePending {
+			names = append(names, fileName)
+		}
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// SetImageStatus sets the status of processing the image named fileName.
+// A status without a State removes it.
+func (s *MemStore) SetImageStatus(fileName string, status ImageStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status.State == "" {
+		delete(s.statuses, fileName)
+		return nil
+	}
+	s.statuses[fileName] = status
+
+	return nil
+}
+
+// PlaceBid places a bid of up to bidAmount for userName on item id. Bids
+// are placed automatically on behalf of the bidder up to bidAmount.
+func (s *MemStore) PlaceBid(id int, bidAmount Money, userName string) (BidResult, error) {
+	return s.PlaceUnitsBid(id, bidAmount, 1, userName)
+}
+
+// PlaceUnitsBid places a bid of bidAmount for each of units units of item
+// id for userName, following the rules of the placeBid procedure.
+func (s *MemStore) PlaceUnitsBid(id int, bidAmount Money, units int, userName string) (BidResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return placeBid(memTx{s}, s.now(), id, bidAmount, units, userName)
+}
+
+// BuyNow buys item id for userName at its buy now price, following the
+// rules of the buyNow procedure.
+func (s *MemStore) BuyNow(id int, userName string) (BidResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return buyNow(memTx{s}, s.now(), id, userName)
+}
+
+// Pledge pledges amount to pledge item id for userName, following the
+// rules of the placePledge procedure.
+func (s *MemStore) Pledge(id int, amount Money, userName string) (PledgeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return placePledge(memTx{s}, s.now(), id, amount, userName)
+}
+
+// GetBids returns the bids of known users on all items.
+func (s *MemStore) GetBids() ([]Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bids []Bid
+	for _, id := range s.itemIDs(0) {
+		bids = append(bids, s.userBids(id)...)
+	}
+
+	return bids, nil
+}
+
+func (s *MemStore) GetBidsForItem(id int) ([]Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bids []Bid
+	for _, bid := range s.itemBids(id) {
+		bids = append(bids, Bid{
+			ID:      bid.ItemID,
+			Created: bid.Created,
+			Bidder:  bid.Bidder,
+			Amount:  bid.Amount,
+		})
+	}
+
+	return bids, nil
+}
+
+// GetMaxBid returns the maximum bid of bidder for item id, or zero if the
+// bidder has not placed a bid on the item.
+func (s *MemStore) GetMaxBid(id int, bidder string) (Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.maxBids[memMaxBidKey{id, bidder}], nil
+}
+
+// GetWinningBids returns the winning bids for item id, highest first.
+func (s *MemStore) GetWinningBids(id int) ([]WinningBid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bids []WinningBid
+	for _, bid := range s.winningBids(s.items[id]) {
+		bids = append(bids, WinningBid{
+			Created: bid.Created,
+			Bidder:  bid.Bidder,
+			Amount:  bid.Amount,
+			Units:   bid.Units,
+		})
+	}
+
+	return bids, nil
+}
+
+// GetWinners returns the current winners in auction auctionID, including
+// items with a high bid below the reserve price, which are not sold. There
+// is one winner for each unit won of an item and for each pledge.
+func (s *MemStore) GetWinners(auctionID int) ([]Winner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var winners []Winner
+	for _, id := range s.itemIDs(auctionID) {
+		item := s.items[id]
+		winner := Winner{
+			ID:       item.ID,
+			Title:    item.Title,
+			Artist:   item.Artist,
+			Quantity: item.Quantity,
+		}
+
+		for _, bid := range s.winningBids(item) {
+			if bid.Amount.IsZero() {
+				continue
+			}
+
+			winner.CurrentBid = bid.Amount
+			winner.Modified = bid.Created
+			winner.ModifiedBy = bid.Bidder
+			winner.FullName, winner.Email = s.user(bid.Bidder)
+			winner.ReserveMet = bid.Amount.Cmp(item.ReservePrice) >= 0
+			winner.BuyNow = bid.BuyNow
+
+			winners = appendWinner(winners, winner, bid.Units)
+		}
+
+		var pledges []memPledge
+		for _, pledge := range s.pledges {
+			if pledge.ItemID == id {
+				pledges = append(pledges, pledge)
+			}
+		}
+		slices.SortStableFunc(pledges, func(a, b memPledge) int {
+			return cmp.Or(b.Amount.Cmp(a.Amount), a.Created.Compare(b.Created))
+		})
+
+		for _, pledge := range pledges {
+			winner.CurrentBid = pledge.Amount
+			winner.Modified = pledge.Created
+			winner.ModifiedBy = pledge.Pledger
+			winner.FullName, winner.Email = s.user(pledge.Pledger)
+			winner.ReserveMet = true
+			winner.BuyNow = false
+			winner.Pledge = true
+
+			winners = appendWinner(winners, winner, 1)
+		}
+	}
+
+	return winners, nil
+}
+
+// storedItem returns item as it is stored, without its bids, pledges, or
+// increment schedule, and without sharing memory with the caller.
+func storedItem(item Item) Item {
+	stored := Item{
+		ID:            item.ID,
+		AuctionID:     item.AuctionID,
+		ItemType:      item.ItemType,
+		Title:         item.Title,
+		Created:       item.Created,
+		Description:   item.Description,
+		OpeningBid:    item.OpeningBid,
+		MinBidIncr:    item.MinBidIncr,
+		IncrSchedule:  item.IncrSchedule,
+		Quantity:      item.Quantity,
+		ReservePrice:  item.ReservePrice,
+		BuyNowPrice:   item.BuyNowPrice,
+		Artist:        item.Artist,
+		ImageFileName: item.ImageFileName,
+		OpensAt:       cloneTime(item.OpensAt),
+		ClosesAt:      cloneTime(item.ClosesAt),
+		ExtendedTo:    cloneTime(item.ExtendedTo),
+		SoldAt:        cloneTime(item.SoldAt),
+	}
+	if len(item.PledgeLevels) > 0 {
+		stored.PledgeLevels = slices.Clone(item.PledgeLevels)
+	}
+	return stored
+}
+
+// cloneTime returns a copy of t.
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// now returns the time of a new bid or pledge, which is after those
+// already placed so that they stay in order. This leaves room for a bid
+// one microsecond later, as placeBid uses for automatic bids.
+//
+// s.mu must be held.
+func (s *MemStore) now() time.Time {
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	if !t.After(s.lastTime) {
+		t = s.lastTime.Add(time.Microsecond)
+	}
+	s.lastTime = t.Add(time.Microsecond)
+	return t
+}
+
+// item returns item id with its current bid, pledges, increment schedule,
+// and minimum bid as they are returned by the database. s.mu must be held.
+func (s *MemStore) item(id int) (Item, bool) {
+	stored, ok := s.items[id]
+	if !ok {
+		return Item{}, false
+	}
+	item := storedItem(stored)
+
+	schedule := item.IncrSchedule
+	if schedule == "" {
+		schedule = s.auctions[item.AuctionID].IncrSchedule
+	}
+	if schedule != "" {
+		item.Increments = slices.Clone(s.increments[schedule])
+	}
+
+	// the current bid is the lowest winning bid, and the latest of
+	// those if tied
+	winning := s.winningBids(item)
+	if n := len(winning); n > 0 {
+		current := winning[n-1]
+		item.Modified = cloneTime(&current.Created)
+		item.Bidder = current.Bidder
+		item.CurrentBid = current.Amount
+		for _, bid := range winning {
+			item.UnitsBid += bid.Units
+		}
+	}
+
+	item.PledgeTotal, item.Pledges = s.pledgeTotal(id)
+
+	if item.CurrentBid.IsZero() || item.UnitsBid < item.Quantity {
+		item.MinBid = item.OpeningBid
+	} else {
+		item.MinBid = item.CurrentBid.Add(item.BidIncrement(item.CurrentBid))
+	}
+
+	return item, true
+}
+
+// itemIDs returns the IDs of the items in auction auctionID, or of all
+// items if auctionID is zero, in order. s.mu must be held.
+func (s *MemStore) itemIDs(auctionID int) []int {
+	var ids []int
+	for _, id := range slices.Sorted(maps.Keys(s.items)) {
+		if auctionID == 0 || s.items[id].AuctionID == auctionID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// itemBids returns the bids on item id, latest first. s.mu must be held.
+func (s *MemStore) itemBids(id int) []memBid {
+	var bids []memBid
+	for _, bid := range s.bids {
+		if bid.ItemID == id {
+			bids = append(bids, bid)
+		}
+	}
+	slices.SortStableFunc(bids, func(a, b memBid) int {
+		return b.Created.Compare(a.Created)
+	})
+	return bids
+}
+
+// userBids returns the bids of known users on item id, latest first, as
+// the database joins bids with users. s.mu must be held.
+func (s *MemStore) userBids(id int) []Bid {
+	var bids []Bid
+	for _, bid := range s.itemBids(id) {
+		user, ok := s.users[bid.Bidder]
+		if !ok {
+			continue
+		}
+
+		bids = append(bids, Bid{
+			ID:       bid.ItemID,
+			Created:  bid.Created,
+			Bidder:   bid.Bidder,
+			Amount:   bid.Amount,
+			FullName: user.FullName,
+			Email:    user.Email,
+		})
+	}
+	return bids
+}
+
+// standingBids returns the latest bid of each bidder for item id, highest
+// first and earliest first if tied, as the standing_bids view. s.mu must
+// be held.
+func (s *MemStore) standingBids(id int) []memBid {
+	latest := make(map[string]memBid)
+	for _, bid := range s.bids {
+		if bid.ItemID != id {
+			continue
+		}
+		if prior, ok := latest[bid.Bidder]; !ok || bid.Created.After(prior.Created) {
+			latest[bid.Bidder] = bid
+		}
+	}
+
+	bids := slices.Collect(maps.Values(latest))
+	slices.SortFunc(bids, func(a, b memBid) int {
+		return cmp.Or(b.Amount.Cmp(a.Amount), a.Created.Compare(b.Created))
+	})
+	return bids
+}
+
+// standingAmount returns the amount of the standing bid of bidder for item
+// id, or zero if none. s.mu must be held.
+func (s *MemStore) standingAmount(id int, bidder string) Money {
+	for _, bid := range s.standingBids(id) {
+		if bid.Bidder == bidder {
+			return bid.Amount
+		}
+	}
+	return Money{}
+}
+
+// winningBids returns the standing bids that win at least one unit of item
+// and the units each wins, as the winning_bids view. s.mu must be held.
+func (s *MemStore) winningBids(item Item) []memBid {
+	var winning []memBid
+	unitsBefore := 0
+	for _, bid := range s.standingBids(item.ID) {
+		if unitsBefore >= item.Quantity {
+			break
+		}
+		units := bid.Units
+		bid.Units = min(units, item.Quantity-unitsBefore)
+		winning = append(winning, bid)
+		unitsBefore += units
+	}
+	return winning
+}
+
+// pledgeTotal returns the total and number of pledges for item id. s.mu
+// must be held.
+func (s *MemStore) pledgeTotal(id int) (Money, int) {
+	var total Money
+	var pledges int
+	for _, pledge := range s.pledges {
+		if pledge.ItemID == id {
+			total = total.Add(pledge.Amount)
+			pledges++
+		}
+	}
+	return total, pledges
+}
+
+// user returns the full name and email of userName, or "<missing>" for an
+// unknown user. s.mu must be held.
+func (s *MemStore) user(userName string) (fullName, email string) {
+	user, ok := s.users[userName]
+	if !ok {
+		return "<missing>", "<missing>"
+	}
+	return user.FullName, user.Email
+}
+
+// memTx is the bidTx of a MemStore, which must be locked while it is used.
+type memTx struct {
+	s *MemStore
+}
+
+func (tx memTx) item(id int) (Item, Auction, error) {
+	item, ok := tx.s.item(id)
+	if !ok {
+		return item, Auction{}, fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+	return item, tx.s.auctions[item.AuctionID], nil
+}
+
+func (tx memTx) maxBid(id int, bidder string) (Money, error) {
+	return tx.s.maxBids[memMaxBidKey{id, bidder}], nil
+}
+
+func (tx memTx) standingAmount(id int, bidder string) (Money, error) {
+	return tx.s.standingAmount(id, bidder), nil
+}
+
+func (tx memTx) winningBids(id int) ([]WinningBid, error) {
+	var bids []WinningBid
+	for _, bid := range tx.s.winningBids(tx.s.items[id]) {
+		bids = append(bids, WinningBid{
+			Created: bid.Created,
+			Bidder:  bid.Bidder,
+			Amount:  bid.Amount,
+			Units:   bid.Units,
+		})
+	}
+	return bids, nil
+}
+
+func (tx memTx) setMaxBid(id int, bidder string, amount Money) error {
+	tx.s.maxBids[memMaxBidKey{id, bidder}] = amount
+	return nil
+}
+
+func (tx memTx) addBid(id int, created time.Time, bidder string, amount Money, units int, buyNow bool) error {
+	tx.s.bids = append(tx.s.bids, memBid{
+		ItemID:  id,
+		Created: created,
+		Bidder:  bidder,
+		Amount:  amount,
+		Units:   units,
+		BuyNow:  buyNow,
+	})
+	return nil
+}
+
+func (tx memTx) extendItem(id int, extendedTo time.Time) error {
+	stored := tx.s.items[id]
+	stored.ExtendedTo = &extendedTo
+	tx.s.items[id] = stored
+	return nil
+}
+
+func (tx memTx) sellItem(id int, soldAt time.Time) error {
+	stored := tx.s.items[id]
+	stored.SoldAt = &soldAt
+	tx.s.items[id] = stored
+	return nil
+}
+
+func (tx memTx) addPledge(id int, created time.Time, pledger string, amount Money) error {
+	tx.s.pledges = append(tx.s.pledges, memPledge{
+		ItemID:  id,
+		Created: created,
+		Pledger: pledger,
+		Amount:  amount,
+	})
+	return nil
+}
+
+func (tx memTx) pledgeTotal(id int) (Money, error) {
+	total, _ := tx.s.pledgeTotal(id)
+	return total, nil
+}
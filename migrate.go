@@ -0,0 +1,310 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// migrationFiles has the migrations for each database driver, which are
+// named sql/migrations/<driver>/<version>_<name>.up.sql and .down.sql.
+//
+//go:embed sql/migrations
+var migrationFiles embed.FS
+
+// Migration is a numbered change to the schema of the database.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // SQL to apply the migration
+	Down    string // SQL to revert the migration
+}
+
+var (
+	ErrSchemaBehind   = errors.New("database schema is behind")
+	ErrSchemaAhead    = errors.New("database schema is ahead")
+	ErrNoMigration    = errors.New("no migration to revert")
+	ErrMigrationFile  = errors.New("invalid migration file")
+	ErrMigrateCommand = errors.New("unknown migrate command")
+)
+
+var migrationFileRE = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migrations returns the migrations for driverName in order of version.
+func Migrations(driverName string) ([]Migration, error) {
+	dir := path.Join("sql/migrations", driverName)
+
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, driverName)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileRE.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationFile, entry.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationFile, entry.Name())
+		}
+
+		script, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: %s has two names", ErrMigrationFile, match[1])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing version %d", ErrMigrationFile, version)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: %d needs up and down", ErrMigrationFile, version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	return migrations, nil
+}
+
+// AppliedMigrations returns the versions of the migrations applied to db,
+// in order, creating the schema_migrations table if it is missing.
+func AppliedMigrations(db *sql.DB) ([]int, error) {
+	create := "CREATE TABLE IF NOT EXISTS schema_migrations (version integer NOT NULL PRIMARY KEY, name varchar(255) NOT NULL, appliedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+
+	_, err := db.Exec(create)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// SchemaVersion returns the version of the latest migration applied to db,
+// or zero if none are applied.
+func SchemaVersion(db *sql.DB) (int, error) {
+	versions, err := AppliedMigrations(db)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// CheckSchema returns an error if the schema of db is not at the latest
+// migration for driverName, wrapping ErrSchemaBehind if migrations must
+// be applied.
+func CheckSchema(db *sql.DB, driverName string) error {
+	migrations, err := Migrations(driverName)
+	if err != nil {
+		return err
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := len(migrations)
+	switch {
+	case version < latest:
+		return fmt.Errorf("%w: version %d, want %d", ErrSchemaBehind, version, latest)
+	case version > latest:
+		return fmt.Errorf("%w: version %d, want %d", ErrSchemaAhead, version, latest)
+	}
+
+	return nil
+}
+
+// MigrateUp applies the migrations for driverName that are not applied to
+// db, returning those that were applied. A database created before
+// migrations already has the tables of the first migration, which leaves
+// them as they are, so the migrations that follow upgrade it.
+func MigrateUp(db *sql.DB, driverName string) ([]Migration, error) {
+	migrations, err := Migrations(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrSchemaAhead, version, len(migrations))
+	}
+
+	var applied []Migration
+	for _, m := range migrations[version:] {
+		err = runMigration(db, m.Up, "INSERT INTO schema_migrations(version, name) VALUES (?, ?)", m.Version, m.Name)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the latest migration applied to db, returning it.
+func MigrateDown(db *sql.DB, driverName string) (Migration, error) {
+	migrations, err := Migrations(driverName)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return Migration{}, err
+	}
+	if version == 0 {
+		return Migration{}, ErrNoMigration
+	}
+	if version > len(migrations) {
+		return Migration{}, fmt.Errorf("%w: version %d, want %d", ErrSchemaAhead, version, len(migrations))
+	}
+
+	m := migrations[version-1]
+
+	err = runMigration(db, m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	if err != nil {
+		return m, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+	}
+
+	return m, nil
+}
+
+// runMigration runs script and then record with args in a transaction.
+// MySQL commits each change to the schema itself, so a failed migration
+// may be partly applied.
+func runMigration(db *sql.DB, script, record string, args ...any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(record, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrateDataSourceName returns dataSourceName for driverName with the
+// parameters needed to run migrations. MySQL must allow more than one
+// statement in a query, which is not needed otherwise.
+func MigrateDataSourceName(driverName, dataSourceName string) (string, error) {
+	switch driverName {
+	case DriverMySQL:
+		cfg, err := mysql.ParseDSN(dataSourceName)
+		if err != nil {
+			return "", err
+		}
+		cfg.MultiStatements = true
+		return cfg.FormatDSN(), nil
+	case DriverSQLite:
+		return SQLiteDataSourceName(dataSourceName), nil
+	}
+
+	return dataSourceName, nil
+}
+
+// Migrate runs the migrate command for action, which is up, down, or
+// status, on db and writes the result to w.
+func Migrate(w io.Writer, db *sql.DB, driverName, action string) error {
+	switch action {
+	case "up":
+		applied, err := MigrateUp(db, driverName)
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(w, "schema is up to date")
+		}
+		return err
+
+	case "down":
+		m, err := MigrateDown(db, driverName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "reverted %d %s\n", m.Version, m.Name)
+		return nil
+
+	case "status":
+		migrations, err := Migrations(driverName)
+		if err != nil {
+			return err
+		}
+
+		versions, err := AppliedMigrations(db)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			state := "pending"
+			if slices.Contains(versions, m.Version) {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%d %s %s\n", m.Version, m.Name, state)
+		}
+		return CheckSchema(db, driverName)
+	}
+
+	return fmt.Errorf("%w: %q", ErrMigrateCommand, action)
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnixon67/webapp/webauth"
+)
+
+func TestMigrations(t *testing.T) {
+	for _, driverName := range []string{DriverMySQL, DriverSQLite, DriverPostgres} {
+		migrations, err := Migrations(driverName)
+		if err != nil || len(migrations) == 0 {
+			t.Errorf("Migrations(%q) got %d err '%v'", driverName, len(migrations), err)
+		}
+
+		for i, m := range migrations {
+			if m.Version != i+1 || m.Up == "" || m.Down == "" {
+				t.Errorf("Migrations(%q)[%d] got version %d name %q", driverName, i, m.Version, m.Name)
+			}
+		}
+	}
+
+	_, err := Migrations("nosuchdriver")
+	if !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("Migrations(%q) got err '%v' want '%v'", "nosuchdriver", err, ErrUnknownDriver)
+	}
+}
+
+func TestMigrateDataSourceName(t *testing.T) {
+	cases := []struct {
+		driverName string
+		in         string
+		want       string
+	}{
+		{DriverMySQL, "gobid:password@/gobid?parseTime=true", "gobid:password@tcp(127.0.0.1:3306)/gobid?multiStatements=true&parseTime=true"},
+		{DriverSQLite, "gobid.db", "gobid.db?_time_format=sqlite"},
+		{DriverPostgres, "postgres://gobid@localhost/gobid", "postgres://gobid@localhost/gobid"},
+	}
+
+	for _, tc := range cases {
+		got, err := MigrateDataSourceName(tc.driverName, tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("MigrateDataSourceName(%q, %q) = %q, %v, want %q", tc.driverName, tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dataSourceName := SQLiteDataSourceName(filepath.Join(t.TempDir(), "gobid.db"))
+
+	sqlDB, err := webauth.InitDB(DriverSQLite, dataSourceName)
+	if err != nil {
+		t.Fatalf("cannot init db: %v", err)
+	}
+	defer sqlDB.Close()
+
+	migrations, err := Migrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("Migrations() failed: %v", err)
+	}
+	latest := migrations[len(migrations)-1]
+
+	err = CheckSchema(sqlDB.DB, DriverSQLite)
+	if !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("CheckSchema() before up got err '%v' want '%v'", err, ErrSchemaBehind)
+	}
+
+	cases := []struct {
+		action  string
+		want    string
+		wantErr error
+		version int
+	}{
+		{"status", "1 init pending", ErrSchemaBehind, 0},
+		{"up", "applied 1 init", nil, len(migrations)},
+		{"up", "schema is up to date", nil, len(migrations)},
+		{"status", "1 init applied", nil, len(migrations)},
+		{"down", fmt.Sprintf("reverted %d %s", latest.Version, latest.Name), nil, latest.Version - 1},
+		{"sideways", "", ErrMigrateCommand, latest.Version - 1},
+	}
+
+	for _, tc := range cases {
+		var out strings.Builder
+
+		err := Migrate(&out, sqlDB.DB, DriverSQLite, tc.action)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("Migrate(%q) got err '%v' want '%v'", tc.action, err, tc.wantErr)
+		}
+		if !strings.Contains(out.String(), tc.want) {
+			t.Errorf("Migrate(%q) got %q want %q", tc.action, out.String(), tc.want)
+		}
+
+		version, err := SchemaVersion(sqlDB.DB)
+		if err != nil || version != tc.version {
+			t.Errorf("SchemaVersion() after %q got %d err '%v' want %d", tc.action, version, err, tc.version)
+		}
+	}
+
+	for range latest.Version - 1 {
+		_, err = MigrateDown(sqlDB.DB, DriverSQLite)
+		if err != nil {
+			t.Fatalf("MigrateDown() failed: %v", err)
+		}
+	}
+
+	_, err = sqlDB.Exec("SELECT 1 FROM items")
+	if err == nil {
+		t.Errorf("items exists after down")
+	}
+
+	_, err = MigrateDown(sqlDB.DB, DriverSQLite)
+	if !errors.Is(err, ErrNoMigration) {
+		t.Errorf("MigrateDown() with none applied got err '%v' want '%v'", err, ErrNoMigration)
+	}
+}
+
+func TestMigrateUpBaseline(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      map[string]string
+		item        bool
+		wantAuction bool
+		wantStart   time.Time
+		wantEnd     time.Time
+	}{
+		{
+			name: "config and item",
+			config: map[string]string{
+				"auction_start": "2023-05-01 18:00:00 CDT",
+				"auction_end":   "2023-12-01 18:00:00 CST",
+			},
+			item:        true,
+			wantAuction: true,
+			wantStart:   time.Date(2023, 5, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:     time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "config",
+			config: map[string]string{
+				"auction_start": "2023-05-01 18:00:00 UTC",
+				"auction_end":   "2023-05-02 18:00:00 UTC",
+			},
+			wantAuction: true,
+			wantStart:   time.Date(2023, 5, 1, 18, 0, 0, 0, time.UTC),
+			wantEnd:     time.Date(2023, 5, 2, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	migrations, err := Migrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("Migrations() failed: %v", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dataSourceName := SQLiteDataSourceName(filepath.Join(t.TempDir(), "gobid.db"))
+
+			sqlDB, err := webauth.InitDB(DriverSQLite, dataSourceName)
+			if err != nil {
+				t.Fatalf("cannot init db: %v", err)
+			}
+			defer sqlDB.Close()
+
+			// tables as created before migrations
+			_, err = sqlDB.Exec(migrations[0].Up)
+			if err != nil {
+				t.Fatalf("cannot create baseline: %v", err)
+			}
+			for name, value := range tc.config {
+				_, err = sqlDB.Exec("INSERT INTO config(name, value, value_type) VALUES (?, ?, 'time')", name, value)
+				if err != nil {
+					t.Fatalf("cannot insert config: %v", err)
+				}
+			}
+			if tc.item {
+				_, err = sqlDB.Exec("INSERT INTO items(title, openingBid, minBidIncr, artist, imageFileName) VALUES ('Item', 10, 1, 'Artist', 'item.png')")
+				if err != nil {
+					t.Fatalf("cannot insert item: %v", err)
+				}
+			}
+
+			_, err = MigrateUp(sqlDB.DB, DriverSQLite)
+			if err != nil {
+				t.Fatalf("MigrateUp() failed: %v", err)
+			}
+
+			db, err := NewSQLiteDB(sqlDB)
+			if err != nil {
+				t.Fatalf("NewSQLiteDB() failed: %v", err)
+			}
+
+			app := &BidApp{BidDB: db}
+			err = app.ConfigAuction()
+			if !tc.wantAuction {
+				if err == nil {
+					t.Errorf("ConfigAuction() without auction got nil error")
+				}
+				return
+			}
+			if err != nil || app.DefaultAuction != "default" {
+				t.Fatalf("ConfigAuction() got %q err '%v' want %q", app.DefaultAuction, err, "default")
+			}
+
+			auction, err := db.GetAuction("default")
+			if err != nil {
+				t.Fatalf("GetAuction() failed: %v", err)
+			}
+			if !auction.AuctionStart.Equal(tc.wantStart) || !auction.AuctionEnd.Equal(tc.wantEnd) {
+				t.Errorf("GetAuction() got %v to %v want %v to %v",
+					auction.AuctionStart, auction.AuctionEnd, tc.wantStart, tc.wantEnd)
+			}
+
+			if tc.item {
+				item, err := db.GetItem(1)
+				if err != nil || item.AuctionID != auction.ID {
+					t.Errorf("GetItem() got auction %d err '%v' want %d", item.AuctionID, err, auction.ID)
+				}
+			}
+		})
+	}
+}
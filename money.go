@@ -0,0 +1,277 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of an amount without one.
+const DefaultCurrency = "USD"
+
+// Money is an exact amount of money in cents of a currency. The zero value
+// is zero in DefaultCurrency.
+//
+// Amounts are stored in the database as decimal(13,2), which Money scans
+// and writes without converting to a float.
+type Money struct {
+	Cents    int64
+	Currency string // ISO 4217 code, or DefaultCurrency if empty
+}
+
+var (
+	ErrInvalidMoney     = errors.New("invalid amount")
+	ErrSubCent          = errors.New("amount has a fraction of a cent")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+)
+
+// Cents returns an amount of cents in DefaultCurrency.
+func Cents(cents int64) Money {
+	return Money{Cents: cents}
+}
+
+// ParseMoney returns the amount in s, which must be a non-negative decimal
+// with at most two decimal places, such as "15", "15.5", or "15.50".
+func ParseMoney(s string) (Money, error) {
+	cents, err := parseCents(strings.TrimSpace(s))
+	if err == nil && cents < 0 {
+		err = ErrInvalidMoney
+	}
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", err, s)
+	}
+
+	return Cents(cents), nil
+}
+
+// parseCents returns the number of cents in the decimal s, which may have a
+// leading minus sign. Trailing zeros after the cents are allowed, such as
+// in "1.500", but other digits are not.
+func parseCents(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") ||
+		!isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidMoney
+	}
+
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 2 {
+		return 0, ErrSubCent
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (math.MaxInt64-99)/100 {
+		return 0, ErrInvalidMoney
+	}
+	fraction, _ := strconv.ParseInt(frac, 10, 64)
+
+	cents := units*100 + fraction
+	if negative {
+		cents = -cents
+	}
+
+	return cents, nil
+}
+
+// isDigits returns true if s only contains the digits 0 through 9.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyCode returns the currency of m.
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// In returns m in currency, such as an amount from the database in the
+// currency of its auction.
+func (m Money) In(currency string) Money {
+	m.Currency = currency
+	return m
+}
+
+// currencyRE matches an ISO 4217 currency code.
+var currencyRE = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency returns true if code is an ISO 4217 currency code.
+func ValidCurrency(code string) bool {
+	return currencyRE.MatchString(code)
+}
+
+// String returns m as a decimal with two decimal places, such as "1000.00",
+// which is used for form values, CSV files, and the database.
+func (m Money) String() string {
+	cents := m.Cents
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// currencySymbols are the symbols used to display amounts. Other currencies
+// are displayed with their code.
+var currencySymbols = map[string]string{
+	"AUD": "$",
+	"CAD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"USD": "$",
+}
+
+// Display returns m as it is shown to users, such as "$1,000.00".
+func (m Money) Display() string {
+	units, cents, _ := strings.Cut(m.String(), ".")
+
+	sign := ""
+	if strings.HasPrefix(units, "-") {
+		sign = "-"
+		units = units[1:]
+	}
+
+	// group the digits in thousands
+	var b strings.Builder
+	for i, r := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+
+	return sign + CurrencySymbol(m.CurrencyCode()) + b.String() + "." + cents
+}
+
+// CurrencySymbol returns the symbol that amounts in the currency code are
+// displayed with, which is the code and a space if it has no symbol.
+func CurrencySymbol(code string) string {
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		symbol = code + " "
+	}
+	return symbol
+}
+
+// IsZero returns true if m is zero.
+func (m Money) IsZero() bool {
+	return m.Cents == 0
+}
+
+// Cmp returns -1, 0, or +1 if m is less than, equal to, or greater than o.
+// Cmp panics as Add if m and o are in different currencies.
+func (m Money) Cmp(o Money) int {
+	sameCurrency(m, o)
+	switch {
+	case m.Cents < o.Cents:
+		return -1
+	case m.Cents > o.Cents:
+		return 1
+	}
+	return 0
+}
+
+// Add returns m plus o. An amount without a currency, such as one parsed
+// from a form, takes the currency of the other. Add panics with
+// ErrCurrencyMismatch if m and o are in different currencies.
+func (m Money) Add(o Money) Money {
+	m.Currency = sameCurrency(m, o)
+	m.Cents += o.Cents
+	return m
+}
+
+// Sub returns m minus o, with the currency of either as for Add.
+func (m Money) Sub(o Money) Money {
+	m.Currency = sameCurrency(m, o)
+	m.Cents -= o.Cents
+	return m
+}
+
+// sameCurrency returns the currency of m and o, which is empty if neither
+// has one, and panics if they are in different currencies.
+func sameCurrency(m, o Money) string {
+	switch {
+	case m.Currency == "":
+		return o.Currency
+	case o.Currency == "" || o.Currency == m.Currency:
+		return m.Currency
+	}
+	panic(fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency))
+}
+
+// Mul returns m times n.
+func (m Money) Mul(n int64) Money {
+	m.Cents *= n
+	return m
+}
+
+// Scan implements sql.Scanner for a decimal column. NULL scans as zero.
+func (m *Money) Scan(src any) error {
+	var cents int64
+	var err error
+
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		cents, err = parseCents(string(v))
+	case string:
+		cents, err = parseCents(v)
+	case int64:
+		cents = v * 100
+	case float64:
+		cents = int64(math.Round(v * 100))
+	default:
+		err = ErrInvalidMoney
+	}
+	if err != nil {
+		return fmt.Errorf("scan %T %v: %w", src, src, err)
+	}
+
+	m.Cents = cents
+
+	return nil
+}
+
+// Value implements driver.Valuer, writing m as a decimal string so that it
+// is exact.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// MarshalJSON returns m as a JSON number with two decimal places.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON sets m from a JSON number or string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	cents, err := parseCents(strings.Trim(string(data), `"`))
+	if err != nil {
+		return fmt.Errorf("money %s: %w", data, err)
+	}
+
+	m.Cents = cents
+
+	return nil
+}
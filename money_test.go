@@ -0,0 +1,192 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		s    string
+		want Money
+		err  error
+	}{
+		{s: "0", want: Cents(0)},
+		{s: "15", want: Cents(1500)},
+		{s: "15.5", want: Cents(1550)},
+		{s: " 15.50 ", want: Cents(1550)},
+		{s: "0.01", want: Cents(1)},
+		{s: "1.500", want: Cents(150)},
+		{s: "0.1", want: Cents(10)},
+		{s: "1.005", err: ErrSubCent},
+		{s: "0.001", err: ErrSubCent},
+		{s: "", err: ErrInvalidMoney},
+		{s: ".5", err: ErrInvalidMoney},
+		{s: "5.", err: ErrInvalidMoney},
+		{s: "-5", err: ErrInvalidMoney},
+		{s: "+5", err: ErrInvalidMoney},
+		{s: "1e3", err: ErrInvalidMoney},
+		{s: "$5", err: ErrInvalidMoney},
+		{s: "1,000", err: ErrInvalidMoney},
+		{s: "NaN", err: ErrInvalidMoney},
+		{s: "99999999999999999999", err: ErrInvalidMoney},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseMoney(tc.s)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("ParseMoney(%q) got err '%v' want '%v'", tc.s, err, tc.err)
+		}
+		if got != tc.want {
+			t.Errorf("ParseMoney(%q) = %v want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	cases := []struct {
+		m       Money
+		str     string
+		display string
+	}{
+		{m: Money{}, str: "0.00", display: "$0.00"},
+		{m: Cents(5), str: "0.05", display: "$0.05"},
+		{m: Cents(1550), str: "15.50", display: "$15.50"},
+		{m: Cents(100000), str: "1000.00", display: "$1,000.00"},
+		{m: Cents(123456789), str: "1234567.89", display: "$1,234,567.89"},
+		{m: Cents(-100000), str: "-1000.00", display: "-$1,000.00"},
+		{m: Money{Cents: 250, Currency: "EUR"}, str: "2.50", display: "€2.50"},
+		{m: Money{Cents: 250, Currency: "CHF"}, str: "2.50", display: "CHF 2.50"},
+	}
+
+	for _, tc := range cases {
+		if got := tc.m.String(); got != tc.str {
+			t.Errorf("String() = %q want %q", got, tc.str)
+		}
+		if got := tc.m.Display(); got != tc.display {
+			t.Errorf("Display() = %q want %q", got, tc.display)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 0.1 + 0.2 is not 0.3 with float64
+	got := Cents(10).Add(Cents(20))
+	if got != Cents(30) {
+		t.Errorf("Add() = %v want 0.30", got)
+	}
+
+	if got := Cents(1000).Sub(Cents(1)); got != Cents(999) {
+		t.Errorf("Sub() = %v want 9.99", got)
+	}
+	if got := Cents(333).Mul(3); got != Cents(999) {
+		t.Errorf("Mul() = %v want 9.99", got)
+	}
+
+	if Cents(1).Cmp(Cents(2)) != -1 || Cents(2).Cmp(Cents(2)) != 0 || Cents(3).Cmp(Cents(2)) != 1 {
+		t.Errorf("Cmp() failed")
+	}
+	if !(Money{}).IsZero() || Cents(1).IsZero() {
+		t.Errorf("IsZero() failed")
+	}
+}
+
+func TestMoneyCurrency(t *testing.T) {
+	eur := Cents(250).In("EUR")
+
+	cases := []struct {
+		name string
+		got  Money
+		want Money
+	}{
+		{name: "In", got: eur, want: Money{Cents: 250, Currency: "EUR"}},
+		{name: "Add none", got: eur.Add(Cents(50)), want: Cents(300).In("EUR")},
+		{name: "Add to none", got: Cents(50).Add(eur), want: Cents(300).In("EUR")},
+		{name: "Sub same", got: eur.Sub(Cents(50).In("EUR")), want: Cents(200).In("EUR")},
+		{name: "none", got: Cents(1).Add(Cents(2)), want: Cents(3)},
+	}
+
+	for _, tc := range cases {
+		if tc.got != tc.want {
+			t.Errorf("%s = %#v want %#v", tc.name, tc.got, tc.want)
+		}
+	}
+
+	for name, f := range map[string]func(){
+		"Add": func() { eur.Add(Cents(1).In("USD")) },
+		"Sub": func() { eur.Sub(Cents(1).In("CHF")) },
+		"Cmp": func() { eur.Cmp(Cents(1).In("USD")) },
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ErrCurrencyMismatch) {
+					t.Errorf("%s() panic = %v want %v", name, err, ErrCurrencyMismatch)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestValidCurrency(t *testing.T) {
+	cases := map[string]bool{
+		"USD": true, "EUR": true, "usd": false, "US": false, "USDX": false, "": false,
+	}
+
+	for code, want := range cases {
+		if got := ValidCurrency(code); got != want {
+			t.Errorf("ValidCurrency(%q) = %t want %t", code, got, want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	cases := []struct {
+		src     any
+		want    Money
+		wantErr bool
+	}{
+		{src: nil, want: Money{}},
+		{src: []byte("15.50"), want: Cents(1550)},
+		{src: "0.10", want: Cents(10)},
+		{src: []byte("-2.00"), want: Cents(-200)},
+		{src: int64(3), want: Cents(300)},
+		{src: 0.29, want: Cents(29)},
+		{src: []byte("1.234"), wantErr: true},
+		{src: true, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		var got Money
+		err := got.Scan(tc.src)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Scan(%v) got err '%v' want err %t", tc.src, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("Scan(%v) = %v want %v", tc.src, got, tc.want)
+		}
+	}
+
+	value, err := Cents(1550).Value()
+	if err != nil || value != "15.50" {
+		t.Errorf("Value() = %v, %v want 15.50", value, err)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(struct{ Amount Money }{Cents(1550)})
+	if err != nil || string(data) != `{"Amount":15.50}` {
+		t.Errorf("Marshal() = %s, %v", data, err)
+	}
+
+	var got struct{ Amount Money }
+	err = json.Unmarshal(data, &got)
+	if err != nil || got.Amount != Cents(1550) {
+		t.Errorf("Unmarshal(%s) = %v, %v", data, got.Amount, err)
+	}
+}
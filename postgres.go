@@ -0,0 +1,180 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DriverPostgres is the database driver for PostgreSQL, which is the pgx
+// driver with queries rewritten by rebindQuery.
+const DriverPostgres = "postgres"
+
+func init() {
+	sql.Register(DriverPostgres, postgresDriver{})
+}
+
+// PostgresDB is a BidStore that uses PostgreSQL.
+//
+// It uses the queries of BidDB, which are portable once rewritten by the
+// driver. Bids, purchases, and pledges are placed in a transaction with
+// the same rules as the stored procedures for MySQL, which locks the item
+// with SELECT ... FOR UPDATE so that bids on it are placed one at a time.
+type PostgresDB struct {
+	txBidDB
+}
+
+// NewPostgresDB returns a PostgresDB for sqlDB, which must be opened with
+// DriverPostgres and has the schema from its migrations.
+func NewPostgresDB(sqlDB *webauth.AuthDB) (*PostgresDB, error) {
+	if sqlDB == nil {
+		return nil, ErrInvalidDB
+	}
+
+	return &PostgresDB{txBidDB{BidDB: BidDB{sqlDB: sqlDB}, lockItem: true}}, nil
+}
+
+// CreateItem creates item and returns its id. PostgreSQL does not return
+// the last insert id, so the id is returned by the insert itself.
+func (db PostgresDB) CreateItem(item Item) (int64, error) {
+	if db.sqlDB == nil {
+		return 0, ErrInvalidDB
+	}
+
+	if !validNewItem(item) {
+		return 0, ErrInvalidItem
+	}
+
+	var id int64
+
+	err := db.sqlDB.QueryRow(insertItem+" RETURNING id", insertItemArgs(item)...).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCreateFailed, err)
+	}
+
+	return id, nil
+}
+
+// CreateAuction creates auction and returns its id. PostgreSQL does not
+// return the last insert id, so the id is returned by the insert itself.
+func (db PostgresDB) CreateAuction(auction Auction) (int64, error) {
+	if db.sqlDB == nil {
+		return 0, ErrInvalidDB
+	}
+
+	var id int64
+
+	err := db.sqlDB.QueryRow(insertAuction+" RETURNING id", insertAuctionArgs(auction)...).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCreateFailed, err)
+	}
+
+	return id, nil
+}
+
+// postgresDriver opens connections of the pgx driver that rewrite each
+// query with rebindQuery, so the queries of webauth and BidDB written for
+// MySQL run unchanged.
+type postgresDriver struct{}
+
+func (postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := stdlib.GetDefaultDriver().Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pgxConn, ok := conn.(*stdlib.Conn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected connection type %T", conn)
+	}
+
+	return postgresConn{pgxConn}, nil
+}
+
+// postgresConn is a pgx connection that rewrites each query.
+type postgresConn struct {
+	*stdlib.Conn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebindQuery(query))
+}
+
+func (c postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebindQuery(query))
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, rebindQuery(query), args)
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebindQuery(query), args)
+}
+
+// rebindQuery rewrites query from MySQL to PostgreSQL, replacing each ?
+// placeholder with $1, $2, and so on, and each double-quoted string with a
+// single-quoted string. Single-quoted strings and comments are unchanged.
+func rebindQuery(query string) string {
+	var b strings.Builder
+	n := 0
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'':
+			// copy up to the closing quote, skipping escaped quotes
+			j := i + 1
+			for j < len(query) {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			j = min(j, len(query)-1)
+			b.WriteString(query[i : j+1])
+			i = j
+
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			b.WriteString(query[i : i+j])
+			i += j - 1
+
+		case c == '"':
+			j := strings.IndexByte(query[i+1:], '"')
+			if j < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			s := query[i+1 : i+1+j]
+			b.WriteString("'" + strings.ReplaceAll(s, "'", "''") + "'")
+			i += j + 1
+
+		case c == '?':
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
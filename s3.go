@@ -0,0 +1,291 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3ImageStore is an ImageStore in a bucket of S3, or of a server with an
+// S3-compatible API, so that several servers can share the images.
+type S3ImageStore struct {
+	Endpoint  string // URL of the server, such as https://s3.us-east-1.amazonaws.com
+	Region    string // region the requests are signed for
+	Bucket    string // bucket of the images
+	Prefix    string // prefix of the keys of the images, such as gobid/
+	AccessKey string // access key id of the credentials
+	SecretKey string // secret access key of the credentials
+
+	// BaseURL is the public URL of the images, which is followed by the
+	// image name. If empty, the images are served by this server.
+	BaseURL string
+
+	Client *http.Client // client for requests, http.DefaultClient if nil
+}
+
+// ErrImageStore is returned when a request to an image store fails.
+var ErrImageStore = errors.New("image store request failed")
+
+// Put stores the image in r as name, unless an object already has the
+// name.
+func (s *S3ImageStore) Put(name string, r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"If-None-Match": {"*"}}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(http.MethodPut, name, nil, header, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Get returns the image named name.
+func (s *S3ImageStore) Get(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, name, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Delete removes the image named name.
+func (s *S3ImageStore) Delete(name string) error {
+	resp, err := s.do(http.MethodDelete, name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// URL returns BaseURL followed by name, or the URL of the image on this
+// server if BaseURL is empty.
+func (s *S3ImageStore) URL(name string) string {
+	if s.BaseURL == "" {
+		return imageURLPrefix + name
+	}
+
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + name
+}
+
+// s3ListResult is the result of a ListObjectsV2 request.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns the images with keys under Prefix.
+func (s *S3ImageStore) List() ([]StoredImage, error) {
+	var images []StoredImage
+
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return images, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return images, fmt.Errorf("%w: list: %v", ErrImageStore, err)
+		}
+
+		for _, object := range result.Contents {
+			images = append(images, StoredImage{
+				Name:     strings.TrimPrefix(object.Key, s.Prefix),
+				Modified: object.LastModified,
+			})
+		}
+
+		if !result.IsTruncated {
+			return images, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// do sends a signed request for the image name, or for the bucket if name
+// is empty, and returns the response if it succeeds. An object that is
+// not found returns an error that wraps fs.ErrNotExist, and a Put of an
+// object that exists returns a response with an empty body.
+func (s *S3ImageStore) do(method, name string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	key := ""
+	if name != "" {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("%w: %q", ErrImageName, name)
+		}
+		key = s.Prefix + name
+	}
+
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageStoreConfig, err)
+	}
+	u.Path = path.Join("/", u.Path, s.Bucket) + "/" + key
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signS3Request(req, body, s.AccessKey, s.SecretKey, s.Region, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %v", ErrImageStore, method, key, err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp, nil
+	case method == http.MethodPut && resp.StatusCode == http.StatusPreconditionFailed:
+		// If-None-Match failed, so the image is already stored
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(nil))
+		return resp, nil
+	}
+
+	// error responses have an XML body with a code and message
+	var s3Error struct{ Code, Message string }
+	xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&s3Error)
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && s3Error.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, key)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s: %s %s %s", ErrImageStore, method, key, resp.Status, s3Error.Code, s3Error.Message)
+}
+
+// signS3Request signs req, with body, at t for S3 in region with AWS
+// Signature Version 4. The host and all other headers of req are signed.
+func signS3Request(req *http.Request, body []byte, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	scope := t.Format("20060102") + "/" + region + "/s3/aws4_request"
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes s as S3 requires, which leaves only the unreserved
+// characters of RFC 3986, and slashes if keepSlash is true.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath escapes the path p as S3 requires.
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3CanonicalQuery returns query escaped and sorted as S3 requires.
+func s3CanonicalQuery(query url.Values) string {
+	var params []string
+	for k, values := range query {
+		for _, v := range values {
+			params = append(params, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	slices.Sort(params)
+
+	return strings.Join(params, "&")
+}
@@ -1,6 +0,0 @@
-CREATE TABLE `config` (
-  `name` varchar(30) NOT NULL,
-  `value` varchar(255) NOT NULL,
-  `value_type` varchar(30) NOT NULL,
-  PRIMARY KEY (`name`)
-);
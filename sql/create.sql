@@ -1,8 +0,0 @@
-source bids.sql
-source config.sql
-source events.sql
-source items.sql
-source tokens.sql
-source users.sql
-source current_bids.sql
-source placeBid.sql
@@ -0,0 +1,133 @@
+-- 0001_init creates the tables, view, and stored procedure of gobid for
+-- MySQL or MariaDB as they were before migrations, so a database created
+-- from those files is left as it is and is upgraded by the migrations that
+-- follow.
+
+CREATE TABLE IF NOT EXISTS `bids` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `created` timestamp NOT NULL DEFAULT current_timestamp(),
+  `bidder` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL,
+  PRIMARY KEY (`id`,`created`)
+);
+
+CREATE TABLE IF NOT EXISTS `config` (
+  `name` varchar(30) NOT NULL,
+  `value` varchar(255) NOT NULL,
+  `value_type` varchar(30) NOT NULL,
+  PRIMARY KEY (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `events` (
+  `name` varchar(10) NOT NULL,
+  `succeeded` boolean NOT NULL,
+  `username` varchar(30) NOT NULL,
+  `message` varchar(255) NOT NULL DEFAULT "",
+  `created` timestamp(6) NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (`created`,`name`,`username`)
+);
+
+CREATE TABLE IF NOT EXISTS `items` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `title` varchar(40) NOT NULL,
+  `created` timestamp NOT NULL DEFAULT current_timestamp(),
+  `description` varchar(255) NOT NULL DEFAULT "",
+  `openingBid` decimal(13,2) NOT NULL,
+  `minBidIncr` decimal(13,2) NOT NULL,
+  `artist` varchar(30) NOT NULL,
+  `imageFileName` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `tokens` (
+  `hashedValue` binary(64) NOT NULL,
+  `expires` datetime NOT NULL,
+  `kind` varchar(7) NOT NULL,
+  `userName` varchar(30) NOT NULL,
+  `created` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`hashedValue`)
+);
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `username` varchar(30) NOT NULL,
+  `fullName` varchar(70) NOT NULL,
+  `email` varchar(256) NOT NULL,
+  `hashedPassword` binary(60) NOT NULL,
+  `admin` boolean NOT NULL DEFAULT false,
+  `confirmed` boolean NOT NULL DEFAULT false,
+  `created` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`username`),
+  UNIQUE KEY `email` (`email`)
+);
+
+CREATE OR REPLACE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount
+FROM bids a
+INNER JOIN (
+  SELECT id, MAX(amount) amount
+  FROM bids
+  GROUP BY id
+) b ON a.id = b.id AND a.amount = b.amount;
+
+-- placeBid will try and place a bid for an item. 
+CREATE OR REPLACE PROCEDURE placeBid(
+  bidId int(11),
+  newAmount decimal(13,2),
+  newBidder varchar(30)
+)
+MODIFIES SQL DATA
+BEGIN
+  DECLARE bidPlaced boolean DEFAULT false;
+  DECLARE minAmount decimal(13,2) DEFAULT NULL;
+  DECLARE openingBid decimal(13,2) DEFAULT 0;
+  DECLARE minBidIncr decimal(13,2) DEFAULT 0;
+  DECLARE curBidder varchar(30) DEFAULT "";
+  DECLARE curAmount decimal(13,2) DEFAULT 0;
+  DECLARE message varchar(30);
+
+  START TRANSACTION;
+
+  -- ensure item exists
+  SELECT COUNT(*) INTO @cnt FROM items WHERE id = bidId;
+  IF @cnt = 0 THEN
+    SET message = 'No such item';
+  ELSEIF @cnt > 1 THEN
+    SET message = 'Multiple rows';
+  ELSE
+    -- get current bid information
+    SELECT items.openingBid, items.minBidIncr,
+           current_bids.bidder, current_bids.amount
+    INTO openingBid, minBidIncr, curBidder, curAmount
+    FROM items LEFT OUTER JOIN current_bids ON items.id = current_bids.id
+    WHERE items.id = bidId
+    FOR UPDATE; -- lock tables within transaction
+
+    IF openingBid = 0 THEN
+      SET message = 'Display only item';
+    ELSE
+      SET minAmount = IF(ISNULL(curAmount),
+                         openingBid,
+                         curAmount+minBidIncr);
+
+      IF newAmount < minAmount THEN
+        SET message = 'Bid too low';
+      ELSE
+        INSERT INTO bids(id, bidder, amount)
+        VALUES(bidId, newBidder, newAmount);
+
+        SET @rows = row_count();
+        IF @rows != 1 THEN
+          SET message = 'No rows inserted';
+        ELSE 
+	  SET bidPlaced = true;
+          SET message = 'Bid placed';
+        END IF;
+      END IF;
+    END IF;
+  END IF;
+
+  SELECT bidPlaced, message, IFNULL(curBidder,"") AS priorBidder;
+
+  COMMIT;
+
+END;
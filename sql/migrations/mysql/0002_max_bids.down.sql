@@ -0,0 +1,5 @@
+-- 0002_max_bids drops the maximum bids and the microseconds of bid times.
+ALTER TABLE `bids`
+  MODIFY `created` timestamp NOT NULL DEFAULT current_timestamp();
+
+DROP TABLE IF EXISTS `max_bids`;
@@ -0,0 +1,13 @@
+-- 0002_max_bids adds the maximum bid of each bidder for each item, so bids
+-- are placed on their behalf, and stores the time of bids to the
+-- microsecond so that bids placed together keep their order.
+CREATE TABLE IF NOT EXISTS `max_bids` (
+  `id` int(11) NOT NULL,
+  `bidder` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL,
+  `created` timestamp(6) NOT NULL DEFAULT current_timestamp(6),
+  PRIMARY KEY (`id`,`bidder`)
+);
+
+ALTER TABLE `bids`
+  MODIFY `created` timestamp(6) NOT NULL DEFAULT current_timestamp(6);
@@ -0,0 +1,5 @@
+-- 0003_item_times drops the opening and closing times of items.
+ALTER TABLE `items`
+  DROP COLUMN `extendedTo`,
+  DROP COLUMN `closesAt`,
+  DROP COLUMN `opensAt`;
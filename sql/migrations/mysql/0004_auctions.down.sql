@@ -0,0 +1,9 @@
+-- 0004_auctions drops auctions and the current auction. The auction_start
+-- and auction_end config is kept, so the times of a single auction remain.
+DELETE FROM config WHERE name = 'current_auction';
+
+ALTER TABLE `items`
+  DROP KEY `auctionId`,
+  DROP COLUMN `auctionId`;
+
+DROP TABLE IF EXISTS `auctions`;
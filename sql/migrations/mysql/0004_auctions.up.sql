@@ -0,0 +1,51 @@
+-- 0004_auctions adds auctions, each with its own items, times, and soft
+-- close, and the current auction in config.
+--
+-- A database with the auction_start and auction_end config of a single
+-- auction, or with items, gets the auction "default" with those times,
+-- which own all existing items. The config times are in America/Chicago
+-- as written by gobid before auctions, such as "2023-05-01 18:00:00 CDT".
+CREATE TABLE IF NOT EXISTS `auctions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `slug` varchar(30) NOT NULL,
+  `name` varchar(70) NOT NULL,
+  `startsAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `endsAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `timeZone` varchar(64) NOT NULL DEFAULT "America/Chicago",
+  `softCloseWindow` int NOT NULL DEFAULT 0,
+  `softCloseExtension` int NOT NULL DEFAULT 0,
+  `created` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `slug` (`slug`)
+);
+
+ALTER TABLE `items`
+  ADD COLUMN `auctionId` int(11) NOT NULL DEFAULT 0 AFTER `id`,
+  ADD KEY `auctionId` (`auctionId`);
+
+INSERT INTO auctions(slug, name, startsAt, endsAt)
+SELECT 'default', 'Auction',
+  COALESCE((SELECT STR_TO_DATE(LEFT(value, 19), '%Y-%m-%d %H:%i:%s') +
+                   INTERVAL CASE SUBSTRING(value, 21)
+                              WHEN 'CST' THEN 6 WHEN 'CDT' THEN 5 ELSE 0
+                            END HOUR
+            FROM config WHERE name = 'auction_start'), NOW()),
+  COALESCE((SELECT STR_TO_DATE(LEFT(value, 19), '%Y-%m-%d %H:%i:%s') +
+                   INTERVAL CASE SUBSTRING(value, 21)
+                              WHEN 'CST' THEN 6 WHEN 'CDT' THEN 5 ELSE 0
+                            END HOUR
+            FROM config WHERE name = 'auction_end'), NOW())
+FROM DUAL
+WHERE EXISTS (SELECT 1 FROM config
+              WHERE name IN ('auction_start', 'auction_end'))
+   OR EXISTS (SELECT 1 FROM items);
+
+UPDATE items SET auctionId = (SELECT id FROM auctions WHERE slug = 'default');
+
+INSERT INTO config(name, value, value_type)
+SELECT 'current_auction', 'default', 'string'
+FROM DUAL
+WHERE EXISTS (SELECT 1 FROM auctions WHERE slug = 'default')
+  AND NOT EXISTS (SELECT 1 FROM config WHERE name = 'current_auction');
+
+ALTER TABLE `items` ALTER COLUMN `auctionId` DROP DEFAULT;
@@ -0,0 +1,7 @@
+-- 0006_quantity adds the number of units of each item and the number of
+-- units of each bid.
+ALTER TABLE `items`
+  ADD COLUMN `quantity` int(11) NOT NULL DEFAULT 1 AFTER `minBidIncr`;
+
+ALTER TABLE `bids`
+  ADD COLUMN `units` int(11) NOT NULL DEFAULT 1 AFTER `amount`;
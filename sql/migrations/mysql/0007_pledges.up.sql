@@ -0,0 +1,21 @@
+-- 0007_pledges adds the type of each item, the suggested pledges of
+-- fund-a-need items, and the pledges to them.
+ALTER TABLE `items`
+  ADD COLUMN `itemType` varchar(10) NOT NULL DEFAULT 'auction' AFTER `auctionId`,
+  ADD COLUMN `pledgeLevels` varchar(255) NOT NULL DEFAULT "" AFTER `buyNowPrice`;
+
+CREATE TABLE IF NOT EXISTS `pledges` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `itemId` int(11) NOT NULL,
+  `created` timestamp(6) NOT NULL DEFAULT current_timestamp(6),
+  `pledger` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `itemId` (`itemId`)
+);
+
+-- pledge_totals has the total and number of pledges for each item.
+CREATE OR REPLACE VIEW pledge_totals AS
+SELECT itemId AS id, SUM(amount) AS total, COUNT(*) AS pledges
+FROM pledges
+GROUP BY itemId;
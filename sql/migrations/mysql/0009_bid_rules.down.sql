@@ -1,4 +1,21 @@
-DELIMITER //
+-- 0009_bid_rules restores the current_bids view and placeBid procedure of
+-- 0001_init.
+DROP PROCEDURE IF EXISTS placePledge;
+DROP PROCEDURE IF EXISTS buyNow;
+DROP PROCEDURE IF EXISTS placeBid;
+DROP FUNCTION IF EXISTS bidIncrement;
+DROP VIEW IF EXISTS current_bids;
+DROP VIEW IF EXISTS winning_bids;
+DROP VIEW IF EXISTS standing_bids;
+
+CREATE OR REPLACE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount
+FROM bids a
+INNER JOIN (
+  SELECT id, MAX(amount) amount
+  FROM bids
+  GROUP BY id
+) b ON a.id = b.id AND a.amount = b.amount;
 
 -- placeBid will try and place a bid for an item. 
 CREATE OR REPLACE PROCEDURE placeBid(
@@ -61,6 +78,4 @@ BEGIN
 
   COMMIT;
 
-END //
-
-DELIMITER ;
+END;
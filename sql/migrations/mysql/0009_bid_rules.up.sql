@@ -0,0 +1,554 @@
+-- 0009_bid_rules replaces the current_bids view and placeBid procedure of
+-- 0001_init with ones that follow the rules added since: maximum bids,
+-- item and auction times, reserve and buy now prices, quantities, sealed
+-- and fund-a-need items, and increment schedules.
+
+-- standing_bids has the latest bid of each bidder for each item, which is
+-- also their highest since a bidder's bids only increase.
+CREATE OR REPLACE VIEW standing_bids AS
+SELECT a.id, a.created, a.bidder, a.amount, a.units, a.buyNow
+FROM bids a
+WHERE NOT EXISTS (
+  SELECT 1
+  FROM bids b
+  WHERE b.id = a.id AND b.bidder = a.bidder AND b.created > a.created
+);
+
+-- winning_bids has the standing bids that win at least one unit of each
+-- item and the number of units each wins. Units go to the highest bids
+-- first, and ties go to the earliest bid. The last winning bid may win
+-- fewer units than were bid.
+CREATE OR REPLACE VIEW winning_bids AS
+SELECT id, created, bidder, amount, buyNow,
+       CASE WHEN units < quantity - unitsBefore
+            THEN units ELSE quantity - unitsBefore END AS units
+FROM (
+  SELECT s.id, s.created, s.bidder, s.amount, s.buyNow, s.units,
+         items.quantity,
+         COALESCE(SUM(s.units) OVER (
+           PARTITION BY s.id
+           ORDER BY s.amount DESC, s.created
+           ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+         ), 0) AS unitsBefore
+  FROM standing_bids s
+  INNER JOIN items ON s.id = items.id
+) ranked
+WHERE unitsBefore < quantity;
+
+-- current_bids has the lowest winning bid for each item, which is the bid
+-- to beat, and the number of units with a winning bid. For an item with a
+-- quantity of one, this is the highest bid. Ties go to the earliest bid.
+CREATE OR REPLACE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount, a.buyNow, t.unitsBid
+FROM winning_bids a
+INNER JOIN (
+  SELECT id, SUM(units) AS unitsBid
+  FROM winning_bids
+  GROUP BY id
+) t ON a.id = t.id
+WHERE NOT EXISTS (
+  SELECT 1
+  FROM winning_bids b
+  WHERE b.id = a.id
+    AND (b.amount < a.amount OR (b.amount = a.amount AND b.created > a.created))
+);
+
+
+-- bidIncrement returns the increment above amount for item itemId.
+--
+-- The increment comes from the incrSchedule of the item, or of its
+-- auction if the item does not have one. Without a schedule, or if the
+-- schedule does not cover amount, the minBidIncr of the item is used.
+CREATE OR REPLACE FUNCTION bidIncrement(
+  itemId int(11),
+  amount decimal(13,2)
+)
+RETURNS decimal(13,2)
+READS SQL DATA
+BEGIN
+  DECLARE scheduleName varchar(30) DEFAULT "";
+  DECLARE flatIncrement decimal(13,2) DEFAULT 0;
+
+  SELECT IF(items.incrSchedule <> "",
+            items.incrSchedule,
+            IFNULL(auctions.incrSchedule, "")),
+         items.minBidIncr
+  INTO scheduleName, flatIncrement
+  FROM items
+  LEFT OUTER JOIN auctions ON items.auctionId = auctions.id
+  WHERE items.id = itemId;
+
+  RETURN IFNULL((SELECT increment
+                 FROM increments
+                 WHERE schedule = scheduleName AND fromAmount <= amount
+                 ORDER BY fromAmount DESC
+                 LIMIT 1),
+                flatIncrement);
+END;
+
+-- placeBid will try and place a bid for an item.
+--
+-- newAmount is the maximum the bidder is willing to pay. Bids are placed
+-- on behalf of each bidder in steps up to their maximum. When two
+-- maximums compete, the higher one wins at one increment above the other,
+-- and a tie goes to the bidder who reached that amount first. The step
+-- above an amount is given by bidIncrement, which uses the increment
+-- schedule of the item or its auction, or else minBidIncr.
+--
+-- An item with a reservePrice is not sold below it. Once a maximum reaches
+-- the reserve, the current bid is raised to the reserve.
+--
+-- An item with a quantity greater than one has a unit for each of the
+-- highest bids, and a bid may be for newUnits units. These bids are not
+-- placed automatically, so each winner pays the amount they bid. Once all
+-- units are bid, a new bid must beat the lowest winning bid. If the bid
+-- takes units from other bidders, the lowest of them is returned as the
+-- prior bidder.
+--
+-- A sealed item has one bid for each bidder of at least the opening bid,
+-- which the bidder may raise. The highest bid wins, and a tie goes to the
+-- earliest bid. Other bids are never returned for a sealed item, and a
+-- late bid does not extend its closing time.
+--
+-- Bids are only accepted between the item's opening and closing times. An
+-- item opens at opensAt and closes at closesAt, or at the start and end of
+-- its auction if not set, unless a late bid extended it. A bid placed
+-- within the auction's softCloseWindow seconds of closing extends the
+-- item's closing time to softCloseExtension seconds after the bid. No
+-- bids are accepted once an item is bought with buyNow.
+CREATE OR REPLACE PROCEDURE placeBid(
+  bidId int(11),
+  newAmount decimal(13,2),
+  newUnits int(11),
+  newBidder varchar(30)
+)
+MODIFIES SQL DATA
+BEGIN
+  DECLARE bidPlaced boolean DEFAULT false;
+  DECLARE minAmount decimal(13,2) DEFAULT NULL;
+  DECLARE openingBid decimal(13,2) DEFAULT 0;
+  DECLARE reservePrice decimal(13,2) DEFAULT 0;
+  DECLARE itemKind varchar(10) DEFAULT "";
+  DECLARE itemQuantity int DEFAULT 1;
+  DECLARE itemUnitsBid int DEFAULT 0;
+  DECLARE priorUnits int DEFAULT 0;
+  DECLARE afterUnits int DEFAULT 0;
+  DECLARE bidderAmount decimal(13,2) DEFAULT 0;
+  DECLARE curBidder varchar(30) DEFAULT "";
+  DECLARE curAmount decimal(13,2) DEFAULT 0;
+  DECLARE curMax decimal(13,2) DEFAULT 0;
+  DECLARE highBidder varchar(30) DEFAULT "";
+  DECLARE highAmount decimal(13,2) DEFAULT 0;
+  DECLARE bidTime timestamp(6);
+  DECLARE openTime datetime DEFAULT NULL;
+  DECLARE closeTime datetime DEFAULT NULL;
+  DECLARE itemExtendedTo datetime DEFAULT NULL;
+  DECLARE itemSoldAt datetime(6) DEFAULT NULL;
+  DECLARE softCloseWindow int DEFAULT 0;
+  DECLARE softCloseExtension int DEFAULT 0;
+  DECLARE message varchar(30);
+
+  START TRANSACTION;
+
+  SET bidTime = NOW(6);
+
+  -- ensure item exists
+  SELECT COUNT(*) INTO @cnt FROM items WHERE id = bidId;
+  IF @cnt = 0 THEN
+    SET message = 'No such item';
+  ELSEIF @cnt > 1 THEN
+    SET message = 'Multiple rows';
+  ELSE
+    -- get current bid information
+    SELECT items.itemType, items.openingBid,
+           items.reservePrice, items.quantity,
+           IFNULL(items.opensAt, auctions.startsAt),
+           IFNULL(items.closesAt, auctions.endsAt),
+           items.extendedTo, items.soldAt,
+           auctions.softCloseWindow, auctions.softCloseExtension,
+           current_bids.bidder, current_bids.amount, current_bids.unitsBid
+    INTO itemKind, openingBid,
+         reservePrice, itemQuantity,
+         openTime, closeTime, itemExtendedTo, itemSoldAt,
+         softCloseWindow, softCloseExtension,
+         curBidder, curAmount, itemUnitsBid
+    FROM items
+    INNER JOIN auctions ON items.auctionId = auctions.id
+    LEFT OUTER JOIN current_bids ON items.id = current_bids.id
+    WHERE items.id = bidId
+    FOR UPDATE; -- lock tables within transaction
+
+    SET highBidder = IFNULL(curBidder, "");
+    SET highAmount = IFNULL(curAmount, 0);
+
+    -- maximum of the current high bidder, which is at least the current bid
+    SELECT GREATEST(IFNULL(MAX(amount), 0), IFNULL(curAmount, 0))
+    INTO curMax
+    FROM max_bids
+    WHERE id = bidId AND bidder = curBidder
+    FOR UPDATE;
+
+    SET closeTime = GREATEST(closeTime, IFNULL(itemExtendedTo, closeTime));
+
+    IF itemKind = 'pledge' THEN
+      SET message = 'Pledge only item';
+    ELSEIF openingBid = 0 THEN
+      SET message = 'Display only item';
+    ELSEIF itemSoldAt IS NOT NULL THEN
+      SET message = 'Item already sold';
+    ELSEIF bidTime < openTime THEN
+      SET message = 'Bidding has not started';
+    ELSEIF bidTime >= closeTime THEN
+      SET message = 'Bidding has closed';
+    ELSEIF newUnits < 1 OR newUnits > itemQuantity THEN
+      SET message = 'Invalid quantity';
+    ELSEIF itemKind = 'sealed' THEN
+      -- sealed bid of the bidder, which a new bid must exceed
+      SELECT IFNULL(MAX(amount), 0)
+      INTO bidderAmount
+      FROM standing_bids
+      WHERE id = bidId AND bidder = newBidder;
+
+      IF newAmount < openingBid THEN
+        SET message = 'Bid too low';
+      ELSEIF newAmount <= bidderAmount THEN
+        SET message = 'Bid must be higher';
+      ELSE
+        INSERT INTO max_bids(id, bidder, amount)
+        VALUES(bidId, newBidder, newAmount)
+        ON DUPLICATE KEY UPDATE amount = newAmount;
+
+        INSERT INTO bids(id, created, bidder, amount)
+        VALUES(bidId, bidTime, newBidder, newAmount);
+
+        SET bidPlaced = true;
+        SET message = IF(bidderAmount > 0, 'Bid raised', 'Bid placed');
+      END IF;
+    ELSEIF itemQuantity > 1 THEN
+      -- standing bid of the bidder, which a new bid must exceed
+      SELECT IFNULL(MAX(amount), 0)
+      INTO bidderAmount
+      FROM standing_bids
+      WHERE id = bidId AND bidder = newBidder;
+
+      SET minAmount = IF(IFNULL(itemUnitsBid, 0) < itemQuantity,
+                         openingBid,
+                         curAmount+bidIncrement(bidId, curAmount));
+
+      IF newAmount < minAmount OR newAmount <= bidderAmount THEN
+        SET message = 'Bid too low';
+      ELSE
+        -- the lowest winning bidder, other than the new bidder, is the
+        -- first to lose a unit
+        SET curBidder = (SELECT bidder
+                         FROM winning_bids
+                         WHERE id = bidId AND bidder <> newBidder
+                         ORDER BY amount, created DESC
+                         LIMIT 1);
+
+        SELECT IFNULL(SUM(units), 0)
+        INTO priorUnits
+        FROM winning_bids
+        WHERE id = bidId AND bidder = curBidder;
+
+        INSERT INTO bids(id, created, bidder, amount, units)
+        VALUES(bidId, bidTime, newBidder, newAmount, newUnits);
+
+        SELECT IFNULL(SUM(units), 0)
+        INTO afterUnits
+        FROM winning_bids
+        WHERE id = bidId AND bidder = curBidder;
+
+        -- only a bidder who lost a unit is the prior bidder
+        IF afterUnits >= priorUnits THEN
+          SET curBidder = NULL;
+        END IF;
+
+        SELECT amount
+        INTO highAmount
+        FROM current_bids
+        WHERE id = bidId;
+
+        SET highBidder = newBidder;
+        SET bidPlaced = true;
+        SET message = 'Bid placed';
+
+        -- extend closing time for a late bid to prevent sniping
+        IF softCloseWindow > 0 AND
+           bidTime >= closeTime - INTERVAL softCloseWindow SECOND THEN
+          UPDATE items
+          SET extendedTo = GREATEST(closeTime,
+                             bidTime + INTERVAL softCloseExtension SECOND)
+          WHERE id = bidId;
+        END IF;
+      END IF;
+    ELSEIF curBidder = newBidder THEN
+      -- high bidder is raising their maximum, price stays the same
+      IF newAmount <= curMax THEN
+        SET message = 'Maximum bid must be higher';
+      ELSE
+        INSERT INTO max_bids(id, bidder, amount)
+        VALUES(bidId, newBidder, newAmount)
+        ON DUPLICATE KEY UPDATE amount = newAmount;
+
+        -- raise the current bid if the new maximum reaches the reserve
+        SET highAmount = GREATEST(curAmount,
+                                  IF(newAmount >= reservePrice, reservePrice, 0));
+        IF highAmount > curAmount THEN
+          INSERT INTO bids(id, created, bidder, amount)
+          VALUES(bidId, bidTime, newBidder, highAmount);
+        END IF;
+
+        SET bidPlaced = true;
+        SET message = 'Maximum bid raised';
+      END IF;
+    ELSE
+      SET minAmount = IF(ISNULL(curAmount),
+                         openingBid,
+                         curAmount+bidIncrement(bidId, curAmount));
+
+      IF newAmount < minAmount THEN
+        SET message = 'Bid too low';
+      ELSE
+        INSERT INTO max_bids(id, bidder, amount)
+        VALUES(bidId, newBidder, newAmount)
+        ON DUPLICATE KEY UPDATE amount = newAmount;
+
+        IF ISNULL(curAmount) THEN
+          -- first bid is placed at the opening bid
+          SET highBidder = newBidder;
+          SET highAmount = GREATEST(openingBid,
+                                    IF(newAmount >= reservePrice, reservePrice, 0));
+
+          INSERT INTO bids(id, created, bidder, amount)
+          VALUES(bidId, bidTime, newBidder, highAmount);
+
+          SET message = 'Bid placed';
+        ELSEIF newAmount > curMax THEN
+          -- current bidder is bid up to their maximum and then outbid
+          IF curMax > curAmount THEN
+            INSERT INTO bids(id, created, bidder, amount)
+            VALUES(bidId, bidTime, curBidder, curMax);
+          END IF;
+
+          SET highBidder = newBidder;
+          SET highAmount = GREATEST(LEAST(newAmount, curMax+bidIncrement(bidId, curMax)),
+                                    IF(newAmount >= reservePrice, reservePrice, 0));
+
+          INSERT INTO bids(id, created, bidder, amount)
+          VALUES(bidId, bidTime + INTERVAL 1 MICROSECOND, newBidder, highAmount);
+
+          SET message = 'Bid placed';
+        ELSE
+          -- current bidder automatically outbids the new bid
+          SET highAmount = GREATEST(LEAST(curMax, newAmount+bidIncrement(bidId, newAmount)),
+                                    IF(curMax >= reservePrice, reservePrice, 0));
+
+          IF highAmount = newAmount THEN
+            -- tie goes to the current bidder, so record their bid first
+            INSERT INTO bids(id, created, bidder, amount)
+            VALUES(bidId, bidTime, curBidder, highAmount);
+
+            INSERT INTO bids(id, created, bidder, amount)
+            VALUES(bidId, bidTime + INTERVAL 1 MICROSECOND, newBidder, newAmount);
+          ELSE
+            INSERT INTO bids(id, created, bidder, amount)
+            VALUES(bidId, bidTime, newBidder, newAmount);
+
+            INSERT INTO bids(id, created, bidder, amount)
+            VALUES(bidId, bidTime + INTERVAL 1 MICROSECOND, curBidder, highAmount);
+          END IF;
+
+          SET message = 'Outbid by automatic bid';
+        END IF;
+
+        SET bidPlaced = true;
+
+        -- extend closing time for a late bid to prevent sniping
+        IF softCloseWindow > 0 AND
+           bidTime >= closeTime - INTERVAL softCloseWindow SECOND THEN
+          UPDATE items
+          SET extendedTo = GREATEST(closeTime,
+                             bidTime + INTERVAL softCloseExtension SECOND)
+          WHERE id = bidId;
+        END IF;
+      END IF;
+    END IF;
+  END IF;
+
+  -- bids on a sealed item are not revealed
+  IF itemKind = 'sealed' THEN
+    SET curBidder = NULL;
+    SET highBidder = "";
+    SET highAmount = 0;
+  END IF;
+
+  SELECT bidPlaced, message, IFNULL(curBidder,"") AS priorBidder,
+         highBidder, highAmount;
+
+  COMMIT;
+
+END;
+
+-- buyNow will try and buy an item at its buyNowPrice.
+--
+-- The purchase is recorded as a winning bid and bidding on the item is
+-- closed by setting soldAt. Buy now is only available while the item is
+-- open and the current bid is below buyNowCutoff percent of buyNowPrice,
+-- and not for an item with a quantity greater than one.
+CREATE OR REPLACE PROCEDURE buyNow(
+  bidId int(11),
+  newBidder varchar(30)
+)
+MODIFIES SQL DATA
+BEGIN
+  DECLARE bidPlaced boolean DEFAULT false;
+  DECLARE openingBid decimal(13,2) DEFAULT 0;
+  DECLARE buyNowPrice decimal(13,2) DEFAULT 0;
+  DECLARE itemKind varchar(10) DEFAULT "";
+  DECLARE itemQuantity int DEFAULT 1;
+  DECLARE buyNowCutoff int DEFAULT 100;
+  DECLARE curBidder varchar(30) DEFAULT "";
+  DECLARE curAmount decimal(13,2) DEFAULT 0;
+  DECLARE highBidder varchar(30) DEFAULT "";
+  DECLARE highAmount decimal(13,2) DEFAULT 0;
+  DECLARE bidTime timestamp(6);
+  DECLARE openTime datetime DEFAULT NULL;
+  DECLARE closeTime datetime DEFAULT NULL;
+  DECLARE itemExtendedTo datetime DEFAULT NULL;
+  DECLARE itemSoldAt datetime(6) DEFAULT NULL;
+  DECLARE message varchar(30);
+
+  START TRANSACTION;
+
+  SET bidTime = NOW(6);
+
+  -- ensure item exists
+  SELECT COUNT(*) INTO @cnt FROM items WHERE id = bidId;
+  IF @cnt = 0 THEN
+    SET message = 'No such item';
+  ELSEIF @cnt > 1 THEN
+    SET message = 'Multiple rows';
+  ELSE
+    -- get current bid information
+    SELECT items.itemType, items.openingBid, items.buyNowPrice, items.quantity,
+           auctions.buyNowCutoff,
+           IFNULL(items.opensAt, auctions.startsAt),
+           IFNULL(items.closesAt, auctions.endsAt),
+           items.extendedTo, items.soldAt,
+           current_bids.bidder, current_bids.amount
+    INTO itemKind, openingBid, buyNowPrice, itemQuantity, buyNowCutoff,
+         openTime, closeTime, itemExtendedTo, itemSoldAt,
+         curBidder, curAmount
+    FROM items
+    INNER JOIN auctions ON items.auctionId = auctions.id
+    LEFT OUTER JOIN current_bids ON items.id = current_bids.id
+    WHERE items.id = bidId
+    FOR UPDATE; -- lock tables within transaction
+
+    SET highBidder = IFNULL(curBidder, "");
+    SET highAmount = IFNULL(curAmount, 0);
+
+    SET closeTime = GREATEST(closeTime, IFNULL(itemExtendedTo, closeTime));
+
+    IF itemKind = 'pledge' THEN
+      SET message = 'Pledge only item';
+    ELSEIF openingBid = 0 THEN
+      SET message = 'Display only item';
+    ELSEIF itemSoldAt IS NOT NULL THEN
+      SET message = 'Item already sold';
+    ELSEIF bidTime < openTime THEN
+      SET message = 'Bidding has not started';
+    ELSEIF bidTime >= closeTime THEN
+      SET message = 'Bidding has closed';
+    ELSEIF buyNowPrice = 0 OR itemQuantity > 1 OR
+           highAmount >= buyNowPrice * buyNowCutoff / 100 THEN
+      SET message = 'Buy now not available';
+    ELSE
+      SET highBidder = newBidder;
+      SET highAmount = buyNowPrice;
+
+      INSERT INTO bids(id, created, bidder, amount, buyNow)
+      VALUES(bidId, bidTime, newBidder, buyNowPrice, true);
+
+      UPDATE items SET soldAt = bidTime WHERE id = bidId;
+
+      SET bidPlaced = true;
+      SET message = 'Item bought';
+    END IF;
+  END IF;
+
+  SELECT bidPlaced, message, IFNULL(curBidder,"") AS priorBidder,
+         highBidder, highAmount;
+
+  COMMIT;
+
+END;
+
+-- placePledge will try and pledge newAmount to a fund-a-need item.
+--
+-- Pledges do not compete, so any number of pledges of any amount are
+-- accepted between the item's opening and closing times, and each is
+-- recorded as given. The total of all pledges for the item is returned.
+CREATE OR REPLACE PROCEDURE placePledge(
+  pledgeId int(11),
+  newAmount decimal(13,2),
+  newPledger varchar(30)
+)
+MODIFIES SQL DATA
+BEGIN
+  DECLARE pledged boolean DEFAULT false;
+  DECLARE itemKind varchar(10) DEFAULT "";
+  DECLARE pledgeTime timestamp(6);
+  DECLARE openTime datetime DEFAULT NULL;
+  DECLARE closeTime datetime DEFAULT NULL;
+  DECLARE itemExtendedTo datetime DEFAULT NULL;
+  DECLARE message varchar(30);
+
+  START TRANSACTION;
+
+  SET pledgeTime = NOW(6);
+
+  -- ensure item exists
+  SELECT COUNT(*) INTO @cnt FROM items WHERE id = pledgeId;
+  IF @cnt = 0 THEN
+    SET message = 'No such item';
+  ELSEIF @cnt > 1 THEN
+    SET message = 'Multiple rows';
+  ELSE
+    SELECT items.itemType,
+           IFNULL(items.opensAt, auctions.startsAt),
+           IFNULL(items.closesAt, auctions.endsAt),
+           items.extendedTo
+    INTO itemKind, openTime, closeTime, itemExtendedTo
+    FROM items
+    INNER JOIN auctions ON items.auctionId = auctions.id
+    WHERE items.id = pledgeId
+    FOR UPDATE; -- lock tables within transaction
+
+    SET closeTime = GREATEST(closeTime, IFNULL(itemExtendedTo, closeTime));
+
+    IF itemKind <> 'pledge' THEN
+      SET message = 'Not a pledge item';
+    ELSEIF newAmount <= 0 THEN
+      SET message = 'Pledge too low';
+    ELSEIF pledgeTime < openTime THEN
+      SET message = 'Pledging has not started';
+    ELSEIF pledgeTime >= closeTime THEN
+      SET message = 'Pledging has closed';
+    ELSE
+      INSERT INTO pledges(itemId, created, pledger, amount)
+      VALUES(pledgeId, pledgeTime, newPledger, newAmount);
+
+      SET pledged = true;
+      SET message = 'Pledge placed';
+    END IF;
+  END IF;
+
+  SELECT pledged, message, IFNULL(SUM(amount), 0) AS total
+  FROM pledges
+  WHERE itemId = pledgeId;
+
+  COMMIT;
+
+END;
@@ -0,0 +1,10 @@
+-- 0011_image_variants adds the variants of each image, at smaller widths
+-- and in other formats, which browsers choose from.
+CREATE TABLE IF NOT EXISTS `image_variants` (
+  `name` varchar(255) NOT NULL,
+  `fileName` varchar(255) NOT NULL,
+  `width` int(11) NOT NULL,
+  `mimeType` varchar(32) NOT NULL,
+  PRIMARY KEY (`name`),
+  KEY `fileName` (`fileName`)
+);
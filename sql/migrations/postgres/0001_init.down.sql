@@ -0,0 +1,8 @@
+-- 0001_init drops everything created by 0001_init.up.sql.
+DROP VIEW IF EXISTS current_bids;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS config;
+DROP TABLE IF EXISTS bids;
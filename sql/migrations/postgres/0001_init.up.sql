@@ -0,0 +1,67 @@
+-- 0001_init creates the tables and view of gobid for PostgreSQL as they
+-- were before migrations, which the migrations that follow upgrade. Bids
+-- are placed by PostgresDB in a transaction that locks the item with
+-- SELECT ... FOR UPDATE instead of by stored procedures.
+--
+-- Identifiers are not quoted, so PostgreSQL folds them to lower case, as it
+-- does for the unquoted identifiers in the queries.
+CREATE TABLE IF NOT EXISTS bids (
+  id integer NOT NULL,
+  created timestamptz NOT NULL DEFAULT now(),
+  bidder varchar(30) NOT NULL,
+  amount numeric(13,2) NOT NULL,
+  PRIMARY KEY (id, created)
+);
+
+CREATE TABLE IF NOT EXISTS config (
+  name varchar(30) NOT NULL PRIMARY KEY,
+  value varchar(255) NOT NULL,
+  value_type varchar(30) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+  name varchar(10) NOT NULL,
+  succeeded boolean NOT NULL,
+  username varchar(30) NOT NULL,
+  message varchar(255) NOT NULL DEFAULT '',
+  created timestamptz NOT NULL DEFAULT clock_timestamp(),
+  PRIMARY KEY (created, name, username)
+);
+
+CREATE TABLE IF NOT EXISTS items (
+  id serial PRIMARY KEY,
+  title varchar(40) NOT NULL,
+  created timestamptz NOT NULL DEFAULT now(),
+  description varchar(255) NOT NULL DEFAULT '',
+  openingBid numeric(13,2) NOT NULL,
+  minBidIncr numeric(13,2) NOT NULL,
+  artist varchar(30) NOT NULL,
+  imageFileName varchar(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+  hashedValue varchar(64) NOT NULL PRIMARY KEY,
+  expires timestamptz NOT NULL,
+  kind varchar(7) NOT NULL,
+  username varchar(30) NOT NULL,
+  created timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS users (
+  username varchar(30) NOT NULL PRIMARY KEY,
+  fullName varchar(70) NOT NULL,
+  email varchar(256) NOT NULL UNIQUE,
+  hashedPassword varchar(60) NOT NULL,
+  admin boolean NOT NULL DEFAULT false,
+  confirmed boolean NOT NULL DEFAULT false,
+  created timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount
+FROM bids a
+INNER JOIN (
+  SELECT id, MAX(amount) amount
+  FROM bids
+  GROUP BY id
+) b ON a.id = b.id AND a.amount = b.amount;
@@ -0,0 +1,2 @@
+-- 0002_max_bids drops the maximum bids.
+DROP TABLE IF EXISTS max_bids;
@@ -0,0 +1,9 @@
+-- 0002_max_bids adds the maximum bid of each bidder for each item, so bids
+-- are placed on their behalf.
+CREATE TABLE IF NOT EXISTS max_bids (
+  id integer NOT NULL,
+  bidder varchar(30) NOT NULL,
+  amount numeric(13,2) NOT NULL,
+  created timestamptz NOT NULL DEFAULT clock_timestamp(),
+  PRIMARY KEY (id, bidder)
+);
@@ -0,0 +1,7 @@
+-- 0003_item_times adds the opening and closing times of each item, which
+-- are used instead of those of the auction if set, and the time a late bid
+-- extended the closing time to.
+ALTER TABLE items
+  ADD COLUMN opensAt timestamptz NULL DEFAULT NULL,
+  ADD COLUMN closesAt timestamptz NULL DEFAULT NULL,
+  ADD COLUMN extendedTo timestamptz NULL DEFAULT NULL;
@@ -0,0 +1,46 @@
+-- 0004_auctions adds auctions, each with its own items, times, and soft
+-- close, and the current auction in config.
+--
+-- A database with the auction_start and auction_end config of a single
+-- auction, or with items, gets the auction "default" with those times,
+-- which own all existing items. The config times are in America/Chicago
+-- as written by gobid before auctions, such as "2023-05-01 18:00:00 CDT".
+CREATE TABLE IF NOT EXISTS auctions (
+  id serial PRIMARY KEY,
+  slug varchar(30) NOT NULL UNIQUE,
+  name varchar(70) NOT NULL,
+  startsAt timestamptz NOT NULL DEFAULT now(),
+  endsAt timestamptz NOT NULL DEFAULT now(),
+  timeZone varchar(64) NOT NULL DEFAULT 'America/Chicago',
+  softCloseWindow integer NOT NULL DEFAULT 0,
+  softCloseExtension integer NOT NULL DEFAULT 0,
+  created timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE items ADD COLUMN auctionId integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS items_auctionId ON items (auctionId);
+
+INSERT INTO auctions(slug, name, startsAt, endsAt)
+SELECT 'default', 'Auction',
+  COALESCE((SELECT (left(value, 19)::timestamp +
+                   CASE substr(value, 21)
+                     WHEN 'CST' THEN 6 WHEN 'CDT' THEN 5 ELSE 0
+                   END * interval '1 hour') AT TIME ZONE 'UTC'
+            FROM config WHERE name = 'auction_start'), now()),
+  COALESCE((SELECT (left(value, 19)::timestamp +
+                   CASE substr(value, 21)
+                     WHEN 'CST' THEN 6 WHEN 'CDT' THEN 5 ELSE 0
+                   END * interval '1 hour') AT TIME ZONE 'UTC'
+            FROM config WHERE name = 'auction_end'), now())
+WHERE EXISTS (SELECT 1 FROM config
+              WHERE name IN ('auction_start', 'auction_end'))
+   OR EXISTS (SELECT 1 FROM items);
+
+UPDATE items SET auctionId = (SELECT id FROM auctions WHERE slug = 'default');
+
+INSERT INTO config(name, value, value_type)
+SELECT 'current_auction', 'default', 'string'
+WHERE EXISTS (SELECT 1 FROM auctions WHERE slug = 'default')
+  AND NOT EXISTS (SELECT 1 FROM config WHERE name = 'current_auction');
+
+ALTER TABLE items ALTER COLUMN auctionId DROP DEFAULT;
@@ -0,0 +1,9 @@
+-- 0005_buy_now drops the reserve and buy now prices.
+ALTER TABLE auctions DROP COLUMN buyNowCutoff;
+
+ALTER TABLE bids DROP COLUMN buyNow;
+
+ALTER TABLE items
+  DROP COLUMN soldAt,
+  DROP COLUMN buyNowPrice,
+  DROP COLUMN reservePrice;
@@ -0,0 +1,11 @@
+-- 0005_buy_now adds the reserve and buy now prices of items, the time an
+-- item was bought, which bids bought an item, and the current bid as a
+-- percent of the buy now price at which buy now ends for each auction.
+ALTER TABLE items
+  ADD COLUMN reservePrice numeric(13,2) NOT NULL DEFAULT 0,
+  ADD COLUMN buyNowPrice numeric(13,2) NOT NULL DEFAULT 0,
+  ADD COLUMN soldAt timestamptz NULL DEFAULT NULL;
+
+ALTER TABLE bids ADD COLUMN buyNow boolean NOT NULL DEFAULT false;
+
+ALTER TABLE auctions ADD COLUMN buyNowCutoff integer NOT NULL DEFAULT 100;
@@ -0,0 +1,4 @@
+-- 0006_quantity drops the quantities of items and bids.
+ALTER TABLE bids DROP COLUMN units;
+
+ALTER TABLE items DROP COLUMN quantity;
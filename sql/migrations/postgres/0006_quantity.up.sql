@@ -0,0 +1,5 @@
+-- 0006_quantity adds the number of units of each item and the number of
+-- units of each bid.
+ALTER TABLE items ADD COLUMN quantity integer NOT NULL DEFAULT 1;
+
+ALTER TABLE bids ADD COLUMN units integer NOT NULL DEFAULT 1;
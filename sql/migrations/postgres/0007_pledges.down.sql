@@ -0,0 +1,7 @@
+-- 0007_pledges drops pledges and the types of items.
+DROP VIEW IF EXISTS pledge_totals;
+DROP TABLE IF EXISTS pledges;
+
+ALTER TABLE items
+  DROP COLUMN pledgeLevels,
+  DROP COLUMN itemType;
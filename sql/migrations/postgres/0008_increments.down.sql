@@ -0,0 +1,6 @@
+-- 0008_increments drops the bid increment schedules.
+ALTER TABLE auctions DROP COLUMN incrSchedule;
+
+ALTER TABLE items DROP COLUMN incrSchedule;
+
+DROP TABLE IF EXISTS increments;
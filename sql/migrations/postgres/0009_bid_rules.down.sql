@@ -1,3 +1,8 @@
+-- 0009_bid_rules restores the current_bids view of 0001_init.
+DROP VIEW IF EXISTS current_bids;
+DROP VIEW IF EXISTS winning_bids;
+DROP VIEW IF EXISTS standing_bids;
+
 CREATE OR REPLACE VIEW current_bids AS
 SELECT a.id, a.created, a.bidder, a.amount
 FROM bids a
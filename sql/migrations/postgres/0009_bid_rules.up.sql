@@ -0,0 +1,55 @@
+-- 0009_bid_rules replaces the current_bids view of 0001_init with one that
+-- follows the rules added since, for quantities and bids that buy an item.
+
+-- standing_bids has the latest bid of each bidder for each item, which is
+-- also their highest since a bidder's bids only increase.
+DROP VIEW IF EXISTS standing_bids;
+CREATE VIEW standing_bids AS
+SELECT a.id, a.created, a.bidder, a.amount, a.units, a.buyNow
+FROM bids a
+WHERE NOT EXISTS (
+  SELECT 1
+  FROM bids b
+  WHERE b.id = a.id AND b.bidder = a.bidder AND b.created > a.created
+);
+
+-- winning_bids has the standing bids that win at least one unit of each
+-- item and the number of units each wins. Units go to the highest bids
+-- first, and ties go to the earliest bid. The last winning bid may win
+-- fewer units than were bid.
+DROP VIEW IF EXISTS winning_bids;
+CREATE VIEW winning_bids AS
+SELECT id, created, bidder, amount, buyNow,
+       CASE WHEN units < quantity - unitsBefore
+            THEN units ELSE quantity - unitsBefore END AS units
+FROM (
+  SELECT s.id, s.created, s.bidder, s.amount, s.buyNow, s.units,
+         items.quantity,
+         COALESCE(SUM(s.units) OVER (
+           PARTITION BY s.id
+           ORDER BY s.amount DESC, s.created
+           ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+         ), 0) AS unitsBefore
+  FROM standing_bids s
+  INNER JOIN items ON s.id = items.id
+) ranked
+WHERE unitsBefore < quantity;
+
+-- current_bids has the lowest winning bid for each item, which is the bid
+-- to beat, and the number of units with a winning bid. For an item with a
+-- quantity of one, this is the highest bid. Ties go to the earliest bid.
+DROP VIEW IF EXISTS current_bids;
+CREATE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount, a.buyNow, t.unitsBid
+FROM winning_bids a
+INNER JOIN (
+  SELECT id, SUM(units) AS unitsBid
+  FROM winning_bids
+  GROUP BY id
+) t ON a.id = t.id
+WHERE NOT EXISTS (
+  SELECT 1
+  FROM winning_bids b
+  WHERE b.id = a.id
+    AND (b.amount < a.amount OR (b.amount = a.amount AND b.created > a.created))
+);
@@ -0,0 +1,2 @@
+-- 0010_item_images drops the images of items, other than the first.
+DROP TABLE IF EXISTS item_images;
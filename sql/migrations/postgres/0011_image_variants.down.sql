@@ -0,0 +1,2 @@
+-- 0011_image_variants drops the variants of images.
+DROP TABLE IF EXISTS image_variants;
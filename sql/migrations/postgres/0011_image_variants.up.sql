@@ -0,0 +1,11 @@
+-- 0011_image_variants adds the variants of each image, at smaller widths
+-- and in other formats, which browsers choose from.
+CREATE TABLE IF NOT EXISTS image_variants (
+  name varchar(255) NOT NULL,
+  fileName varchar(255) NOT NULL,
+  width integer NOT NULL,
+  mimeType varchar(32) NOT NULL,
+  PRIMARY KEY (name)
+);
+
+CREATE INDEX IF NOT EXISTS image_variants_fileName ON image_variants (fileName);
@@ -0,0 +1,8 @@
+-- 0012_image_status adds the status of processing each uploaded image,
+-- which is pending until its sizes and variants are saved.
+CREATE TABLE IF NOT EXISTS image_status (
+  fileName varchar(255) NOT NULL,
+  state varchar(16) NOT NULL,
+  message varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (fileName)
+);
@@ -0,0 +1,2 @@
+-- 0013_auction_currency drops the currency of auctions.
+ALTER TABLE auctions DROP COLUMN currency;
@@ -0,0 +1,65 @@
+-- 0001_init creates the tables and view of gobid for SQLite as they were
+-- before migrations, which the migrations that follow upgrade. SQLite does
+-- not have stored procedures, so bids are placed by SQLiteDB instead.
+--
+-- Times are stored as text in UTC, so that they sort in order.
+CREATE TABLE IF NOT EXISTS `bids` (
+  `id` int NOT NULL,
+  `created` timestamp NOT NULL DEFAULT current_timestamp,
+  `bidder` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL,
+  PRIMARY KEY (`id`,`created`)
+);
+
+CREATE TABLE IF NOT EXISTS `config` (
+  `name` varchar(30) NOT NULL PRIMARY KEY,
+  `value` varchar(255) NOT NULL,
+  `value_type` varchar(30) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `events` (
+  `name` varchar(10) NOT NULL,
+  `succeeded` boolean NOT NULL,
+  `username` varchar(30) NOT NULL,
+  `message` varchar(255) NOT NULL DEFAULT '',
+  `created` timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  PRIMARY KEY (`created`,`name`,`username`)
+);
+
+CREATE TABLE IF NOT EXISTS `items` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `title` varchar(40) NOT NULL,
+  `created` timestamp NOT NULL DEFAULT current_timestamp,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `openingBid` decimal(13,2) NOT NULL,
+  `minBidIncr` decimal(13,2) NOT NULL,
+  `artist` varchar(30) NOT NULL,
+  `imageFileName` varchar(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `tokens` (
+  `hashedValue` binary(64) NOT NULL PRIMARY KEY,
+  `expires` datetime NOT NULL,
+  `kind` varchar(7) NOT NULL,
+  `username` varchar(30) NOT NULL,
+  `created` timestamp NOT NULL DEFAULT current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `username` varchar(30) NOT NULL PRIMARY KEY,
+  `fullName` varchar(70) NOT NULL,
+  `email` varchar(256) NOT NULL UNIQUE,
+  `hashedPassword` binary(60) NOT NULL,
+  `admin` boolean NOT NULL DEFAULT false,
+  `confirmed` boolean NOT NULL DEFAULT false,
+  `created` timestamp NOT NULL DEFAULT current_timestamp
+);
+
+CREATE VIEW IF NOT EXISTS current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount
+FROM bids a
+INNER JOIN (
+  SELECT id, MAX(amount) amount
+  FROM bids
+  GROUP BY id
+) b ON a.id = b.id AND a.amount = b.amount;
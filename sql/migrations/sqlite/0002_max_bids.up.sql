@@ -0,0 +1,9 @@
+-- 0002_max_bids adds the maximum bid of each bidder for each item, so bids
+-- are placed on their behalf.
+CREATE TABLE IF NOT EXISTS `max_bids` (
+  `id` int NOT NULL,
+  `bidder` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL,
+  `created` timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  PRIMARY KEY (`id`,`bidder`)
+);
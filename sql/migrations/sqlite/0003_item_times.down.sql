@@ -0,0 +1,4 @@
+-- 0003_item_times drops the opening and closing times of items.
+ALTER TABLE `items` DROP COLUMN `extendedTo`;
+ALTER TABLE `items` DROP COLUMN `closesAt`;
+ALTER TABLE `items` DROP COLUMN `opensAt`;
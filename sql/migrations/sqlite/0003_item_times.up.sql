@@ -0,0 +1,6 @@
+-- 0003_item_times adds the opening and closing times of each item, which
+-- are used instead of those of the auction if set, and the time a late bid
+-- extended the closing time to.
+ALTER TABLE `items` ADD COLUMN `opensAt` timestamp NULL DEFAULT NULL;
+ALTER TABLE `items` ADD COLUMN `closesAt` timestamp NULL DEFAULT NULL;
+ALTER TABLE `items` ADD COLUMN `extendedTo` timestamp NULL DEFAULT NULL;
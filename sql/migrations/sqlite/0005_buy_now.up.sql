@@ -0,0 +1,10 @@
+-- 0005_buy_now adds the reserve and buy now prices of items, the time an
+-- item was bought, which bids bought an item, and the current bid as a
+-- percent of the buy now price at which buy now ends for each auction.
+ALTER TABLE `items` ADD COLUMN `reservePrice` decimal(13,2) NOT NULL DEFAULT 0;
+ALTER TABLE `items` ADD COLUMN `buyNowPrice` decimal(13,2) NOT NULL DEFAULT 0;
+ALTER TABLE `items` ADD COLUMN `soldAt` timestamp NULL DEFAULT NULL;
+
+ALTER TABLE `bids` ADD COLUMN `buyNow` boolean NOT NULL DEFAULT false;
+
+ALTER TABLE `auctions` ADD COLUMN `buyNowCutoff` int NOT NULL DEFAULT 100;
@@ -0,0 +1,20 @@
+-- 0007_pledges adds the type of each item, the suggested pledges of
+-- fund-a-need items, and the pledges to them.
+ALTER TABLE `items` ADD COLUMN `itemType` varchar(10) NOT NULL DEFAULT 'auction';
+ALTER TABLE `items` ADD COLUMN `pledgeLevels` varchar(255) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS `pledges` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `itemId` int NOT NULL,
+  `created` timestamp NOT NULL,
+  `pledger` varchar(30) NOT NULL,
+  `amount` decimal(13,2) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `pledges_itemId` ON `pledges` (`itemId`);
+
+-- pledge_totals has the total and number of pledges for each item.
+DROP VIEW IF EXISTS pledge_totals;
+CREATE VIEW pledge_totals AS
+SELECT itemId AS id, SUM(amount) AS total, COUNT(*) AS pledges
+FROM pledges
+GROUP BY itemId;
@@ -0,0 +1,16 @@
+-- 0008_increments adds the bid increment schedules, and the schedule of
+-- each auction and of each item, which is used instead of that of its
+-- auction if set.
+--
+-- A schedule applies the increment of its row with the highest fromAmount
+-- that is at most the current bid.
+CREATE TABLE IF NOT EXISTS `increments` (
+  `schedule` varchar(30) NOT NULL,
+  `fromAmount` decimal(13,2) NOT NULL DEFAULT 0,
+  `increment` decimal(13,2) NOT NULL,
+  PRIMARY KEY (`schedule`, `fromAmount`)
+);
+
+ALTER TABLE `items` ADD COLUMN `incrSchedule` varchar(30) NOT NULL DEFAULT '';
+
+ALTER TABLE `auctions` ADD COLUMN `incrSchedule` varchar(30) NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- 0009_bid_rules restores the current_bids view of 0001_init.
+DROP VIEW IF EXISTS current_bids;
+DROP VIEW IF EXISTS winning_bids;
+DROP VIEW IF EXISTS standing_bids;
+
+CREATE VIEW current_bids AS
+SELECT a.id, a.created, a.bidder, a.amount
+FROM bids a
+INNER JOIN (
+  SELECT id, MAX(amount) amount
+  FROM bids
+  GROUP BY id
+) b ON a.id = b.id AND a.amount = b.amount;
@@ -0,0 +1,11 @@
+-- 0010_item_images adds the images of each item in order. The first image
+-- is also in items.imageFileName, which is used for the gallery thumbnail.
+CREATE TABLE IF NOT EXISTS `item_images` (
+  `itemId` int NOT NULL,
+  `position` int NOT NULL,
+  `fileName` varchar(255) NOT NULL,
+  PRIMARY KEY (`itemId`,`position`)
+);
+
+INSERT INTO item_images(itemId, position, fileName)
+SELECT id, 0, imageFileName FROM items WHERE imageFileName <> '';
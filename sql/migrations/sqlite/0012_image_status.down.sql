@@ -0,0 +1,2 @@
+-- 0012_image_status drops the status of processing images.
+DROP TABLE IF EXISTS `image_status`;
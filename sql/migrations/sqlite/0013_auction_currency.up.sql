@@ -0,0 +1,4 @@
+-- 0013_auction_currency adds the ISO 4217 currency that the amounts of
+-- each auction are in.
+ALTER TABLE `auctions`
+  ADD COLUMN `currency` varchar(3) NOT NULL DEFAULT 'USD';
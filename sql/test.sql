@@ -1,18 +1,77 @@
+TRUNCATE TABLE auctions;
+
+INSERT INTO auctions(id, slug, name, startsAt, endsAt, timeZone, softCloseWindow, softCloseExtension, buyNowCutoff, created)
+VALUES
+(1,"test","Test Auction",NOW() - INTERVAL 1 DAY,NOW() + INTERVAL 1 DAY,"America/Chicago",0,0,75,"2022-12-29"),
+(2,"past","Past Auction","2021-12-01","2021-12-02","America/New_York",0,0,100,"2021-11-01"),
+(3,"soon","Future Auction",NOW() + INTERVAL 1 DAY,NOW() + INTERVAL 2 DAY,"America/Chicago",0,0,100,"2022-12-29"),
+(4,"soft","Soft Close Auction",NOW() - INTERVAL 1 DAY,NOW() + INTERVAL 1 MINUTE,"America/Chicago",120,300,100,"2022-12-29");
+
+INSERT INTO auctions(id, slug, name, startsAt, endsAt, timeZone, incrSchedule, created)
+VALUES
+(5,"incr","Increment Auction",NOW() - INTERVAL 1 DAY,NOW() + INTERVAL 1 DAY,"America/Chicago","standard","2022-12-29");
+
+TRUNCATE TABLE increments;
+
+INSERT INTO increments(schedule, fromAmount, increment)
+VALUES
+("standard",0,5),
+("standard",100,10),
+("standard",500,25),
+("fine",0,1);
+
 TRUNCATE TABLE items;
 
-INSERT INTO items(id, title, created, description, openingBid, minBidIncr, artist, imageFileName)
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName)
+VALUES
+(1,1,"Bid Test","2022-12-30 01:00","Item to test PlaceBid",10,2,"ARTIST","FILENAME"),
+(2,1,"Item Test","2022-12-30 02:00","Item to test GetItem",10,2,"ARTIST","FILENAME"),
+(3,1,"Item Test with Bid","2022-12-30 03:00","Item to test GetItem with Bid",5,1,"Art","File"),
+(4,1,"Item Test Display Only","2022-12-30 04:00","Item to test Display Only",0,0,"Art4","File4"),
+(5,1,"UpdateItem Test","2022-12-30 05:00","Item to test UpdateItem",1,1,"Art5","File5"),
+(6,1,"Item Test with 3 Bids","2022-12-30 06:00","Item to test GetItem with 3 Bids",3,2,"Art 3 Bid","File 3 Bid"),
+(7,4,"Soft Close Test","2022-12-30 07:00","Item to test soft close",10,1,"Art7","File7"),
+(9,2,"Past Auction Item","2021-11-02 00:00","Item in a past auction",10,1,"Art9","File9"),
+(10,3,"Future Auction Item","2022-12-30 10:00","Item in a future auction",10,1,"Art10","File10");
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, reservePrice)
+VALUES
+(11,1,"Reserve Test","2022-12-30 11:00","Item to test reservePrice",10,1,"Art11","File11",50),
+(12,1,"Reserve Not Met Test","2022-12-30 12:00","Item to test reservePrice not met",5,1,"Art12","File12",100);
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, buyNowPrice)
+VALUES
+(13,1,"Buy Now Test","2022-12-30 13:00","Item to test buyNow",10,1,"Art13","File13",100),
+(14,1,"Buy Now Cutoff Test","2022-12-30 14:00","Item to test buyNowCutoff",10,1,"Art14","File14",40);
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, quantity)
+VALUES
+(15,1,"Quantity Test","2022-12-30 15:00","Item to test quantity",10,5,"Art15","File15",3);
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, itemType, pledgeLevels)
+VALUES
+(16,1,"Pledge Test","2022-12-30 16:00","Item to test pledges",0,0,"Art16","File16","pledge","100, 250, 500");
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, itemType)
+VALUES
+(17,1,"Sealed Test","2022-12-30 17:00","Item to test sealed bids",10,1,"Art17","File17","sealed");
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, incrSchedule)
+VALUES
+(18,1,"Item Schedule Test","2022-12-30 18:00","Item to test incrSchedule",50,1,"Art18","File18","standard"),
+(19,5,"Auction Schedule Test","2022-12-30 19:00","Item to test auction incrSchedule",50,1,"Art19","File19",""),
+(20,5,"Override Schedule Test","2022-12-30 20:00","Item to test incrSchedule override",50,2,"Art20","File20","fine");
+
+INSERT INTO items(id, auctionId, title, created, description, openingBid, minBidIncr, artist, imageFileName, opensAt, closesAt)
 VALUES
-(1,"Bid Test","2022-12-30 01:00","Item to test PlaceBid",10,2,"ARTIST","FILENAME"),
-(2,"Item Test","2022-12-30 02:00","Item to test GetItem",10,2,"ARTIST","FILENAME"),
-(3,"Item Test with Bid","2022-12-30 03:00","Item to test GetItem with Bid",5,1,"Art","File"),
-(4,"Item Test Display Only","2022-12-30 04:00","Item to test Display Only",0,0,"Art4","File4"),
-(5,"UpdateItem Test","2022-12-30 05:00","Item to test UpdateItem",1,1,"Art5","File5"),
-(6,"Item Test with 3 Bids","2022-12-30 06:00","Item to test GetItem with 3 Bids",3,2,"Art 3 Bid","File 3 Bid");
+(8,1,"Closed Item Test","2022-12-30 08:00","Item to test closesAt",10,1,"Art8","File8","2022-12-30 00:00","2022-12-31 00:00");
 
 TRUNCATE TABLE config;
 
 INSERT INTO config(name, value, value_type)
-VALUES("cname", "cvalue", "ctype");
+VALUES
+("cname", "cvalue", "ctype"),
+("current_auction", "test", "string");
 
 TRUNCATE TABLE bids;
 
@@ -21,7 +80,30 @@ VALUES
 (3,"2022-12-31","test",15),
 (6,"2022-12-31 01:00","test",3),
 (6,"2022-12-31 02:00","test",5),
-(6,"2022-12-31 03:00","test",7);
+(6,"2022-12-31 03:00","test",7),
+(12,"2022-12-31","test",20),
+(14,"2022-12-31","test",30);
+
+INSERT INTO bids(id, created, bidder, amount, units)
+VALUES
+(15,"2022-12-31","test",20,2);
+
+INSERT INTO bids(id, created, bidder, amount)
+VALUES
+(17,"2022-12-31","test",20),
+(18,"2022-12-31","test",120);
+
+TRUNCATE TABLE max_bids;
+
+INSERT INTO max_bids(id, bidder, amount)
+VALUES
+(17,"test",20);
+
+TRUNCATE TABLE pledges;
+
+INSERT INTO pledges(itemId, created, pledger, amount)
+VALUES
+(16,"2022-12-31","test",100);
 
 TRUNCATE TABLE users;
 
@@ -1,5 +1,4 @@
-DROP TABLE IF EXISTS users;
-source users.sql;
+TRUNCATE TABLE users;
 
 INSERT INTO users(userName, fullName, email, hashedPassword)
 VALUES ('test', 'Test User', 'test@email', '$2a$10$2bLycFqUmc6m6iLkaeUgKOGwzekGd9IoAPMbXRNNuJ8Sv9ItgV29O');
@@ -7,11 +6,9 @@ VALUES ('test', 'Test User', 'test@email', '$2a$10$2bLycFqUmc6m6iLkaeUgKOGwzekGd
 INSERT INTO users(userName, fullName, email, hashedPassword, admin)
 VALUES ('admin', 'Admin User', 'admin@email', '$2a$10$2bLycFqUmc6m6iLkaeUgKOGwzekGd9IoAPMbXRNNuJ8Sv9ItgV29O', 1);
 
-DROP TABLE IF EXISTS tokens;
-source tokens.sql;
+TRUNCATE TABLE tokens;
 
-DROP TABLE IF EXISTS events;
-source events.sql;
+TRUNCATE TABLE events;
 
 INSERT INTO events(userName, created, name, succeeded)
 VALUES
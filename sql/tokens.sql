@@ -1,8 +0,0 @@
-CREATE TABLE `tokens` (
-  `hashedValue` binary(64) NOT NULL,
-  `expires` datetime NOT NULL,
-  `kind` varchar(7) NOT NULL,
-  `userName` varchar(30) NOT NULL,
-  `created` timestamp NOT NULL DEFAULT current_timestamp(),
-  PRIMARY KEY (`hashedValue`)
-);
@@ -0,0 +1,56 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/bnixon67/webapp/webauth"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDB is a BidStore that uses SQLite, so the whole app can run with
+// a single database file.
+//
+// It uses the queries of BidDB, which are portable. SQLite does not have
+// stored procedures, so bids, purchases, and pledges are placed in a
+// transaction with the same rules as the procedures.
+type SQLiteDB struct {
+	txBidDB
+}
+
+// NewSQLiteDB returns a SQLiteDB for sqlDB, which has the schema from its
+// migrations.
+//
+// SQLite allows one writer at a time, so sqlDB is limited to a single
+// connection, which also keeps bids on an item from being placed at once.
+func NewSQLiteDB(sqlDB *webauth.AuthDB) (*SQLiteDB, error) {
+	if sqlDB == nil {
+		return nil, ErrInvalidDB
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+
+	return &SQLiteDB{txBidDB{BidDB: BidDB{sqlDB: sqlDB}}}, nil
+}
+
+// SQLiteDataSourceName returns dataSourceName with the parameters needed by
+// SQLiteDB added if they are missing, which store times in a format that
+// sorts in order.
+func SQLiteDataSourceName(dataSourceName string) string {
+	name, query, _ := strings.Cut(dataSourceName, "?")
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return dataSourceName
+	}
+
+	if !params.Has("_time_format") {
+		params.Set("_time_format", "sqlite")
+	}
+
+	return name + "?" + params.Encode()
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bnixon67/webapp/webauth"
+)
+
+// sqliteDBForTest returns a SQLiteDB in a new database file seeded by
+// seedStoreForTest.
+func sqliteDBForTest(t *testing.T) *SQLiteDB {
+	db := emptySQLiteDBForTest(t)
+
+	seedStoreForTest(t, sqlSeeder{BidStore: db, sqlDB: db.sqlDB, t: t})
+
+	return db
+}
+
+// emptySQLiteDBForTest returns a SQLiteDB in a new database file with the
+// schema from its migrations and no rows.
+func emptySQLiteDBForTest(t *testing.T) *SQLiteDB {
+	dataSourceName := SQLiteDataSourceName(filepath.Join(t.TempDir(), "gobid.db"))
+
+	sqlDB, err := webauth.InitDB(DriverSQLite, dataSourceName)
+	if err != nil {
+		t.Fatalf("cannot init db: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	_, err = MigrateUp(sqlDB.DB, DriverSQLite)
+	if err != nil {
+		t.Fatalf("MigrateUp() failed: %v", err)
+	}
+
+	db, err := NewSQLiteDB(sqlDB)
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() failed: %v", err)
+	}
+
+	return db
+}
+
+func TestSQLiteDataSourceName(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"gobid.db", "gobid.db?_time_format=sqlite"},
+		{"file:gobid.db?_pragma=busy_timeout(5000)", "file:gobid.db?_pragma=busy_timeout%285000%29&_time_format=sqlite"},
+		{"gobid.db?_time_format=sqlite", "gobid.db?_time_format=sqlite"},
+	}
+
+	for _, tc := range cases {
+		got := SQLiteDataSourceName(tc.in)
+		if got != tc.want {
+			t.Errorf("SQLiteDataSourceName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNewSQLiteDBExisting(t *testing.T) {
+	dataSourceName := SQLiteDataSourceName(filepath.Join(t.TempDir(), "gobid.db"))
+
+	for i := 0; i < 2; i++ {
+		sqlDB, err := webauth.InitDB(DriverSQLite, dataSourceName)
+		if err != nil {
+			t.Fatalf("cannot init db: %v", err)
+		}
+
+		_, err = MigrateUp(sqlDB.DB, DriverSQLite)
+		if err != nil {
+			t.Fatalf("MigrateUp() failed on open %d: %v", i+1, err)
+		}
+
+		db, err := NewSQLiteDB(sqlDB)
+		if err != nil {
+			t.Fatalf("NewSQLiteDB() failed on open %d: %v", i+1, err)
+		}
+
+		if i == 0 {
+			seedStoreForTest(t, sqlSeeder{BidStore: db, sqlDB: db.sqlDB, t: t})
+		}
+
+		items, err := db.GetItems(1)
+		if err != nil || len(items) != 9 {
+			t.Errorf("GetItems(1) on open %d got %d items err '%v' want 9", i+1, len(items), err)
+		}
+
+		sqlDB.Close()
+	}
+}
+
+func TestSQLiteAuthDB(t *testing.T) {
+	db := sqliteDBForTest(t)
+
+	err := db.sqlDB.RegisterUser("new", "New User", "new@user", "password")
+	if err != nil {
+		t.Fatalf("RegisterUser() failed: %v", err)
+	}
+
+	err = db.sqlDB.CheckPassword("new", "password")
+	if err != nil {
+		t.Errorf("CheckPassword() failed: %v", err)
+	}
+
+	token, err := db.sqlDB.CreateToken("reset", "new", 32, "1h")
+	if err != nil {
+		t.Fatalf("CreateToken() failed: %v", err)
+	}
+
+	userName, err := db.sqlDB.UsernameForResetToken(token.Value)
+	if err != nil || userName != "new" {
+		t.Errorf("UsernameForResetToken() got %q err '%v' want %q", userName, err, "new")
+	}
+
+	_, err = db.PlaceBid(1, Cents(2000), "new")
+	if err != nil {
+		t.Fatalf("PlaceBid() failed: %v", err)
+	}
+
+	events, err := db.sqlDB.GetEvents()
+	if err != nil || len(events) != 1 || events[0].Name != EventBid {
+		t.Errorf("GetEvents() got %s err '%v'", AsJson(events), err)
+	}
+}
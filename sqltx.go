@@ -0,0 +1,206 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// storedTime returns t as it is stored in the database, in UTC so that
+// times sort in order as text in SQLite.
+func storedTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// txBidDB is a BidDB for a database without the stored procedures, so
+// bids, purchases, and pledges are placed in a transaction with the same
+// rules as the procedures.
+type txBidDB struct {
+	BidDB
+	lockItem bool // lock the item row before a bid with SELECT ... FOR UPDATE
+}
+
+// PlaceBid places a bid of up to bidAmount for userName on item id. Bids
+// are placed automatically on behalf of the bidder up to bidAmount.
+func (db txBidDB) PlaceBid(id int, bidAmount Money, userName string) (BidResult, error) {
+	return db.PlaceUnitsBid(id, bidAmount, 1, userName)
+}
+
+// PlaceUnitsBid places a bid of bidAmount for each of units units of item
+// id for userName, following the rules of the placeBid procedure.
+func (db txBidDB) PlaceUnitsBid(id int, bidAmount Money, units int, userName string) (BidResult, error) {
+	bidResult, err := inTx(db, func(tx bidTx) (BidResult, error) {
+		return placeBid(tx, storedTime(time.Now()), id, bidAmount, units, userName)
+	})
+	if err != nil {
+		bidResult.Message = PlaceBidError
+		return bidResult, err
+	}
+
+	db.sqlDB.WriteEvent(EventBid, true, userName, bidResult.Message)
+
+	return bidResult, err
+}
+
+// BuyNow buys item id for userName at its buy now price, following the
+// rules of the buyNow procedure.
+func (db txBidDB) BuyNow(id int, userName string) (BidResult, error) {
+	bidResult, err := inTx(db, func(tx bidTx) (BidResult, error) {
+		return buyNow(tx, storedTime(time.Now()), id, userName)
+	})
+	if err != nil {
+		bidResult.Message = PlaceBidError
+		return bidResult, err
+	}
+
+	db.sqlDB.WriteEvent(EventBid, true, userName, bidResult.Message)
+
+	return bidResult, err
+}
+
+// Pledge pledges amount to pledge item id for userName, following the
+// rules of the placePledge procedure.
+func (db txBidDB) Pledge(id int, amount Money, userName string) (PledgeResult, error) {
+	pledgeResult, err := inTx(db, func(tx bidTx) (PledgeResult, error) {
+		return placePledge(tx, storedTime(time.Now()), id, amount, userName)
+	})
+	if err != nil {
+		pledgeResult.Message = PledgeError
+		return pledgeResult, err
+	}
+
+	db.sqlDB.WriteEvent(EventPledge, true, userName, pledgeResult.Message)
+
+	return pledgeResult, err
+}
+
+// inTx calls f within a transaction of db, which is committed if f does
+// not return an error.
+func inTx[T any](db txBidDB, f func(tx bidTx) (T, error)) (T, error) {
+	var result T
+
+	if db.sqlDB == nil {
+		return result, ErrInvalidDB
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	result, err = f(sqlTx{tx: tx, lockItem: db.lockItem})
+	if err != nil {
+		return result, err
+	}
+
+	return result, tx.Commit()
+}
+
+// sqlTx is the bidTx of a txBidDB.
+type sqlTx struct {
+	tx       *sql.Tx
+	lockItem bool
+}
+
+func (t sqlTx) item(id int) (Item, Auction, error) {
+	if t.lockItem {
+		// wait for other bids on the item, a missing item is
+		// reported by getItem
+		var lockedID int
+		err := t.tx.QueryRow("SELECT id FROM items WHERE id = ? FOR UPDATE", id).Scan(&lockedID)
+		if err != nil && err != sql.ErrNoRows {
+			return Item{}, Auction{}, err
+		}
+	}
+
+	item, err := getItem(t.tx, id)
+	if err != nil {
+		return item, Auction{}, err
+	}
+
+	qry := "SELECT " + auctionColumns + " FROM auctions WHERE id = ?"
+
+	auction, err := scanAuction(t.tx.QueryRow(qry, item.AuctionID))
+	if err == sql.ErrNoRows {
+		return item, auction, fmt.Errorf("auction %d: %w", item.AuctionID, ErrNotFound)
+	}
+
+	return item, auction, err
+}
+
+func (t sqlTx) maxBid(id int, bidder string) (Money, error) {
+	var amount Money
+
+	qry := "SELECT amount FROM max_bids WHERE id = ? AND bidder = ?"
+
+	err := t.tx.QueryRow(qry, id, bidder).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return Money{}, nil
+	}
+
+	return amount, err
+}
+
+func (t sqlTx) standingAmount(id int, bidder string) (Money, error) {
+	var amount Money
+
+	qry := "SELECT COALESCE(MAX(amount),0) FROM standing_bids WHERE id = ? AND bidder = ?"
+
+	err := t.tx.QueryRow(qry, id, bidder).Scan(&amount)
+
+	return amount, err
+}
+
+func (t sqlTx) winningBids(id int) ([]WinningBid, error) {
+	return getWinningBids(t.tx, id)
+}
+
+func (t sqlTx) setMaxBid(id int, bidder string, amount Money) error {
+	upsert := "INSERT INTO max_bids(id, bidder, amount) VALUES (?, ?, ?) ON CONFLICT(id, bidder) DO UPDATE SET amount = excluded.amount"
+
+	_, err := t.tx.Exec(upsert, id, bidder, amount)
+
+	return err
+}
+
+func (t sqlTx) addBid(id int, created time.Time, bidder string, amount Money, units int, buyNow bool) error {
+	insert := "INSERT INTO bids(id, created, bidder, amount, units, buyNow) VALUES (?, ?, ?, ?, ?, ?)"
+
+	_, err := t.tx.Exec(insert, id, storedTime(created), bidder, amount, units, buyNow)
+
+	return err
+}
+
+func (t sqlTx) extendItem(id int, extendedTo time.Time) error {
+	_, err := t.tx.Exec("UPDATE items SET extendedTo = ? WHERE id = ?", storedTime(extendedTo), id)
+
+	return err
+}
+
+func (t sqlTx) sellItem(id int, soldAt time.Time) error {
+	_, err := t.tx.Exec("UPDATE items SET soldAt = ? WHERE id = ?", storedTime(soldAt), id)
+
+	return err
+}
+
+func (t sqlTx) addPledge(id int, created time.Time, pledger string, amount Money) error {
+	insert := "INSERT INTO pledges(itemId, created, pledger, amount) VALUES (?, ?, ?, ?)"
+
+	_, err := t.tx.Exec(insert, id, storedTime(created), pledger, amount)
+
+	return err
+}
+
+func (t sqlTx) pledgeTotal(id int) (Money, error) {
+	var total Money
+
+	qry := "SELECT COALESCE(SUM(amount),0) FROM pledges WHERE itemId = ?"
+
+	err := t.tx.QueryRow(qry, id).Scan(&total)
+
+	return total, err
+}
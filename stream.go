@@ -0,0 +1,357 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bnixon67/webapp/webauth"
+	"github.com/bnixon67/webapp/webhandler"
+	"github.com/bnixon67/webapp/webutil"
+)
+
+// BidUpdate is the new price of an item sent to browsers after a bid.
+type BidUpdate struct {
+	ID          int       `json:"id"`
+	CurrentBid  Money     `json:"currentBid"`
+	Bidder      string    `json:"bidder"` // display name of high bidder
+	MinBid      Money     `json:"minBid"`
+	ReserveMet  bool      `json:"reserveMet"`  // true if no reserve
+	BuyNow      bool      `json:"buyNow"`      // true if buy now is available
+	Sold        bool      `json:"sold"`        // true if bought with buy now
+	PledgeTotal Money     `json:"pledgeTotal"` // total of pledges
+	Pledges     int       `json:"pledges"`     // number of pledges
+	ClosesAt    time.Time `json:"closesAt"`
+}
+
+// NewBidUpdate returns the update for item in auction.
+func NewBidUpdate(item Item, auction Auction) BidUpdate {
+	return BidUpdate{
+		ID:          item.ID,
+		CurrentBid:  item.CurrentBid,
+		Bidder:      DisplayName(item.Bidder),
+		MinBid:      item.MinBid,
+		ReserveMet:  !item.HasReserve() || item.ReserveMet(),
+		BuyNow:      auction.BuyNowAvailable(item),
+		Sold:        item.Sold(),
+		PledgeTotal: item.PledgeTotal,
+		Pledges:     item.Pledges,
+		ClosesAt:    auction.ItemClosesAt(item).UTC(),
+	}
+}
+
+// DisplayName returns a name for userName that is safe to show to other
+// bidders, e.g., "b***" for "bidder".
+func DisplayName(userName string) string {
+	if userName == "" {
+		return ""
+	}
+
+	r := []rune(userName)
+
+	return string(r[0]) + "***"
+}
+
+// Subscriber receives the updates for one item, or all items if itemID
+// is zero.
+type Subscriber struct {
+	itemID  int
+	updates chan BidUpdate
+}
+
+// subscriberBuffer is the number of updates queued for a subscriber. A
+// subscriber that falls further behind is dropped, and the browser will
+// reconnect.
+const subscriberBuffer = 16
+
+// Broadcaster sends bid updates to subscribers.
+//
+// Publish never blocks, so a slow subscriber cannot delay bidding or
+// other subscribers.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewBroadcaster returns a Broadcaster without any subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[*Subscriber]struct{})}
+}
+
+// Subscribe returns a subscriber for updates to item itemID, or all items
+// if itemID is zero. Unsubscribe must be called when done.
+func (b *Broadcaster) Subscribe(itemID int) *Subscriber {
+	sub := &Subscriber{
+		itemID:  itemID,
+		updates: make(chan BidUpdate, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe removes sub and closes its updates channel. It is safe to
+// call more than once.
+func (b *Broadcaster) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+// remove removes sub. b.mu must be held.
+func (b *Broadcaster) remove(sub *Subscriber) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.updates)
+	}
+}
+
+// Publish sends update to subscribers of the item and of all items.
+// Subscribers that are too far behind are removed.
+func (b *Broadcaster) Publish(update BidUpdate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.itemID != 0 && sub.itemID != update.ID {
+			continue
+		}
+
+		select {
+		case sub.updates <- update:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// Len returns the number of subscribers.
+func (b *Broadcaster) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+const (
+	// streamHeartbeat is how often a comment is sent on an idle stream
+	// so that proxies and browsers do not close it.
+	streamHeartbeat = 30 * time.Second
+
+	// streamWriteTimeout limits how long a single write to a stream may
+	// take, which replaces the server write timeout for streams.
+	streamWriteTimeout = 10 * time.Second
+
+	// streamRetry is the reconnection time sent to browsers.
+	streamRetry = 5 * time.Second
+)
+
+// PlaceBid places a bid using BidDB and publishes the new price of the
+// item to browsers if the bid was placed.
+func (app *BidApp) PlaceBid(id int, bidAmount Money, userName string) (BidResult, error) {
+	return app.PlaceUnitsBid(id, bidAmount, 1, userName)
+}
+
+// PlaceUnitsBid places a bid for units of an item using BidDB and
+// publishes the new price of the item to browsers if the bid was placed.
+func (app *BidApp) PlaceUnitsBid(id int, bidAmount Money, units int, userName string) (BidResult, error) {
+	bidResult, err := app.BidDB.PlaceUnitsBid(id, bidAmount, units, userName)
+	if err == nil && bidResult.BidPlaced {
+		app.publishBid(id)
+	}
+
+	return bidResult, err
+}
+
+// BuyNow buys an item using BidDB and publishes the sale to browsers if
+// the item was bought.
+func (app *BidApp) BuyNow(id int, userName string) (BidResult, error) {
+	bidResult, err := app.BidDB.BuyNow(id, userName)
+	if err == nil && bidResult.BidPlaced {
+		app.publishBid(id)
+	}
+
+	return bidResult, err
+}
+
+// Pledge pledges to an item using BidDB and publishes the new total of the
+// item to browsers if the pledge was placed.
+func (app *BidApp) Pledge(id int, amount Money, userName string) (PledgeResult, error) {
+	pledgeResult, err := app.BidDB.Pledge(id, amount, userName)
+	if err == nil && pledgeResult.Pledged {
+		app.publishBid(id)
+	}
+
+	return pledgeResult, err
+}
+
+// publishBid publishes the current price of item id to browsers.
+func (app *BidApp) publishBid(id int) {
+	if app.Updates == nil {
+		return
+	}
+
+	update, err := app.bidUpdate(id)
+	if err != nil {
+		// bid was placed, so only the update is lost
+		slog.Error("unable to get bid update", "id", id, "err", err)
+		return
+	}
+
+	app.Updates.Publish(update)
+}
+
+// bidUpdate returns the current update for item id.
+func (app *BidApp) bidUpdate(id int) (BidUpdate, error) {
+	item, err := app.BidDB.GetItem(id)
+	if err != nil {
+		return BidUpdate{}, err
+	}
+
+	auction, err := app.BidDB.GetAuctionByID(item.AuctionID)
+	if err != nil {
+		return BidUpdate{}, err
+	}
+
+	// updates are public, so hide bids as for an anonymous user
+	if auction.BidsHidden(item, webauth.User{}) {
+		item = item.WithBidsHidden()
+	}
+
+	return NewBidUpdate(item, auction), nil
+}
+
+// ItemsStreamHandler streams bid updates for all items.
+func (app *BidApp) ItemsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	// Check if the HTTP method is valid.
+	if !webutil.IsMethodOrError(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	app.stream(w, r, 0, nil)
+}
+
+// ItemStreamHandler streams bid updates for the item in the request path.
+func (app *BidApp) ItemStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get logger with request info and function name.
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	// Check if the HTTP method is valid.
+	if !webutil.IsMethodOrError(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.Warn("invalid id", "id", r.PathValue("id"), "err", err)
+		webutil.RespondWithError(w, http.StatusBadRequest)
+		return
+	}
+
+	// send the current price first, since it may have changed since
+	// the page was loaded or the browser reconnected
+	update, err := app.bidUpdate(id)
+	if err != nil {
+		logger.Warn("unable to get item", "id", id, "err", err)
+		webutil.RespondWithError(w, http.StatusNotFound)
+		return
+	}
+
+	app.stream(w, r, id, &update)
+}
+
+// stream sends updates for item id, or all items if id is zero, until the
+// client disconnects. If first is not nil, it is sent before any updates.
+func (app *BidApp) stream(w http.ResponseWriter, r *http.Request, id int, first *BidUpdate) {
+	logger := webhandler.RequestLoggerWithFuncName(r)
+
+	if app.Updates == nil {
+		logger.Error("updates is nil")
+		webutil.RespondWithError(w, http.StatusInternalServerError)
+		return
+	}
+
+	// streams are long lived, so the server timeouts do not apply
+	rc := http.NewResponseController(w)
+	err := rc.SetReadDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warn("unable to clear read deadline", "err", err)
+	}
+
+	sub := app.Updates.Subscribe(id)
+	defer app.Updates.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// send writes s to the stream and flushes it to the client
+	send := func(s string) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		_, err = io.WriteString(w, s)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	err = send(fmt.Sprintf("retry: %d\n\n", streamRetry.Milliseconds()))
+	if err == nil && first != nil {
+		err = send(bidEvent(*first))
+	}
+	if err != nil {
+		logger.Warn("unable to write", "id", id, "err", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case update, ok := <-sub.updates:
+			if !ok {
+				logger.Info("subscriber dropped", "id", id)
+				return
+			}
+			err = send(bidEvent(update))
+		case <-heartbeat.C:
+			err = send(": heartbeat\n\n")
+		case <-r.Context().Done():
+			logger.Debug("client disconnected", "id", id)
+			return
+		}
+
+		if err != nil {
+			logger.Info("unable to write", "id", id, "err", err)
+			return
+		}
+	}
+}
+
+// bidEvent returns update as a "bid" event.
+func bidEvent(update BidUpdate) string {
+	return "event: bid\ndata: " + AsJson(update) + "\n\n"
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"bidder", "b***"},
+		{"é", "é***"},
+	}
+
+	for _, tc := range tests {
+		got := DisplayName(tc.in)
+		if got != tc.want {
+			t.Errorf("DisplayName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestBroadcasterPublish(t *testing.T) {
+	b := NewBroadcaster()
+
+	all := b.Subscribe(0)
+	one := b.Subscribe(1)
+	two := b.Subscribe(2)
+
+	update := BidUpdate{ID: 1, CurrentBid: Cents(1000), MinBid: Cents(1100)}
+	b.Publish(update)
+
+	for name, sub := range map[string]*Subscriber{"all": all, "one": one} {
+		select {
+		case got := <-sub.updates:
+			if got != update {
+				t.Errorf("%s got %v, want %v", name, got, update)
+			}
+		default:
+			t.Errorf("%s did not get update", name)
+		}
+	}
+
+	select {
+	case got := <-two.updates:
+		t.Errorf("two got %v, want nothing", got)
+	default:
+	}
+
+	b.Unsubscribe(all)
+	b.Unsubscribe(all) // safe to call again
+	if _, ok := <-all.updates; ok {
+		t.Errorf("updates not closed after Unsubscribe")
+	}
+	if got := b.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+}
+
+func TestBroadcasterDropsSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster()
+	sub := b.Subscribe(0)
+
+	// never read, so the buffer fills and the subscriber is dropped
+	for n := 0; n <= subscriberBuffer; n++ {
+		b.Publish(BidUpdate{ID: 1, CurrentBid: Cents(int64(n))})
+	}
+
+	if got := b.Len(); got != 0 {
+		t.Errorf("Len() = %d, want 0", got)
+	}
+
+	n := 0
+	for range sub.updates {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d updates, want %d", n, subscriberBuffer)
+	}
+}
+
+func TestItemsStreamHandler(t *testing.T) {
+	app := &BidApp{Updates: NewBroadcaster()}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.ItemsStreamHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	// wait for the handler to subscribe
+	for n := 0; app.Updates.Len() == 0; n++ {
+		if n > 100 {
+			t.Fatalf("handler did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	update := BidUpdate{ID: 1, CurrentBid: Cents(1000), Bidder: "b***", MinBid: Cents(1100)}
+	app.Updates.Publish(update)
+
+	want := []string{
+		"retry: 5000",
+		"",
+		"event: bid",
+		"data: " + AsJson(update),
+		"",
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for _, line := range want {
+		if !scanner.Scan() {
+			t.Fatalf("stream ended: %v", scanner.Err())
+		}
+		if got := scanner.Text(); got != line {
+			t.Errorf("got %q, want %q", got, line)
+		}
+	}
+
+	// client disconnect should unsubscribe
+	resp.Body.Close()
+	for n := 0; app.Updates.Len() != 0; n++ {
+		if n > 100 {
+			t.Fatalf("handler did not unsubscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBidEvent(t *testing.T) {
+	update := BidUpdate{ID: 2, CurrentBid: Cents(500)}
+
+	got := bidEvent(update)
+	if !strings.HasPrefix(got, "event: bid\ndata: {") ||
+		!strings.HasSuffix(got, "}\n\n") {
+		t.Errorf("bidEvent() = %q", got)
+	}
+}
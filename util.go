@@ -5,6 +5,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -54,3 +55,36 @@ func AnyEmpty(strings ...string) bool {
 	}
 	return false
 }
+
+// ParsePledgeLevels returns the amounts in a comma separated list, such as
+// "100, 250, 500". An empty list returns nil.
+func ParsePledgeLevels(s string) ([]Money, error) {
+	var levels []Money
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		amount, err := ParseMoney(field)
+		if err != nil || amount.IsZero() {
+			return nil, fmt.Errorf("invalid pledge level %q", field)
+		}
+
+		levels = append(levels, amount)
+	}
+
+	return levels, nil
+}
+
+// FormatPledgeLevels returns levels as a comma separated list, without
+// cents for whole amounts.
+func FormatPledgeLevels(levels []Money) string {
+	fields := make([]string, len(levels))
+	for i, amount := range levels {
+		fields[i] = strings.TrimSuffix(amount.String(), ".00")
+	}
+
+	return strings.Join(fields, ", ")
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -58,3 +59,52 @@ func TestAnyEmpty(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePledgeLevels(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    []Money
+		wantErr bool
+	}{
+		{"", nil, false},
+		{" , ", nil, false},
+		{"100", []Money{Cents(10000)}, false},
+		{"100, 250,500", []Money{Cents(10000), Cents(25000), Cents(50000)}, false},
+		{"12.50", []Money{Cents(1250)}, false},
+		{"100, x", nil, true},
+		{"0", nil, true},
+		{"-5", nil, true},
+		{"1.005", nil, true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParsePledgeLevels(tc.s)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParsePledgeLevels(%q): got err %v want err %v",
+				tc.s, err, tc.wantErr)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParsePledgeLevels(%q): got %v want %v",
+				tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestFormatPledgeLevels(t *testing.T) {
+	cases := []struct {
+		levels []Money
+		want   string
+	}{
+		{nil, ""},
+		{[]Money{Cents(10000)}, "100"},
+		{[]Money{Cents(10000), Cents(25000), Cents(1250)}, "100, 250, 12.50"},
+	}
+
+	for _, tc := range cases {
+		got := FormatPledgeLevels(tc.levels)
+		if got != tc.want {
+			t.Errorf("FormatPledgeLevels(%v): got %q want %q",
+				tc.levels, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+//go:build cgo
+
+package main
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// webpSupported is true if encodeWebP can encode images, which requires
+// cgo.
+const webpSupported = true
+
+// encodeWebP writes img to w as a lossy WebP with quality from 0 to 100.
+func encodeWebP(w io.Writer, img image.Image, quality int) error {
+	data, err := webp.EncodeRGB(img, float32(quality))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+//go:build !cgo
+
+package main
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// webpSupported is false without cgo, so only JPEG variants are saved.
+const webpSupported = false
+
+// encodeWebP returns an error, since WebP encoding requires cgo.
+func encodeWebP(w io.Writer, img image.Image, quality int) error {
+	return errors.New("WebP encoding requires cgo")
+}
@@ -18,11 +18,22 @@ type Winner struct {
 	ID         int
 	Title      string
 	Artist     string
-	CurrentBid float64
+	Quantity   int // units of the item
+	Unit       int // unit won, starting at one
+	CurrentBid Money
 	Modified   time.Time
 	ModifiedBy string
 	Email      string
 	FullName   string
+	ReserveMet bool // false if the item is not sold
+	BuyNow     bool // true if bought with buy now
+	Pledge     bool // true if a pledge to a fund-a-need item
+}
+
+// InCurrency returns the winner with its bid in currency.
+func (winner Winner) InCurrency(currency string) Winner {
+	winner.CurrentBid = winner.CurrentBid.In(currency)
+	return winner
 }
 
 // WinnerPageData holds the data to be passed to the winners page template.
@@ -30,6 +41,20 @@ type WinnerPageData struct {
 	Title   string
 	User    webauth.User
 	Winners []Winner
+	Total   Money // total of Winners that are sold
+	Auction Auction
+}
+
+// WinnersTotal returns the total amount of winners, excluding those below
+// the reserve price, which are not sold.
+func WinnersTotal(winners []Winner) Money {
+	var total Money
+	for _, winner := range winners {
+		if winner.ReserveMet {
+			total = total.Add(winner.CurrentBid)
+		}
+	}
+	return total
 }
 
 // WinnerHandler handles requests for the winners page.
@@ -52,18 +77,41 @@ func (app *BidApp) WinnerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
 	// Retrieve the list of winners from the database.
-	winners, err := app.BidDB.GetWinners()
+	winners, err := app.BidDB.GetWinners(auction.ID)
 	if err != nil {
 		logger.Error("failed to GetWinners", "err", err)
 	}
 
+	// Remove winners of sealed items until they close.
+	hidden, err := app.hiddenItemIDs(auction, user)
+	if err != nil {
+		logger.Error("failed to get hidden items", "err", err)
+	}
+	if len(hidden) > 0 {
+		shown := winners[:0]
+		for _, winner := range winners {
+			if !hidden[winner.ID] {
+				shown = append(shown, winner)
+			}
+		}
+		winners = shown
+	}
+	winners = inCurrency(winners, auction.Currency)
+
 	// Render page.
 	err = webutil.RenderTemplateOrError(app.Tmpl, w, "winners.html",
 		WinnerPageData{
 			Title:   app.Cfg.App.Name,
 			User:    user,
 			Winners: winners,
+			Total:   WinnersTotal(winners).In(auction.Currency),
+			Auction: auction,
 		})
 	if err != nil {
 		logger.Error("unable to render page", "err", err)
@@ -97,7 +145,12 @@ func (app *BidApp) WinnersCSVHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	winners, err := app.BidDB.GetWinners()
+	auction, ok := app.auctionOrError(w, r)
+	if !ok {
+		return
+	}
+
+	winners, err := app.BidDB.GetWinners(auction.ID)
 	if err != nil {
 		logger.Error("failed to get winners", "err", err)
 		webutil.RespondWithError(w, http.StatusInternalServerError)
@@ -105,7 +158,8 @@ func (app *BidApp) WinnersCSVHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment;filename=winners.csv")
+	w.Header().Set("Content-Disposition",
+		"attachment;filename="+auction.Slug+"-winners.csv")
 
 	err = csv.SliceOfStructsToCSV(w, winners)
 	if err != nil {
@@ -59,12 +59,29 @@ func TestWinnerHandler(t *testing.T) {
 		t.Errorf("could not get user: %v", err)
 	}
 
+	auction, err := app.BidDB.GetAuction(app.DefaultAuction)
+	if err != nil {
+		t.Fatalf("failed to GetAuction: %v", err)
+	}
+
 	// Retrieve the list of winners from the database.
-	winners, err := app.BidDB.GetWinners()
+	winners, err := app.BidDB.GetWinners(auction.ID)
 	if err != nil {
 		t.Fatalf("failed to GetWinners: %v", err)
 	}
 
+	// Winners of sealed items are hidden from user until they close.
+	hidden, err := app.hiddenItemIDs(auction, user)
+	if err != nil {
+		t.Fatalf("failed to get hidden items: %v", err)
+	}
+	var shown []Winner
+	for _, winner := range winners {
+		if !hidden[winner.ID] {
+			shown = append(shown, winner)
+		}
+	}
+
 	tests := []webhandler.TestCase{
 		{
 			Name:          "Invalid Method",
@@ -79,7 +96,8 @@ func TestWinnerHandler(t *testing.T) {
 			RequestMethod: http.MethodGet,
 			WantStatus:    http.StatusOK,
 			WantBody: winnersBody(t, WinnerPageData{
-				Title: app.Cfg.App.Name}),
+				Title:   app.Cfg.App.Name,
+				Auction: auction}),
 		},
 		{
 			Name:          "Valid User",
@@ -91,7 +109,9 @@ func TestWinnerHandler(t *testing.T) {
 			WantStatus: http.StatusOK,
 			WantBody: winnersBody(t, WinnerPageData{
 				Title:   app.Cfg.App.Name,
-				Winners: winners,
+				Winners: shown,
+				Total:   WinnersTotal(shown),
+				Auction: auction,
 				User:    user}),
 		},
 	}
@@ -113,7 +133,12 @@ func TestWinnersCSVHandler(t *testing.T) {
 		t.Fatalf("could not login user to get session token")
 	}
 
-	winners, err := app.BidDB.GetWinners()
+	auction, err := app.BidDB.GetAuction(app.DefaultAuction)
+	if err != nil {
+		t.Fatalf("failed to get auction: %v", err)
+	}
+
+	winners, err := app.BidDB.GetWinners(auction.ID)
 	if err != nil {
 		t.Fatalf("failed to get winners: %v", err)
 	}
@@ -179,3 +204,20 @@ func TestWinnersCSVHandler(t *testing.T) {
 	// Test the handler using the utility function.
 	webhandler.TestHandler(t, app.WinnersCSVHandler, tests)
 }
+
+func TestWinnersTotal(t *testing.T) {
+	winners := []Winner{
+		{ID: 1, CurrentBid: Cents(10), ReserveMet: true},
+		{ID: 2, CurrentBid: Cents(20), ReserveMet: true},
+		{ID: 3, CurrentBid: Cents(5000), ReserveMet: false},
+		{ID: 4, CurrentBid: Cents(1), ReserveMet: true, Pledge: true},
+	}
+
+	// 0.10 + 0.20 + 0.01 without float rounding
+	if got := WinnersTotal(winners); got != Cents(31) {
+		t.Errorf("WinnersTotal() = %v want 0.31", got)
+	}
+	if got := WinnersTotal(nil); got != (Money{}) {
+		t.Errorf("WinnersTotal(nil) = %v want 0.00", got)
+	}
+}